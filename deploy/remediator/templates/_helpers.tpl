@@ -26,3 +26,12 @@ app.kubernetes.io/managed-by: {{ .Release.Service }}
 app.kubernetes.io/name: {{ include "remediator.name" . }}
 app.kubernetes.io/instance: {{ .Release.Name }}
 {{- end -}}
+
+{{/* SCALE_TARGETS env value: "namespace/name=step:max,..." from .Values.deployments.scaleTargets. */}}
+{{- define "remediator.scaleTargets" -}}
+{{- $out := list -}}
+{{- range .Values.deployments.scaleTargets -}}
+{{- $out = append $out (printf "%s=%v:%v" .name .step .max) -}}
+{{- end -}}
+{{- join "," $out -}}
+{{- end -}}
@@ -2,13 +2,11 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"maps"
 	"sync"
+	"sync/atomic"
 	"time"
-
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
 )
 
 // Outcome is the result of an attempted remediation — recorded as a metric label and
@@ -16,104 +14,607 @@ import (
 type Outcome string
 
 const (
-	OutcomeDisabled    Outcome = "disabled"     // we turned the flag off
-	OutcomeAlreadyOff  Outcome = "already_off"  // nothing to do (idempotent)
-	OutcomeDryRun      Outcome = "dry_run"      // would have acted, but dry-run
-	OutcomeCooldown    Outcome = "cooldown"     // acted too recently for this incident
-	OutcomeFlagMissing Outcome = "flag_missing" // alert named a flag flagd doesn't have
+	OutcomeDisabled       Outcome = "disabled"        // we switched the flag to its safe variant
+	OutcomeAlreadyOff     Outcome = "already_off"     // nothing to do (idempotent)
+	OutcomeDryRun         Outcome = "dry_run"         // would have acted, but dry-run
+	OutcomeCooldown       Outcome = "cooldown"        // acted too recently for this incident
+	OutcomeFlagMissing    Outcome = "flag_missing"    // alert named a flag flagd doesn't have
+	OutcomeVariantMissing Outcome = "variant_missing" // alert named a safe variant the flag doesn't define
+	OutcomeRestored       Outcome = "restored"        // an earlier action was undone
+	OutcomePROpened       Outcome = "pr_opened"       // the change is committed on a branch awaiting merge
+
+	OutcomeRampedDown     Outcome = "ramped_down"      // we lowered a bad variant's fractional share one step
+	OutcomeRampInProgress Outcome = "ramp_in_progress" // a ramp-down is already stepping this flag
+
+	OutcomeRestorePending Outcome = "restore_pending" // alert resolved; undo waits out the soak period
+	OutcomeRestoreSkipped Outcome = "restore_skipped" // target changed since we acted; left as is
+
+	OutcomeRevertedByGitOps Outcome = "reverted_by_gitops" // Argo CD/Flux synced our change back out
+	OutcomeReverted         Outcome = "reverted"           // someone else changed it back
+
+	OutcomeVerified    Outcome = "verified"    // after acting, the SLO recovered
+	OutcomeIneffective Outcome = "ineffective" // after acting, the SLO kept burning (escalated)
+	OutcomeUnverified  Outcome = "unverified"  // no SLO evidence to judge the action by
+
+	OutcomeAborted        Outcome = "aborted"         // we aborted an in-flight canary
+	OutcomeAlreadyAborted Outcome = "already_aborted" // rollout already aborted (idempotent)
+	OutcomeRolledBack     Outcome = "rolled_back"     // we moved a workload back to an earlier revision
+	OutcomeAlreadyStable  Outcome = "already_stable"  // nothing in flight; already on the stable revision
+	OutcomeTargetMissing  Outcome = "target_missing"  // alert named a workload the cluster doesn't have
+
+	OutcomeNoPreviousRevision Outcome = "no_previous_revision" // refused: only one revision to roll back to
+
+	OutcomeScaledUp     Outcome = "scaled_up"      // we added replicas
+	OutcomeScaledDown   Outcome = "scaled_down"    // we removed the replicas we added
+	OutcomeAtCeiling    Outcome = "at_ceiling"     // already at the target's (or its HPA's) max
+	OutcomeNoScaleLimit Outcome = "no_scale_limit" // refused: no ceiling configured for the target
+	// refused: the alert's namespace label names a namespace the action isn't allowed in
+	OutcomeNamespaceNotAllowed Outcome = "namespace_not_allowed"
+
+	OutcomeBudgetExhausted Outcome = "budget_exhausted" // refused: global action budget spent; breaker tripped
+	OutcomePolicyDenied    Outcome = "policy_denied"    // refused by the remediation policy (see Result.Rule)
+
+	OutcomePendingApproval Outcome = "pending_approval" // policy wants a human: queued, not executed
+	OutcomeApprovalDenied  Outcome = "approval_denied"  // a human denied the queued action
+	OutcomeApprovalExpired Outcome = "approval_expired" // nobody answered before the queued action expired
+
+	OutcomePaused Outcome = "paused" // refused: an operator paused remediation (admin API)
+
+	// Operator toggles through the admin API, recorded in the audit log as action "admin".
+	OutcomeResumed       Outcome = "resumed"
+	OutcomeDryRunOn      Outcome = "dry_run_on"
+	OutcomeDryRunOff     Outcome = "dry_run_off"
+	OutcomeCooldownReset Outcome = "cooldown_reset"
+	OutcomeBudgetReset   Outcome = "budget_reset"
+
+	OutcomeWebhookRejected Outcome = "rejected" // a webhook failed authentication (audited as action "webhook")
+)
+
+// Registry modes, as reported by /healthz and the remediator_mode gauge.
+const (
+	modeActive  = "active"
+	modeDryRun  = "dry_run"
+	modePaused  = "paused"
+	modeObserve = "observe_only" // no registry: no Kubernetes API to act against
 )
 
-// FlagRemediator disables flagd feature flags in response to alerts. It is the bounded
-// action of OmniObserve's control loop: the ONLY mutation it can perform is setting a
-// named flag's defaultVariant to "off" — a feature-flag kill switch, the safest possible
-// remediation (reversible, scoped, and exactly undoing the injected fault).
-type FlagRemediator struct {
-	k8s       kubernetes.Interface
-	namespace string // where the flagd ConfigMap lives (e.g. otel-demo)
-	configMap string // e.g. flagd-config
-	configKey string // e.g. demo.flagd.json
-	dryRun    bool
-	cooldown  time.Duration
-
-	mu        sync.Mutex
-	lastActed map[string]time.Time // incidentKey -> last action time (cooldown)
-}
-
-// NewFlagRemediator builds a remediator bound to a flagd ConfigMap.
-func NewFlagRemediator(k8s kubernetes.Interface, namespace, configMap, configKey string, dryRun bool, cooldown time.Duration) *FlagRemediator {
-	return &FlagRemediator{
-		k8s:       k8s,
-		namespace: namespace,
-		configMap: configMap,
-		configKey: configKey,
-		dryRun:    dryRun,
-		cooldown:  cooldown,
-		lastActed: map[string]time.Time{},
-	}
-}
-
-// DisableFlag turns the named flagd flag off, idempotently and within a per-incident
-// cooldown. It returns the outcome (for metrics/logs) and any error. The flagd config is
-// a JSON document in a ConfigMap key; flagd hot-reloads the mounted file, so updating the
-// ConfigMap is enough to stop the fault — no pod restart.
-func (r *FlagRemediator) DisableFlag(ctx context.Context, flag, incidentKey string) (Outcome, error) {
-	// Cooldown: never act twice on the same incident within the window. This is what
-	// keeps the loop from thrashing when an alert keeps firing while it recovers.
+// Plan is what an Action intends to do for one alert, worked out without mutating
+// anything. It carries everything Execute, Verify and Undo need, so those steps never
+// have to re-derive intent from the alert.
+type Plan struct {
+	Action      string `json:"action"` // registry name of the action, e.g. "flagd"
+	Target      string `json:"target"` // what is acted on, e.g. the flag name — the metric/log label
+	IncidentKey string `json:"incidentKey"`
+	// Noop, when set, short-circuits the run: the action has nothing to do (already_off,
+	// flag_missing) and Execute is never called.
+	Noop        Outcome           `json:"noop,omitempty"`
+	Description string            `json:"description"`      // past-tense summary for logs and the RCA, e.g. "disabled flagd flag X"
+	Params      map[string]string `json:"params,omitempty"` // action-specific state, e.g. the variant Execute replaces
+}
+
+// Action is one bounded, reversible remediation. Plan decides (read-only), Execute
+// mutates, Verify confirms the mutation landed, and Undo reverses it. Actions don't know
+// about cooldown or dry-run: the Registry owns those, so every action gets the same rails.
+type Action interface {
+	Plan(ctx context.Context, alert Alert) (Plan, error)
+	Execute(ctx context.Context, p Plan) (Outcome, error)
+	Verify(ctx context.Context, p Plan) (bool, error)
+	Undo(ctx context.Context, p Plan) (Outcome, error)
+}
+
+// undoOnResolve is implemented by actions whose change is only meant to last as long as
+// the alert that caused it (extra capacity, a fault flag switched off): the registry
+// undoes them once the alert has resolved and stayed quiet for the soak period.
+type undoOnResolve interface {
+	UndoOnResolve() bool
+}
+
+// stacker is implemented by actions that may run again for an incident they already acted
+// on (another scale step). Stack folds the plan executed before into the one just
+// executed, so the single plan kept per incident still undoes every step.
+type stacker interface {
+	Stack(prev, next Plan) Plan
+}
+
+// Result is one registry run: which action was chosen, its plan, and how it turned out.
+type Result struct {
+	Plan     Plan
+	Outcome  Outcome
+	Executed bool   // Execute ran and the change was verified — the loop actually acted
+	Rule     string // the policy rule that allowed or denied the action, when a policy is loaded
+	Actor    string // who decided, for the audit log: empty for the remediator itself
+
+	ErrorRatio float64 // for a verification, the error ratio it judged the action by
+}
+
+// Registry maps the remediation_action annotation to Actions and runs them behind the
+// loop's uniform safety rails: a per-incident cooldown, a global dry-run switch, an
+// operator kill switch, and an optional policy and global action budget.
+type Registry struct {
+	actions map[string]Action
+	// dryRun starts as REMEDIATOR_DRY_RUN and can be toggled through the admin API. Once
+	// toggled (dryRunSet) it is persisted with the state, like paused, and the stored
+	// setting wins over the environment's after a restart or failover.
+	dryRun    atomic.Bool
+	dryRunSet atomic.Bool
+	// paused is the operator kill switch: nothing is executed or undone while it's set. It
+	// is persisted with the state, so a restart or a new leader doesn't quietly resume.
+	paused   atomic.Bool
+	cooldown time.Duration
+	// Soak is how long a resolved incident must stay quiet before its undo-on-resolve
+	// actions are reversed (by RestoreDue). Zero reverses them as soon as the alert resolves.
+	Soak time.Duration
+	// Budget caps mutations across all incidents; nil means unlimited. Undos don't spend it:
+	// reversing our own changes only shrinks the blast radius.
+	Budget *Budget
+	// policy says which actions may run for which alerts; nil allows any registered action.
+	// It is set with UsePolicy, which validates it against the registered actions.
+	policy *Policy
+
+	mu         sync.Mutex
+	lastActed  map[string]time.Time // incidentKey -> last action time (cooldown)
+	executed   map[string]Plan      // incidentKey -> plan executed for it (undo on resolve)
+	resolvedAt map[string]time.Time // incidentKey -> when it resolved (soaking before undo)
+	now        func() time.Time
+
+	store  StateStore // nil: state lives only in memory
+	saveMu sync.Mutex // serialises saves so an older snapshot never lands after a newer one
+}
+
+// NewRegistry builds an empty registry; actions are added with Register.
+func NewRegistry(dryRun bool, cooldown time.Duration) *Registry {
+	r := &Registry{
+		actions:    map[string]Action{},
+		cooldown:   cooldown,
+		lastActed:  map[string]time.Time{},
+		executed:   map[string]Plan{},
+		resolvedAt: map[string]time.Time{},
+		now:        time.Now,
+	}
+	r.dryRun.Store(dryRun)
+	return r
+}
+
+// UseStore loads the state persisted in s into the registry, then writes every later
+// change through to it. Call it once at startup, before the registry sees any alerts.
+func (r *Registry) UseStore(ctx context.Context, s StateStore) error {
+	st, err := s.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
 	r.mu.Lock()
-	if last, ok := r.lastActed[incidentKey]; ok && time.Since(last) < r.cooldown {
-		r.mu.Unlock()
-		return OutcomeCooldown, nil
+	r.replaceState(st)
+	r.store = s
+	r.mu.Unlock()
+	return nil
+}
+
+// Reload replaces the registry's state with what its store holds — for a replica taking
+// over as leader, whose memory is whatever it loaded at startup. Without a store it is a no-op.
+func (r *Registry) Reload(ctx context.Context) error {
+	if r.store == nil {
+		return nil
+	}
+	st, err := r.store.Load(ctx)
+	if err != nil {
+		return fmt.Errorf("load state: %w", err)
+	}
+	r.mu.Lock()
+	r.replaceState(st)
+	r.mu.Unlock()
+	return nil
+}
+
+// replaceState swaps in loaded state; r.mu must be held.
+func (r *Registry) replaceState(st State) {
+	clear(r.lastActed)
+	clear(r.executed)
+	clear(r.resolvedAt)
+	maps.Copy(r.lastActed, st.LastActed)
+	maps.Copy(r.executed, st.Executed)
+	maps.Copy(r.resolvedAt, st.ResolvedAt)
+	r.paused.Store(st.Paused)
+	if st.DryRun != nil {
+		r.dryRun.Store(*st.DryRun)
+		r.dryRunSet.Store(true)
+	}
+	if r.Budget != nil {
+		r.Budget.RestoreTrips(st.BudgetTripped)
+	}
+}
+
+// UsePolicy validates p against the registered actions and makes Run consult it. Call it
+// after every Register.
+func (r *Registry) UsePolicy(p *Policy) error {
+	if err := p.Validate(r.actions); err != nil {
+		return err
+	}
+	r.policy = p
+	return nil
+}
+
+// Register makes an action selectable by name. Registering a name twice replaces it.
+func (r *Registry) Register(name string, a Action) {
+	r.actions[name] = a
+}
+
+// Select returns the action an alert asks for, and false when it asks for none or names
+// one that isn't registered — the loop never guesses an action.
+func (r *Registry) Select(alert Alert) (string, Action, bool) {
+	name := alert.remediationAction()
+	a, ok := r.actions[name]
+	return name, a, ok && name != ""
+}
+
+// Run plans and (unless cooldown, a no-op plan, the policy, dry-run or a spent budget
+// stops it) executes the action the alert selects. Cooldown is checked first so a still-firing
+// alert doesn't even re-read cluster state; dry-run marks the incident acted so the same
+// intent isn't re-logged every evaluation, but never mutates, so it spends no budget.
+func (r *Registry) Run(ctx context.Context, alert Alert) (Result, error) {
+	name, a, ok := r.Select(alert)
+	if !ok {
+		return Result{}, fmt.Errorf("no registered action %q", alert.remediationAction())
 	}
+	key := alert.incidentKey()
+
+	// Firing again means the incident didn't stay quiet: whatever we did is still needed.
+	r.mu.Lock()
+	_, wasSoaking := r.resolvedAt[key]
+	delete(r.resolvedAt, key)
 	r.mu.Unlock()
+	if wasSoaking {
+		r.persist(ctx)
+	}
+
+	if r.paused.Load() {
+		return Result{Plan: Plan{Action: name, IncidentKey: key}, Outcome: OutcomePaused}, nil
+	}
+	if r.cooling(key) {
+		return Result{Plan: Plan{Action: name, IncidentKey: key}, Outcome: OutcomeCooldown}, nil
+	}
 
-	cm, err := r.k8s.CoreV1().ConfigMaps(r.namespace).Get(ctx, r.configMap, metav1.GetOptions{})
+	plan, err := a.Plan(ctx, alert)
+	plan.Action, plan.IncidentKey = name, key
 	if err != nil {
-		return "", fmt.Errorf("get configmap %s/%s: %w", r.namespace, r.configMap, err)
+		return Result{Plan: plan}, fmt.Errorf("plan %s: %w", name, err)
+	}
+	if plan.Noop != "" {
+		return Result{Plan: plan, Outcome: plan.Noop}, nil
+	}
+	var rule string
+	if r.policy != nil {
+		var verdict Verdict
+		verdict, rule = r.policy.Decide(alert, plan, r.now())
+		switch verdict {
+		case VerdictDeny:
+			return Result{Plan: plan, Outcome: OutcomePolicyDenied, Rule: rule}, nil
+		case VerdictApprove:
+			return Result{Plan: plan, Outcome: OutcomePendingApproval, Rule: rule}, nil
+		}
+	}
+	return r.execute(ctx, a, plan, rule)
+}
+
+// RunApproved executes a plan a human approved. It plans again first, because the
+// cluster may have moved on while the plan waited, and refuses if the target is no longer
+// the one approved. The policy isn't consulted again — the approval was its decision — but
+// cooldown, dry-run and the budget still apply.
+func (r *Registry) RunApproved(ctx context.Context, alert Alert, approved Plan, rule string) (Result, error) {
+	name, key := approved.Action, approved.IncidentKey
+	a, ok := r.actions[name]
+	if !ok {
+		return Result{Plan: approved}, fmt.Errorf("no registered action %q", name)
+	}
+	if r.paused.Load() {
+		return Result{Plan: approved, Outcome: OutcomePaused, Rule: rule}, nil
+	}
+	if r.cooling(key) {
+		return Result{Plan: approved, Outcome: OutcomeCooldown, Rule: rule}, nil
+	}
+
+	plan, err := a.Plan(ctx, alert)
+	plan.Action, plan.IncidentKey = name, key
+	if err != nil {
+		return Result{Plan: plan}, fmt.Errorf("plan %s: %w", name, err)
+	}
+	if plan.Noop != "" {
+		return Result{Plan: plan, Outcome: plan.Noop, Rule: rule}, nil
 	}
+	if plan.Target != approved.Target {
+		return Result{Plan: plan}, fmt.Errorf("plan %s: target is now %s, approved was %s", name, plan.Target, approved.Target)
+	}
+	return r.execute(ctx, a, plan, rule)
+}
 
-	raw, ok := cm.Data[r.configKey]
+// Step executes a further step of an action already under way for p's incident — a ramp's
+// next step — behind the rails every mutation goes through. The cooldown and policy that
+// let the first step through aren't consulted again, but the pause, dry-run and the budget
+// are, and the executed step becomes the plan kept for undo.
+func (r *Registry) Step(ctx context.Context, p Plan, rule string) (Result, error) {
+	a, ok := r.actions[p.Action]
 	if !ok {
-		return "", fmt.Errorf("key %q not in configmap %s/%s", r.configKey, r.namespace, r.configMap)
+		return Result{Plan: p}, fmt.Errorf("no registered action %q", p.Action)
+	}
+	if r.paused.Load() {
+		return Result{Plan: p, Outcome: OutcomePaused, Rule: rule}, nil
+	}
+	return r.execute(ctx, a, p, rule)
+}
+
+// execute takes a plan the rails so far have let through past dry-run and the budget,
+// then executes and verifies it.
+func (r *Registry) execute(ctx context.Context, a Action, plan Plan, rule string) (Result, error) {
+	name, key := plan.Action, plan.IncidentKey
+	if r.dryRun.Load() {
+		r.markActed(ctx, key)
+		return Result{Plan: plan, Outcome: OutcomeDryRun, Rule: rule}, nil
+	}
+	if r.Budget != nil && !r.Budget.Take(plan.Params["namespace"]) {
+		r.persist(ctx) // the trip, if this refusal made one
+		return Result{Plan: plan, Outcome: OutcomeBudgetExhausted, Rule: rule}, nil
 	}
 
-	var doc map[string]any
-	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
-		return "", fmt.Errorf("parse flagd config: %w", err)
+	outcome, err := a.Execute(ctx, plan)
+	if err != nil {
+		return Result{Plan: plan}, fmt.Errorf("execute %s: %w", name, err)
 	}
+	r.markActed(ctx, key)
 
-	flags, _ := doc["flags"].(map[string]any)
-	entry, ok := flags[flag].(map[string]any)
+	ok, err := a.Verify(ctx, plan)
+	if err != nil {
+		return Result{Plan: plan, Outcome: outcome}, fmt.Errorf("verify %s: %w", name, err)
+	}
 	if !ok {
-		return OutcomeFlagMissing, nil
+		return Result{Plan: plan, Outcome: outcome}, fmt.Errorf("verify %s: change to %s did not persist", name, plan.Target)
 	}
+	r.mu.Lock()
+	kept := plan
+	if prev, ok := r.executed[key]; ok && prev.Action == name {
+		if s, ok := a.(stacker); ok {
+			kept = s.Stack(prev, plan)
+		}
+	}
+	r.executed[key] = kept
+	r.mu.Unlock()
+	r.persist(ctx)
+	return Result{Plan: plan, Outcome: outcome, Executed: true, Rule: rule}, nil
+}
 
-	if entry["defaultVariant"] == "off" {
-		return OutcomeAlreadyOff, nil // idempotent: already remediated
+// Resolve handles a resolved alert for an incident we acted on, when the action asks to
+// be undone on resolve. With no soak period it undoes straight away; otherwise it starts
+// the soak clock and reports restore_pending, leaving the undo to RestoreDue. It reports
+// false when there is nothing to do, including a repeat resolve for an incident already
+// soaking.
+func (r *Registry) Resolve(ctx context.Context, alert Alert) (Result, bool, error) {
+	key := alert.incidentKey()
+	r.mu.Lock()
+	p, ok := r.executed[key]
+	_, soaking := r.resolvedAt[key]
+	r.mu.Unlock()
+	if !ok || soaking {
+		return Result{}, false, nil
+	}
+	if u, ok := r.actions[p.Action].(undoOnResolve); !ok || !u.UndoOnResolve() {
+		return Result{}, false, nil
 	}
 
-	if r.dryRun {
-		// Mark acted so we don't re-log the same intent every evaluation, but never mutate.
-		r.markActed(incidentKey)
-		return OutcomeDryRun, nil
+	if r.Soak > 0 || r.paused.Load() { // paused: soak until resumed
+		r.mu.Lock()
+		r.resolvedAt[key] = r.now()
+		r.mu.Unlock()
+		r.persist(ctx)
+		return Result{Plan: p, Outcome: OutcomeRestorePending}, true, nil
 	}
+	res, err := r.undoExecuted(ctx, key, p, r.now())
+	return res, true, err
+}
 
-	entry["defaultVariant"] = "off"
-	patched, err := json.MarshalIndent(doc, "", "  ")
+// driftDetector is implemented by actions that can tell when the change a plan made has
+// been put back by someone else — typically a GitOps controller re-syncing the object to
+// what's in git.
+type driftDetector interface {
+	// Drifted returns the reverted outcome, or "" while the change is still in place.
+	Drifted(ctx context.Context, p Plan) (Outcome, error)
+}
+
+// Restore is one soaked undo attempted by RestoreDue, or drift found by CheckDrift.
+type Restore struct {
+	Result
+	Err error
+}
+
+// RestoreDue undoes every executed plan whose incident resolved at least Soak ago and
+// hasn't fired since. It is called periodically; each returned Restore is one decision
+// to record.
+func (r *Registry) RestoreDue(ctx context.Context) []Restore {
+	if r.paused.Load() || r.dryRun.Load() {
+		return nil // due undos wait for resume, or for dry-run to be switched off
+	}
+	now := r.now()
+	due := map[string]Plan{}
+	resolved := map[string]time.Time{}
+	r.mu.Lock()
+	for key, at := range r.resolvedAt {
+		if now.Sub(at) >= r.Soak {
+			due[key], resolved[key] = r.executed[key], at
+			delete(r.resolvedAt, key)
+		}
+	}
+	r.mu.Unlock()
+	if len(due) > 0 {
+		r.persist(ctx)
+	}
+
+	var out []Restore
+	for key, p := range due {
+		res, err := r.undoExecuted(ctx, key, p, resolved[key])
+		out = append(out, Restore{Result: res, Err: err})
+	}
+	return out
+}
+
+// CheckDrift asks each executed plan's action whether its change is still in place. A
+// reverted change is forgotten — there is nothing left to undo, and undoing it later would
+// fight whoever reverted it — and returned as a decision to record. Its cooldown stands,
+// so a GitOps controller and the remediator don't take turns flipping the target.
+func (r *Registry) CheckDrift(ctx context.Context) []Restore {
+	r.mu.Lock()
+	executed := maps.Clone(r.executed)
+	r.mu.Unlock()
+
+	var out []Restore
+	for key, p := range executed {
+		d, ok := r.actions[p.Action].(driftDetector)
+		if !ok || r.dryRun.Load() {
+			continue
+		}
+		outcome, err := d.Drifted(ctx, p)
+		if err != nil {
+			out = append(out, Restore{Result: Result{Plan: p}, Err: fmt.Errorf("drift check %s: %w", p.Action, err)})
+			continue
+		}
+		if outcome == "" {
+			continue
+		}
+		r.mu.Lock()
+		delete(r.executed, key)
+		delete(r.resolvedAt, key)
+		r.mu.Unlock()
+		r.persist(ctx)
+		out = append(out, Restore{Result: Result{Plan: p, Outcome: outcome}})
+	}
+	return out
+}
+
+// undoExecuted undoes the plan executed for key, whose incident resolved at, and forgets
+// it once undone. A dry-run undo changes nothing, so the plan is kept, due again from at
+// once dry-run is switched off.
+func (r *Registry) undoExecuted(ctx context.Context, key string, p Plan, at time.Time) (Result, error) {
+	outcome, err := r.Undo(ctx, p)
 	if err != nil {
-		return "", fmt.Errorf("marshal flagd config: %w", err)
+		return Result{Plan: p}, fmt.Errorf("undo %s: %w", p.Action, err)
+	}
+	if outcome == OutcomeDryRun {
+		r.mu.Lock()
+		r.resolvedAt[key] = at
+		r.mu.Unlock()
+		r.persist(ctx)
+		return Result{Plan: p, Outcome: outcome}, nil
+	}
+	r.mu.Lock()
+	delete(r.executed, key)
+	r.mu.Unlock()
+	r.persist(ctx)
+	return Result{Plan: p, Outcome: outcome, Executed: outcome != OutcomeRestoreSkipped}, nil
+}
+
+// Undo reverses a previously executed plan through the action that produced it.
+func (r *Registry) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	a, ok := r.actions[p.Action]
+	if !ok {
+		return "", fmt.Errorf("no registered action %q", p.Action)
+	}
+	if r.dryRun.Load() {
+		return OutcomeDryRun, nil
+	}
+	return a.Undo(ctx, p)
+}
+
+// Mode is paused, dry_run or active.
+func (r *Registry) Mode() string {
+	switch {
+	case r.paused.Load():
+		return modePaused
+	case r.dryRun.Load():
+		return modeDryRun
+	default:
+		return modeActive
+	}
+}
+
+// SetPaused sets the kill switch and persists it, reporting whether that changed it.
+func (r *Registry) SetPaused(ctx context.Context, paused bool) bool {
+	if r.paused.Swap(paused) == paused {
+		return false
+	}
+	r.persist(ctx)
+	return true
+}
+
+// SetDryRun switches dry-run on or off and persists it, reporting whether that changed it.
+func (r *Registry) SetDryRun(ctx context.Context, on bool) bool {
+	r.dryRunSet.Store(true)
+	if r.dryRun.Swap(on) == on {
+		return false
+	}
+	r.persist(ctx)
+	return true
+}
+
+// ResetCooldown forgets when the loop last acted for incidentKey, so the alert's next
+// firing is acted on again. It reports whether the incident was cooling down.
+func (r *Registry) ResetCooldown(ctx context.Context, incidentKey string) bool {
+	cooling := r.cooling(incidentKey)
+	r.mu.Lock()
+	_, known := r.lastActed[incidentKey]
+	delete(r.lastActed, incidentKey)
+	r.mu.Unlock()
+	if known {
+		r.persist(ctx)
 	}
-	cm.Data[r.configKey] = string(patched)
+	return cooling
+}
 
-	if _, err := r.k8s.CoreV1().ConfigMaps(r.namespace).Update(ctx, cm, metav1.UpdateOptions{}); err != nil {
-		return "", fmt.Errorf("update configmap %s/%s: %w", r.namespace, r.configMap, err)
+// ResetBudget closes the budget breaker for scope ("" resets every scope) and persists
+// that, returning the scopes that were tripped.
+func (r *Registry) ResetBudget(ctx context.Context, scope string) []string {
+	reset := r.Budget.Reset(scope)
+	if len(reset) > 0 {
+		r.persist(ctx)
 	}
+	return reset
+}
 
-	r.markActed(incidentKey)
-	return OutcomeDisabled, nil
+func (r *Registry) cooling(incidentKey string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	last, ok := r.lastActed[incidentKey]
+	return ok && r.now().Sub(last) < r.cooldown
 }
 
-func (r *FlagRemediator) markActed(incidentKey string) {
+func (r *Registry) markActed(ctx context.Context, incidentKey string) {
 	r.mu.Lock()
-	r.lastActed[incidentKey] = time.Now()
+	r.lastActed[incidentKey] = r.now()
 	r.mu.Unlock()
+	r.persist(ctx)
+}
+
+// persist writes the registry's state through to its store, dropping cooldowns that have
+// already expired so the stored state doesn't grow with every incident ever seen. A failed
+// save is logged rather than returned: the cluster change has already happened, and the
+// in-memory state is still right for as long as this process lives.
+func (r *Registry) persist(ctx context.Context) {
+	if r.store == nil {
+		return
+	}
+	r.saveMu.Lock()
+	defer r.saveMu.Unlock()
+
+	r.mu.Lock()
+	now := r.now()
+	maps.DeleteFunc(r.lastActed, func(_ string, at time.Time) bool { return now.Sub(at) >= r.cooldown })
+	st := State{LastActed: r.lastActed, Executed: r.executed, ResolvedAt: r.resolvedAt}.clone()
+	st.Paused = r.paused.Load()
+	if r.dryRunSet.Load() {
+		on := r.dryRun.Load()
+		st.DryRun = &on
+	}
+	if r.Budget != nil {
+		st.BudgetTripped = r.Budget.Trips()
+	}
+	r.mu.Unlock()
+
+	if err := r.store.Save(ctx, st); err != nil {
+		logger.Errorw("could not persist remediation state; a restart will forget it", "error", err)
+	}
 }
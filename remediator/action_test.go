@@ -2,120 +2,126 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"testing"
 	"time"
-
-	corev1 "k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes/fake"
 )
 
-// flagdConfig builds a minimal demo.flagd.json with productCatalogFailure at the given
-// defaultVariant, so tests can start from "on" (fault active) or "off" (already healed).
-func flagdConfig(defaultVariant string) string {
-	doc := map[string]any{
-		"flags": map[string]any{
-			"productCatalogFailure": map[string]any{
-				"state":          "ENABLED",
-				"variants":       map[string]any{"on": true, "off": false},
-				"defaultVariant": defaultVariant,
-			},
-		},
-	}
-	b, _ := json.Marshal(doc)
-	return string(b)
+// stubAction records calls so tests can check the registry's rails independently of any
+// real cluster mutation.
+type stubAction struct {
+	noop     Outcome
+	verified bool
+	executed int
 }
 
-func newFakeRemediator(t *testing.T, defaultVariant string, dryRun bool, cooldown time.Duration) (*FlagRemediator, *fake.Clientset) {
-	t.Helper()
-	cm := &corev1.ConfigMap{
-		ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "otel-demo"},
-		Data:       map[string]string{"demo.flagd.json": flagdConfig(defaultVariant)},
-	}
-	cs := fake.NewClientset(cm)
-	return NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json", dryRun, cooldown), cs
+func (s *stubAction) Plan(_ context.Context, alert Alert) (Plan, error) {
+	return Plan{Target: alert.Labels["service"], Noop: s.noop, Description: "stubbed"}, nil
 }
 
-// currentVariant reads productCatalogFailure's defaultVariant back from the cluster.
-func currentVariant(t *testing.T, cs *fake.Clientset) string {
-	t.Helper()
-	cm, err := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
-	if err != nil {
-		t.Fatalf("get configmap: %v", err)
+func (s *stubAction) Execute(context.Context, Plan) (Outcome, error) {
+	s.executed++
+	return "stubbed", nil
+}
+
+func (s *stubAction) Verify(context.Context, Plan) (bool, error) { return s.verified, nil }
+
+func (s *stubAction) Undo(context.Context, Plan) (Outcome, error) { return OutcomeRestored, nil }
+
+func stubAlert() Alert {
+	return Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighLatency", "service": "cart"},
+		Annotations: map[string]string{"remediation_action": "stub"},
 	}
-	var doc map[string]any
-	if err := json.Unmarshal([]byte(cm.Data["demo.flagd.json"]), &doc); err != nil {
-		t.Fatalf("parse: %v", err)
+}
+
+func TestRegistry_Select(t *testing.T) {
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", &stubAction{})
+	r.Register(actionFlagd, &stubAction{})
+	r.Register(actionRollback, &stubAction{})
+
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		want        string
+		wantOK      bool
+	}{
+		{"explicit action", map[string]string{"remediation_action": "stub"}, "stub", true},
+		{"flag annotation defaults to flagd", map[string]string{"remediation_flag": "f"}, actionFlagd, true},
+		{"rollback annotation defaults to rollback", map[string]string{"remediation_rollback": "d"}, actionRollback, true},
+		{"unregistered action", map[string]string{"remediation_action": "reboot"}, "reboot", false},
+		{"no opt-in", nil, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _, ok := r.Select(Alert{Annotations: tt.annotations})
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("Select = (%q, %v), want (%q, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
 	}
-	flags := doc["flags"].(map[string]any)
-	return flags["productCatalogFailure"].(map[string]any)["defaultVariant"].(string)
 }
 
-func TestDisableFlag_TurnsOff(t *testing.T) {
-	r, cs := newFakeRemediator(t, "on", false, time.Minute)
-	got, err := r.DisableFlag(context.Background(), "productCatalogFailure", "inc1")
+func TestRegistry_RailsApplyToEveryAction(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+
+	res, err := r.Run(context.Background(), stubAlert())
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
-	}
-	if got != OutcomeDisabled {
-		t.Errorf("outcome = %q, want disabled", got)
+		t.Fatalf("first run: %v", err)
 	}
-	if v := currentVariant(t, cs); v != "off" {
-		t.Errorf("flag defaultVariant = %q, want off", v)
+	if !res.Executed || res.Outcome != "stubbed" || res.Plan.Action != "stub" || res.Plan.IncidentKey != "HighLatency|cart" {
+		t.Errorf("first run = %+v, want executed stub plan for HighLatency|cart", res)
 	}
-}
 
-func TestDisableFlag_Idempotent(t *testing.T) {
-	r, cs := newFakeRemediator(t, "off", false, time.Minute)
-	got, err := r.DisableFlag(context.Background(), "productCatalogFailure", "inc1")
+	res, err = r.Run(context.Background(), stubAlert())
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("second run: %v", err)
 	}
-	if got != OutcomeAlreadyOff {
-		t.Errorf("outcome = %q, want already_off", got)
-	}
-	if v := currentVariant(t, cs); v != "off" {
-		t.Errorf("flag defaultVariant = %q, want off", v)
+	if res.Outcome != OutcomeCooldown || a.executed != 1 {
+		t.Errorf("second run outcome = %q with %d executions, want cooldown and 1", res.Outcome, a.executed)
 	}
 }
 
-func TestDisableFlag_DryRunDoesNotMutate(t *testing.T) {
-	r, cs := newFakeRemediator(t, "on", true, time.Minute)
-	got, err := r.DisableFlag(context.Background(), "productCatalogFailure", "inc1")
+func TestRegistry_DryRunNeverExecutes(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(true, time.Minute)
+	r.Register("stub", a)
+
+	res, err := r.Run(context.Background(), stubAlert())
 	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+		t.Fatalf("run: %v", err)
 	}
-	if got != OutcomeDryRun {
-		t.Errorf("outcome = %q, want dry_run", got)
-	}
-	if v := currentVariant(t, cs); v != "on" {
-		t.Errorf("dry-run mutated the flag to %q, want it left on", v)
+	if res.Outcome != OutcomeDryRun || res.Executed || a.executed != 0 {
+		t.Errorf("dry-run = %+v with %d executions, want dry_run and none", res, a.executed)
 	}
 }
 
-func TestDisableFlag_Cooldown(t *testing.T) {
-	r, _ := newFakeRemediator(t, "on", false, time.Minute)
-	if _, err := r.DisableFlag(context.Background(), "productCatalogFailure", "inc1"); err != nil {
-		t.Fatalf("first call: %v", err)
-	}
-	// Same incident again within the window: must not act.
-	got, err := r.DisableFlag(context.Background(), "productCatalogFailure", "inc1")
+func TestRegistry_NoopPlanSkipsExecute(t *testing.T) {
+	a := &stubAction{noop: OutcomeAlreadyOff}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+
+	res, err := r.Run(context.Background(), stubAlert())
 	if err != nil {
-		t.Fatalf("second call: %v", err)
+		t.Fatalf("run: %v", err)
 	}
-	if got != OutcomeCooldown {
-		t.Errorf("outcome = %q, want cooldown", got)
+	if res.Outcome != OutcomeAlreadyOff || a.executed != 0 {
+		t.Errorf("outcome = %q with %d executions, want already_off and none", res.Outcome, a.executed)
 	}
 }
 
-func TestDisableFlag_Missing(t *testing.T) {
-	r, _ := newFakeRemediator(t, "on", false, time.Minute)
-	got, err := r.DisableFlag(context.Background(), "noSuchFlag", "inc1")
-	if err != nil {
-		t.Fatalf("unexpected error: %v", err)
+func TestRegistry_UnverifiedChangeIsAnError(t *testing.T) {
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", &stubAction{verified: false})
+
+	res, err := r.Run(context.Background(), stubAlert())
+	if err == nil {
+		t.Fatal("expected an error when Verify reports the change didn't land")
 	}
-	if got != OutcomeFlagMissing {
-		t.Errorf("outcome = %q, want flag_missing", got)
+	if res.Executed {
+		t.Error("an unverified change must not count as executed")
 	}
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// remediatorMode: "is the loop acting right now?" — 1 for the current mode (active,
+// dry_run, paused or observe_only), 0 for the others.
+func init() {
+	for _, m := range []string{modeActive, modeDryRun, modePaused, modeObserve} {
+		prometheus.MustRegister(prometheus.NewGaugeFunc(
+			prometheus.GaugeOpts{
+				Name:        "remediator_mode",
+				Help:        "The remediator's operating mode: 1 for the current one.",
+				ConstLabels: prometheus.Labels{"mode": m},
+			},
+			func() float64 {
+				if mode() == m {
+					return 1
+				}
+				return 0
+			},
+		))
+	}
+}
+
+// mode is the registry's mode, or observe_only without one.
+func mode() string {
+	if registry == nil {
+		return modeObserve
+	}
+	return registry.Mode()
+}
+
+// adminAuth guards the admin endpoints with a bearer token from REMEDIATOR_ADMIN_TOKEN.
+// With no token configured the admin API is off: an operator lever that reopens the loop
+// must never be reachable anonymously.
+func adminAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := os.Getenv("REMEDIATOR_ADMIN_TOKEN")
+		if token == "" {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API disabled (no REMEDIATOR_ADMIN_TOKEN)"})
+			return
+		}
+		got, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// budgetResetHandler closes a tripped budget breaker: ?namespace=<ns> resets one
+// namespace, no parameter resets every scope. Only the leader's breaker matters, and the
+// reset is audited as action "admin" like the other toggles.
+func budgetResetHandler(c *gin.Context) {
+	var req adminRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+			return
+		}
+	}
+	switch {
+	case registry == nil || registry.Budget == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no action budget configured"})
+		return
+	case !acting():
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "the budget is kept by the leader", "leader": leadership.Leader()})
+		return
+	}
+	scope := c.Query("namespace")
+	reset := registry.ResetBudget(c.Request.Context(), scope)
+	logger.Warnw("action budget reset by admin", "scope", scope, "reset", reset, "by", req.By, "reason", req.Reason)
+	target := "budget"
+	if scope != "" {
+		target += "/" + scope
+	}
+	audit(trace.SpanFromContext(c.Request.Context()), "admin", "", Result{
+		Plan:    Plan{Action: "admin", Target: target},
+		Outcome: OutcomeBudgetReset,
+		Actor:   req.actor(),
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"reset": reset})
+}
+
+// adminRequest is the optional body of an admin toggle, for the audit trail.
+type adminRequest struct {
+	By     string `json:"by"`
+	Reason string `json:"reason"`
+}
+
+// actor names the operator in the audit log.
+func (r adminRequest) actor() string {
+	if r.By == "" {
+		return "admin"
+	}
+	return "admin:" + r.By
+}
+
+// pauseHandler is the kill switch: nothing is executed or undone until resume. Alerts
+// still arrive, and are recorded as paused.
+func pauseHandler(c *gin.Context) {
+	adminToggle(c, OutcomePaused, "", func(ctx context.Context) bool { return registry.SetPaused(ctx, true) })
+}
+
+// resumeHandler lifts the kill switch and runs the undos that came due meanwhile, so
+// resolves held by the pause don't wait for the restore loop's next tick.
+func resumeHandler(c *gin.Context) {
+	adminToggle(c, OutcomeResumed, "", func(ctx context.Context) bool { return registry.SetPaused(ctx, false) })
+	if c.Writer.Status() == http.StatusOK {
+		restoreDue(c.Request.Context())
+	}
+}
+
+// dryRunHandler switches dry-run on (/admin/dry-run/on) or off (/admin/dry-run/off) until
+// the next restart, which goes back to REMEDIATOR_DRY_RUN.
+func dryRunHandler(c *gin.Context) {
+	switch c.Param("state") {
+	case "on":
+		adminToggle(c, OutcomeDryRunOn, "", func(ctx context.Context) bool { return registry.SetDryRun(ctx, true) })
+	case "off":
+		adminToggle(c, OutcomeDryRunOff, "", func(ctx context.Context) bool { return registry.SetDryRun(ctx, false) })
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "want /admin/dry-run/on or /admin/dry-run/off"})
+	}
+}
+
+// cooldownResetHandler lets the loop act again on ?incidentKey=<alertname|service> before
+// its cooldown is up — e.g. after a human undid a change that turned out to be right.
+func cooldownResetHandler(c *gin.Context) {
+	key := c.Query("incidentKey")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "incidentKey is required"})
+		return
+	}
+	adminToggle(c, OutcomeCooldownReset, key, func(ctx context.Context) bool { return registry.ResetCooldown(ctx, key) })
+}
+
+// adminToggle is the shared body of the mode endpoints: only the acting replica holds the
+// registry state that matters, and every call — changing anything or not — goes in the
+// audit log as action "admin".
+func adminToggle(c *gin.Context, outcome Outcome, incidentKey string, apply func(ctx context.Context) bool) {
+	var req adminRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+			return
+		}
+	}
+	if registry == nil || !acting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "not the acting replica; retry"})
+		return
+	}
+	changed := apply(c.Request.Context())
+	logger.Warnw("remediator toggled by admin", "outcome", outcome, "incidentKey", incidentKey,
+		"changed", changed, "mode", registry.Mode(), "by", req.By, "reason", req.Reason)
+	audit(trace.SpanFromContext(c.Request.Context()), "admin", incidentKey, Result{
+		Plan:    Plan{Action: "admin", Target: incidentKey, IncidentKey: incidentKey},
+		Outcome: outcome,
+		Actor:   req.actor(),
+	}, nil)
+	c.JSON(http.StatusOK, gin.H{"mode": registry.Mode(), "changed": changed})
+}
+
+// queueHandler lists the work queue: jobs waiting (with their attempts so far) and the
+// dead letters that ran out of them.
+func queueHandler(c *gin.Context) {
+	if workQueue == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no work queue (observe-only)"})
+		return
+	}
+	c.JSON(http.StatusOK, workQueue.Snapshot())
+}
+
+// requeueHandler gives a dead letter another full set of attempts — once whatever made it
+// fail (an expired token, a missing RBAC verb) is fixed.
+func requeueHandler(c *gin.Context) {
+	if workQueue == nil || !acting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "not the acting replica; retry"})
+		return
+	}
+	ok, err := workQueue.Requeue(c.Request.Context(), c.Param("id"))
+	switch {
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+	case !ok:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no such dead letter"})
+	default:
+		logger.Warnw("dead-lettered job requeued by admin", "id", c.Param("id"))
+		c.JSON(http.StatusOK, gin.H{"requeued": c.Param("id")})
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// adminRouter serves the mode endpoints and /healthz over a registry with a stub action
+// that undoes on resolve, a memory store, and an audit log.
+func adminRouter(t *testing.T) (*gin.Engine, *undoingStub, *MemoryStore) {
+	t.Helper()
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	a := &undoingStub{stubAction{verified: true}}
+	store := &MemoryStore{}
+	registry = NewRegistry(false, time.Minute)
+	registry.Register("stub", a)
+	if err := registry.UseStore(context.Background(), store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	auditLog = testAuditLog(t)
+	t.Cleanup(func() { registry, auditLog = nil, nil })
+
+	router := gin.New()
+	router.GET("/healthz", healthHandler)
+	admin := router.Group("/admin", adminAuth())
+	admin.POST("/pause", pauseHandler)
+	admin.POST("/resume", resumeHandler)
+	admin.POST("/dry-run/:state", dryRunHandler)
+	admin.POST("/cooldowns/reset", cooldownResetHandler)
+	return router, a, store
+}
+
+// healthMode reads the mode /healthz reports.
+func healthMode(t *testing.T, router *gin.Engine) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	var body map[string]string
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("healthz body not JSON: %v", err)
+	}
+	return body["mode"]
+}
+
+func TestAdmin_PauseStopsActionsAndUndosUntilResume(t *testing.T) {
+	router, a, store := adminRouter(t)
+	ctx := context.Background()
+
+	if w := postAction(router, "/admin/pause", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated pause status = %d, want 401", w.Code)
+	}
+	if _, err := registry.Run(ctx, stubAlert()); err != nil { // acted before the pause
+		t.Fatalf("Run: %v", err)
+	}
+	if w := postAction(router, "/admin/pause", "s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("pause status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+	if got := healthMode(t, router); got != modePaused {
+		t.Errorf("healthz mode = %q, want paused", got)
+	}
+	if st, _ := store.Load(ctx); !st.Paused {
+		t.Error("pause not persisted; a restart would resume")
+	}
+
+	other := stubAlert()
+	other.Labels["service"] = "checkout"
+	if res, err := registry.Run(ctx, other); err != nil || res.Outcome != OutcomePaused || a.executed != 1 {
+		t.Errorf("Run while paused = (%s, %v), executed %d; want paused and nothing executed", res.Outcome, err, a.executed)
+	}
+	resolved := stubAlert()
+	resolved.Status = "resolved"
+	if res, _, err := registry.Resolve(ctx, resolved); err != nil || res.Outcome != OutcomeRestorePending {
+		t.Errorf("Resolve while paused = (%s, %v), want restore_pending", res.Outcome, err)
+	}
+	if restores := registry.RestoreDue(ctx); len(restores) != 0 {
+		t.Errorf("RestoreDue while paused = %+v, want nothing undone", restores)
+	}
+
+	if w := postAction(router, "/admin/resume", "s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("resume status = %d, want 200", w.Code)
+	}
+	if got, _ := auditLog.Query(AuditFilter{Outcome: string(OutcomeRestored)}); len(got) != 1 || registry.Soak != 0 {
+		t.Errorf("restores recorded by resume = %+v, want the undo held by the pause even with no soak", got)
+	}
+
+	got, _ := auditLog.Query(AuditFilter{Action: "admin"})
+	if len(got) != 2 || got[0].Outcome != "paused" || got[1].Outcome != "resumed" || got[0].Actor != "admin:oncall" {
+		t.Errorf("audit = %+v, want paused then resumed by admin:oncall", got)
+	}
+}
+
+func TestAdmin_DryRunToggle(t *testing.T) {
+	router, a, _ := adminRouter(t)
+
+	if w := postAction(router, "/admin/dry-run/on", "s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("dry-run on status = %d, want 200", w.Code)
+	}
+	if got := healthMode(t, router); got != modeDryRun {
+		t.Errorf("healthz mode = %q, want dry_run", got)
+	}
+	if res, _ := registry.Run(context.Background(), stubAlert()); res.Outcome != OutcomeDryRun || a.executed != 0 {
+		t.Errorf("Run in dry-run = %s (executed %d), want dry_run", res.Outcome, a.executed)
+	}
+
+	w := postAction(router, "/admin/dry-run/off", "s3cret")
+	var body struct {
+		Mode    string
+		Changed bool
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusOK || body.Mode != modeActive || !body.Changed {
+		t.Errorf("dry-run off = %d %+v, want active and changed", w.Code, body)
+	}
+	if w := postAction(router, "/admin/dry-run/maybe", "s3cret"); w.Code != http.StatusNotFound {
+		t.Errorf("dry-run/maybe status = %d, want 404", w.Code)
+	}
+	if got, _ := auditLog.Query(AuditFilter{Action: "admin"}); len(got) != 2 || got[1].Outcome != "dry_run_off" {
+		t.Errorf("audit = %+v, want dry_run_on then dry_run_off", got)
+	}
+}
+
+func TestAdmin_CooldownReset(t *testing.T) {
+	router, a, _ := adminRouter(t)
+	ctx := context.Background()
+	if _, err := registry.Run(ctx, stubAlert()); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if res, _ := registry.Run(ctx, stubAlert()); res.Outcome != OutcomeCooldown {
+		t.Fatalf("second Run = %s, want cooldown", res.Outcome)
+	}
+
+	if w := postAction(router, "/admin/cooldowns/reset", "s3cret"); w.Code != http.StatusBadRequest {
+		t.Errorf("reset without incidentKey status = %d, want 400", w.Code)
+	}
+	if w := postAction(router, "/admin/cooldowns/reset?incidentKey=HighLatency%7Ccart", "s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("reset status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+	if res, _ := registry.Run(ctx, stubAlert()); res.Outcome != "stubbed" || a.executed != 2 {
+		t.Errorf("Run after reset = %s (executed %d), want acted again", res.Outcome, a.executed)
+	}
+	got, _ := auditLog.Query(AuditFilter{Service: "cart", Action: "admin"})
+	if len(got) != 1 || got[0].Outcome != "cooldown_reset" || got[0].Target != "HighLatency|cart" {
+		t.Errorf("audit = %+v, want the cooldown reset for the incident", got)
+	}
+}
+
+func TestAdmin_FollowerRefusesToggles(t *testing.T) {
+	router, _, _ := adminRouter(t)
+	followerOf(t, "")
+	if w := postAction(router, "/admin/pause", "s3cret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("pause on a follower status = %d, want 503", w.Code)
+	}
+	if registry.Mode() != modeActive {
+		t.Errorf("mode = %s, want a follower's pause to change nothing", registry.Mode())
+	}
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// approvals holds actions the policy wants a human to approve. Nil when observe-only.
+var approvals *ApprovalQueue
+
+// pendingApprovals: "is anything waiting on a human?"
+var pendingApprovals = prometheus.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Name: "remediator_pending_approvals",
+		Help: "Planned actions waiting for a human to approve or deny them.",
+	},
+	func() float64 {
+		if approvals == nil {
+			return 0
+		}
+		return float64(len(approvals.List()))
+	},
+)
+
+func init() { prometheus.MustRegister(pendingApprovals) }
+
+// PendingAction is a planned action waiting for a human: everything needed to judge it
+// (the alert, the plan, the evidence at the time) and to run it if approved.
+type PendingAction struct {
+	ID        string            `json:"id"`
+	Alert     Alert             `json:"alert"`
+	Plan      Plan              `json:"plan"`
+	Rule      string            `json:"rule"`     // the policy rule that asked for approval
+	Evidence  map[string]string `json:"evidence"` // e.g. the error ratio when it was queued
+	CreatedAt time.Time         `json:"createdAt"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// errApprovalNotFound is ApprovalQueue.Take's only failure: each action is decided once.
+var errApprovalNotFound = errors.New("no pending action with that id (decided, expired or never queued)")
+
+// ApprovalQueue holds pending actions, at most one per incident, until they are approved,
+// denied or expire after TTL. It lives in the leader's memory: after a restart the alert,
+// still firing, simply queues its action again.
+type ApprovalQueue struct {
+	TTL time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*PendingAction // id -> action
+	now     func() time.Time
+}
+
+// NewApprovalQueue builds a queue whose actions expire after ttl.
+func NewApprovalQueue(ttl time.Duration) *ApprovalQueue {
+	return &ApprovalQueue{TTL: ttl, pending: map[string]*PendingAction{}, now: time.Now}
+}
+
+// Add queues the plan in res for a human, unless one is already pending for the same
+// incident; it returns the pending action and whether it was newly queued.
+func (q *ApprovalQueue) Add(alert Alert, res Result, evidence map[string]string) (*PendingAction, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, p := range q.pending {
+		if p.Plan.IncidentKey == res.Plan.IncidentKey {
+			return p, false
+		}
+	}
+	id := make([]byte, 8)
+	_, _ = rand.Read(id)
+	now := q.now()
+	p := &PendingAction{
+		ID:        hex.EncodeToString(id),
+		Alert:     alert,
+		Plan:      res.Plan,
+		Rule:      res.Rule,
+		Evidence:  evidence,
+		CreatedAt: now,
+		ExpiresAt: now.Add(q.TTL),
+	}
+	q.pending[p.ID] = p
+	return p, true
+}
+
+// Take removes and returns a pending action for a decision. Expired actions can't be taken.
+func (q *ApprovalQueue) Take(id string) (*PendingAction, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	p, ok := q.pending[id]
+	if !ok || !q.now().Before(p.ExpiresAt) {
+		return nil, errApprovalNotFound
+	}
+	delete(q.pending, id)
+	return p, nil
+}
+
+// Expire removes and returns every action nobody answered in time.
+func (q *ApprovalQueue) Expire() []*PendingAction {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var out []*PendingAction
+	for id, p := range q.pending {
+		if !q.now().Before(p.ExpiresAt) {
+			out = append(out, p)
+			delete(q.pending, id)
+		}
+	}
+	return out
+}
+
+// List returns the pending actions, oldest first.
+func (q *ApprovalQueue) List() []*PendingAction {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	out := make([]*PendingAction, 0, len(q.pending))
+	for _, p := range q.pending {
+		out = append(out, p)
+	}
+	slices.SortFunc(out, func(a, b *PendingAction) int { return a.CreatedAt.Compare(b.CreatedAt) })
+	return out
+}
+
+// queueApproval puts an action the policy wants approved in front of a human, recording
+// it once per incident (Alertmanager repeats a firing alert until it resolves).
+func queueApproval(ctx context.Context, span trace.Span, name string, alert Alert, res Result) {
+	if approvals == nil {
+		recordAction(span, name, alert.incidentKey(), res, nil)
+		return
+	}
+	p, created := approvals.Add(alert, res, approvalEvidence(ctx, alert))
+	if !created {
+		return
+	}
+	res.Plan.Params = withParam(res.Plan.Params, "approvalID", p.ID)
+	recordAction(span, name, alert.incidentKey(), res, nil)
+	logger.Warnw("action awaiting approval", "id", p.ID, "action", name, "target", res.Plan.Target,
+		"rule", res.Rule, "expires_at", p.ExpiresAt)
+}
+
+// approvalEvidence is what the approver sees next to the plan: the alert's own summary and,
+// when Prometheus is configured, the service's error ratio at the time.
+func approvalEvidence(ctx context.Context, alert Alert) map[string]string {
+	ev := map[string]string{"summary": alert.Annotations["summary"], "startsAt": alert.StartsAt.Format(time.RFC3339)}
+	if verifier != nil {
+		if ratio, ok := verifier.prom.ErrorRatio(ctx, alert.Labels["service"]); ok {
+			ev["errorRatio"] = strconv.FormatFloat(ratio, 'f', 4, 64)
+		}
+	}
+	return ev
+}
+
+// withParam returns a copy of params with key set, leaving the plan's own map untouched.
+func withParam(params map[string]string, key, value string) map[string]string {
+	out := maps.Clone(params)
+	if out == nil {
+		out = map[string]string{}
+	}
+	out[key] = value
+	return out
+}
+
+// approvalRequest is the optional body of an approve/deny call, for the audit trail.
+type approvalRequest struct {
+	By     string `json:"by"`
+	Reason string `json:"reason"`
+}
+
+// actor names the approver in the audit log; anonymous decisions are still marked human.
+func (r approvalRequest) actor() string {
+	if r.By == "" {
+		return "approver"
+	}
+	return "approver:" + r.By
+}
+
+// listApprovalsHandler lists the actions waiting for a decision.
+func listApprovalsHandler(c *gin.Context) {
+	if approvals == nil {
+		c.JSON(http.StatusOK, gin.H{"pending": []*PendingAction{}})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"pending": approvals.List()})
+}
+
+// approveHandler runs a pending action through the registry (cooldown, dry-run, budget,
+// verify) and the same accounting and RCA path as an unattended one.
+func approveHandler(c *gin.Context) {
+	p, req, ok := takePending(c)
+	if !ok {
+		return
+	}
+	span := trace.SpanFromContext(c.Request.Context())
+	res, err := registry.RunApproved(c.Request.Context(), p.Alert, p.Plan, p.Rule)
+	res.Actor = req.actor()
+	logger.Infow("pending action approved", "id", p.ID, "by", req.By, "reason", req.Reason)
+	recordAction(span, p.Plan.Action, p.Alert.incidentKey(), res, err)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"id": p.ID, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": p.ID, "outcome": res.Outcome})
+}
+
+// denyHandler drops a pending action and records the refusal.
+func denyHandler(c *gin.Context) {
+	p, req, ok := takePending(c)
+	if !ok {
+		return
+	}
+	span := trace.SpanFromContext(c.Request.Context())
+	logger.Infow("pending action denied", "id", p.ID, "by", req.By, "reason", req.Reason)
+	// A denial is a decision for this incident: start its cooldown, so the still-firing
+	// alert doesn't queue the same action again on Alertmanager's next repeat.
+	registry.markActed(c.Request.Context(), p.Plan.IncidentKey)
+	recordAction(span, p.Plan.Action, p.Plan.IncidentKey,
+		Result{Plan: p.Plan, Outcome: OutcomeApprovalDenied, Rule: p.Rule, Actor: req.actor()}, nil)
+	c.JSON(http.StatusOK, gin.H{"id": p.ID, "outcome": OutcomeApprovalDenied})
+}
+
+// takePending is the shared front half of approve/deny: only the acting replica holds
+// the queue, and each pending action can be decided once.
+func takePending(c *gin.Context) (*PendingAction, approvalRequest, bool) {
+	var req approvalRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid body: " + err.Error()})
+			return nil, req, false
+		}
+	}
+	if registry == nil || approvals == nil || !acting() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "not the acting replica; retry"})
+		return nil, req, false
+	}
+	p, err := approvals.Take(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return nil, req, false
+	}
+	return p, req, true
+}
+
+// approvalLoop periodically expires pending actions nobody answered, recording each.
+func approvalLoop(ctx context.Context, every time.Duration) {
+	tracer := otel.Tracer("remediator")
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, p := range approvals.Expire() {
+			_, span := tracer.Start(ctx, "approval-expired")
+			recordAction(span, p.Plan.Action, p.Plan.IncidentKey,
+				Result{Plan: p.Plan, Outcome: OutcomeApprovalExpired, Rule: p.Rule}, nil)
+			span.End()
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestApprovalQueue(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	q := NewApprovalQueue(30 * time.Minute)
+	q.now = func() time.Time { return now }
+	res := Result{Plan: Plan{Action: "stub", Target: "cart", IncidentKey: "HighLatency|cart"}, Rule: "r"}
+
+	p, created := q.Add(stubAlert(), res, nil)
+	if !created {
+		t.Fatal("first Add did not queue")
+	}
+	if again, created := q.Add(stubAlert(), res, nil); created || again.ID != p.ID {
+		t.Errorf("repeat Add = (%s, %v), want the existing pending action", again.ID, created)
+	}
+
+	if _, err := q.Take(p.ID); err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	if _, err := q.Take(p.ID); err == nil {
+		t.Error("second Take succeeded, want each action decided once")
+	}
+
+	p, _ = q.Add(stubAlert(), res, nil)
+	now = now.Add(31 * time.Minute)
+	if _, err := q.Take(p.ID); err == nil {
+		t.Error("Take of an expired action succeeded")
+	}
+	if expired := q.Expire(); len(expired) != 1 || expired[0].ID != p.ID {
+		t.Errorf("Expire = %v, want the unanswered action", expired)
+	}
+	if len(q.List()) != 0 {
+		t.Error("queue not empty after Expire")
+	}
+}
+
+func TestRegistry_ApprovePolicyQueuesInsteadOfExecuting(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+	p, err := ParsePolicy([]byte("rules:\n  - name: stub-needs-a-human\n    effect: approve\n    actions: [stub]"))
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	if err := r.UsePolicy(p); err != nil {
+		t.Fatalf("UsePolicy: %v", err)
+	}
+
+	res, err := r.Run(context.Background(), stubAlert())
+	if err != nil || res.Outcome != OutcomePendingApproval || res.Rule != "stub-needs-a-human" || a.executed != 0 {
+		t.Fatalf("Run = (%+v, %v), executed %d; want pending_approval without executing", res, err, a.executed)
+	}
+
+	res, err = r.RunApproved(context.Background(), stubAlert(), res.Plan, res.Rule)
+	if err != nil || !res.Executed || a.executed != 1 {
+		t.Errorf("RunApproved = (%+v, %v), executed %d; want executed once", res, err, a.executed)
+	}
+}
+
+func TestRegistry_RunApprovedRefusesChangedTarget(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+	approved := Plan{Action: "stub", Target: "checkout", IncidentKey: "HighLatency|cart"}
+
+	if _, err := r.RunApproved(context.Background(), stubAlert(), approved, "r"); err == nil || a.executed != 0 {
+		t.Errorf("RunApproved with a changed target: err = %v, executed %d; want refused", err, a.executed)
+	}
+}
+
+// approvalRouter serves the approval endpoints over a registry whose policy requires
+// approval for the stub action, with one action already queued.
+func approvalRouter(t *testing.T) (*gin.Engine, *stubAction, string) {
+	t.Helper()
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	a := &stubAction{verified: true}
+	registry = NewRegistry(false, time.Minute)
+	registry.Register("stub", a)
+	approvals = NewApprovalQueue(time.Hour)
+	t.Cleanup(func() { registry, approvals = nil, nil })
+
+	res := Result{Plan: Plan{Action: "stub", Target: "cart", IncidentKey: "HighLatency|cart"}, Rule: "r"}
+	p, _ := approvals.Add(stubAlert(), res, nil)
+
+	router := gin.New()
+	g := router.Group("/actions", adminAuth())
+	g.GET("", listApprovalsHandler)
+	g.POST("/:id/approve", approveHandler)
+	g.POST("/:id/deny", denyHandler)
+	return router, a, p.ID
+}
+
+func postAction(router *gin.Engine, path, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"by":"oncall","reason":"looks right"}`))
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestApproveHandler(t *testing.T) {
+	router, a, id := approvalRouter(t)
+
+	if w := postAction(router, "/actions/"+id+"/approve", ""); w.Code != http.StatusUnauthorized {
+		t.Fatalf("unauthenticated approve status = %d, want 401", w.Code)
+	}
+
+	w := postAction(router, "/actions/"+id+"/approve", "s3cret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("approve status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+	var body struct{ Outcome Outcome }
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || body.Outcome != "stubbed" || a.executed != 1 {
+		t.Errorf("approve body = %s, executed %d; want the action run once", w.Body.String(), a.executed)
+	}
+
+	if w := postAction(router, "/actions/"+id+"/approve", "s3cret"); w.Code != http.StatusNotFound {
+		t.Errorf("second approve status = %d, want 404", w.Code)
+	}
+}
+
+func TestDenyHandler(t *testing.T) {
+	router, a, id := approvalRouter(t)
+
+	if w := postAction(router, "/actions/"+id+"/deny", "s3cret"); w.Code != http.StatusOK {
+		t.Fatalf("deny status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+	if a.executed != 0 || len(approvals.List()) != 0 {
+		t.Errorf("after deny: executed %d, pending %d; want neither", a.executed, len(approvals.List()))
+	}
+	if !registry.cooling("HighLatency|cart") {
+		t.Error("denied incident not cooling; the next repeat would queue it again")
+	}
+}
+
+func TestApproveHandler_FollowerRefuses(t *testing.T) {
+	router, a, id := approvalRouter(t)
+	followerOf(t, "127.0.0.1:1")
+
+	if w := postAction(router, "/actions/"+id+"/approve", "s3cret"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("follower approve status = %d, want 503", w.Code)
+	}
+	if a.executed != 0 || len(approvals.List()) != 1 {
+		t.Error("follower consumed or ran the pending action")
+	}
+}
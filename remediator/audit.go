@@ -0,0 +1,348 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// auditLog is the durable record of every decision. Nil (no AUDIT_LOG_PATH) means the
+// counter and logs are the only record.
+var auditLog *AuditLog
+
+// auditWriteErrors: "is the audit trail complete?" — should always be 0.
+var auditWriteErrors = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "remediator_audit_write_errors_total",
+	Help: "Remediation decisions that could not be written to the audit log.",
+})
+
+func init() { prometheus.MustRegister(auditWriteErrors) }
+
+// AuditRecord is one remediation decision as written to the audit log. Hash covers every
+// other field plus PrevHash, chaining each record to the one before it: editing, dropping
+// or reordering a record breaks the chain from that point on.
+type AuditRecord struct {
+	Seq         int64             `json:"seq"`
+	Time        time.Time         `json:"time"`
+	IncidentKey string            `json:"incidentKey"`
+	Alertname   string            `json:"alertname"`
+	Service     string            `json:"service"`
+	Action      string            `json:"action"`
+	Target      string            `json:"target"`
+	Outcome     string            `json:"outcome"`
+	Rule        string            `json:"rule,omitempty"`
+	Actor       string            `json:"actor"`            // "remediator", or the human who approved/denied
+	Before      string            `json:"before,omitempty"` // target state before the change, e.g. the flag's variant
+	After       string            `json:"after,omitempty"`  // target state after it
+	Params      map[string]string `json:"params,omitempty"`
+	Error       string            `json:"error,omitempty"`
+	TraceID     string            `json:"traceId,omitempty"`
+	PrevHash    string            `json:"prevHash"`
+	Hash        string            `json:"hash"`
+}
+
+// changeDescriber is implemented by actions that can say what their plan changes, for the
+// audit log's before/after: the state the target is in before Execute, and after it.
+type changeDescriber interface {
+	Change(p Plan) (before, after string)
+}
+
+// AuditLog appends records to a JSONL file — on a PersistentVolume in the cluster — and
+// never rewrites it. Each append is synced before the decision counts as recorded.
+//
+// The volume is ReadWriteMany and every replica opens the same file: a follower audits
+// the webhooks it rejects, and a new leader keeps writing after the old one. So nothing
+// about the chain is cached; each append takes an exclusive flock and chains onto the
+// record that is actually last in the file.
+type AuditLog struct {
+	path string
+
+	mu  sync.Mutex
+	f   *os.File
+	now func() time.Time
+}
+
+// OpenAuditLog opens (or creates) the log at path and checks the chain it already holds.
+// A chain that doesn't verify is reported but not repaired: the log is evidence, and the
+// break itself is what an auditor needs to see.
+func OpenAuditLog(path string) (*AuditLog, error) {
+	l := &AuditLog{path: path, now: time.Now}
+	records, err := l.read()
+	if err != nil {
+		return nil, err
+	}
+	if i, err := VerifyChain(records); err != nil {
+		logger.Errorw("audit log hash chain is broken", "path", path, "record", i, "error", err)
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	l.f = f
+	return l, nil
+}
+
+// Append chains rec onto the log and writes it, filling Seq, Time, PrevHash and Hash.
+func (l *AuditLog) Append(rec AuditRecord) (AuditRecord, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := syscall.Flock(int(l.f.Fd()), syscall.LOCK_EX); err != nil {
+		return rec, fmt.Errorf("lock audit log: %w", err)
+	}
+	defer syscall.Flock(int(l.f.Fd()), syscall.LOCK_UN)
+
+	last, err := l.tail()
+	if err != nil {
+		return rec, err
+	}
+	rec.Seq, rec.PrevHash = last.Seq+1, last.Hash
+	if rec.Time.IsZero() {
+		rec.Time = l.now().UTC()
+	}
+	rec.Hash = rec.hash()
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return rec, fmt.Errorf("marshal audit record: %w", err)
+	}
+	if _, err := l.f.Write(append(line, '\n')); err != nil {
+		return rec, fmt.Errorf("write audit log: %w", err)
+	}
+	if err := l.f.Sync(); err != nil {
+		return rec, fmt.Errorf("sync audit log: %w", err)
+	}
+	return rec, nil
+}
+
+// tail reads the last record in the file, or the zero record when it is empty. It reads
+// backwards from the end, so an append costs one record, not the whole log.
+func (l *AuditLog) tail() (AuditRecord, error) {
+	var last AuditRecord
+	fi, err := l.f.Stat()
+	if err != nil {
+		return last, fmt.Errorf("stat audit log: %w", err)
+	}
+	end := fi.Size()
+	var buf []byte
+	for chunk := int64(4096); ; chunk *= 2 {
+		start := max(end-chunk, 0)
+		buf = make([]byte, end-start)
+		if _, err := l.f.ReadAt(buf, start); err != nil && !errors.Is(err, io.EOF) {
+			return last, fmt.Errorf("read audit log: %w", err)
+		}
+		trimmed := bytes.TrimRight(buf, "\n")
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 || start == 0 {
+			buf = trimmed[i+1:]
+			break
+		}
+	}
+	if len(buf) == 0 {
+		return last, nil
+	}
+	if err := json.Unmarshal(buf, &last); err != nil {
+		return last, fmt.Errorf("audit log last record: %w", err)
+	}
+	return last, nil
+}
+
+// AuditFilter selects records for a query. Zero fields don't filter.
+type AuditFilter struct {
+	Since, Until time.Time
+	Service      string
+	Outcome      string
+	Action       string
+	Limit        int // newest N matching records; 0 = all
+}
+
+// Query returns the records matching f, oldest first.
+func (l *AuditLog) Query(f AuditFilter) ([]AuditRecord, error) {
+	l.mu.Lock()
+	records, err := l.read()
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	out := []AuditRecord{}
+	for _, r := range records {
+		if (!f.Since.IsZero() && r.Time.Before(f.Since)) || (!f.Until.IsZero() && !r.Time.Before(f.Until)) ||
+			(f.Service != "" && r.Service != f.Service) || (f.Outcome != "" && r.Outcome != f.Outcome) ||
+			(f.Action != "" && r.Action != f.Action) {
+			continue
+		}
+		out = append(out, r)
+	}
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[len(out)-f.Limit:]
+	}
+	return out, nil
+}
+
+// Verify re-reads the whole log and checks its hash chain.
+func (l *AuditLog) Verify() (int, error) {
+	l.mu.Lock()
+	records, err := l.read()
+	l.mu.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	return VerifyChain(records)
+}
+
+// VerifyChain checks each record's hash and its link to the previous one. It returns the
+// number of records checked, or the index of the first bad record with the error.
+func VerifyChain(records []AuditRecord) (int, error) {
+	prev := ""
+	for i, r := range records {
+		if r.PrevHash != prev {
+			return i, fmt.Errorf("record seq %d: prevHash doesn't match the record before it", r.Seq)
+		}
+		if r.hash() != r.Hash {
+			return i, fmt.Errorf("record seq %d: contents don't match its hash", r.Seq)
+		}
+		prev = r.Hash
+	}
+	return len(records), nil
+}
+
+// hash is the SHA-256 of the record's JSON with Hash cleared; PrevHash is inside it.
+func (r AuditRecord) hash() string {
+	r.Hash = ""
+	raw, _ := json.Marshal(r)
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// read parses every record in the log; a missing file is an empty log.
+func (l *AuditLog) read() ([]AuditRecord, error) {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("open audit log: %w", err)
+	}
+	defer f.Close()
+	var out []AuditRecord
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var rec AuditRecord
+			if jerr := json.Unmarshal(line, &rec); jerr != nil {
+				return nil, fmt.Errorf("audit log line %d: %w", n, jerr)
+			}
+			out = append(out, rec)
+		}
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read audit log: %w", err)
+		}
+	}
+}
+
+// audit writes one decision to the audit log, when there is one. The alertname and
+// service come from the incident key, which is built from exactly those two.
+func audit(span trace.Span, name, incidentKey string, res Result, err error) {
+	if auditLog == nil {
+		return
+	}
+	alertname, service, _ := strings.Cut(incidentKey, "|")
+	rec := AuditRecord{
+		IncidentKey: incidentKey,
+		Alertname:   alertname,
+		Service:     service,
+		Action:      name,
+		Target:      res.Plan.Target,
+		Outcome:     string(res.Outcome),
+		Rule:        res.Rule,
+		Actor:       res.Actor,
+		Params:      res.Plan.Params,
+	}
+	if rec.Actor == "" {
+		rec.Actor = "remediator"
+	}
+	if err != nil {
+		rec.Outcome, rec.Error = "error", err.Error()
+	}
+	if sc := span.SpanContext(); sc.HasTraceID() {
+		rec.TraceID = sc.TraceID().String()
+	}
+	if d, ok := registryAction(name).(changeDescriber); ok && res.Plan.Noop == "" && err == nil {
+		rec.Before, rec.After = d.Change(res.Plan)
+		if res.Outcome == OutcomeRestored || res.Outcome == OutcomeScaledDown {
+			rec.Before, rec.After = rec.After, rec.Before // an undo runs the change backwards
+		}
+	}
+	if _, werr := auditLog.Append(rec); werr != nil {
+		logger.Errorw("audit write failed", "incident_key", incidentKey, "error", werr)
+		auditWriteErrors.Inc()
+	}
+}
+
+// registryAction looks an action up by name, or returns nil.
+func registryAction(name string) Action {
+	if registry == nil {
+		return nil
+	}
+	return registry.actions[name]
+}
+
+// auditHandler serves GET /audit?since=&until=&service=&outcome=&action=&limit=, with
+// since/until as RFC 3339 timestamps. ?verify=true also checks the hash chain.
+func auditHandler(c *gin.Context) {
+	if auditLog == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "audit log disabled (no AUDIT_LOG_PATH)"})
+		return
+	}
+	f := AuditFilter{Service: c.Query("service"), Outcome: c.Query("outcome"), Action: c.Query("action")}
+	for param, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be RFC 3339"})
+				return
+			}
+			*dst = t
+		}
+	}
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+			return
+		}
+		f.Limit = n
+	}
+
+	records, err := auditLog.Query(f)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	body := gin.H{"records": records}
+	if c.Query("verify") == "true" {
+		n, err := auditLog.Verify()
+		body["chain"] = gin.H{"valid": err == nil, "checked": n}
+		if err != nil {
+			body["chain"] = gin.H{"valid": false, "firstBadRecord": n, "error": err.Error()}
+		}
+	}
+	c.JSON(http.StatusOK, body)
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// testAuditLog opens a log in a temp dir on a clock that ticks a minute per record.
+func testAuditLog(t *testing.T) *AuditLog {
+	t.Helper()
+	l, err := OpenAuditLog(filepath.Join(t.TempDir(), "audit.jsonl"))
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	t.Cleanup(func() { l.f.Close() })
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { now = now.Add(time.Minute); return now }
+	return l
+}
+
+func seedAudit(t *testing.T, l *AuditLog) {
+	t.Helper()
+	for _, rec := range []AuditRecord{
+		{IncidentKey: "HighErrors|cart", Service: "cart", Action: actionFlagd, Outcome: "disabled"},
+		{IncidentKey: "HighErrors|cart", Service: "cart", Action: actionFlagd, Outcome: "cooldown"},
+		{IncidentKey: "HighLatency|api", Service: "api", Action: actionScale, Outcome: "scaled_up"},
+	} {
+		if _, err := l.Append(rec); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+}
+
+func TestAuditLog_Query(t *testing.T) {
+	l := testAuditLog(t)
+	seedAudit(t, l)
+
+	tests := []struct {
+		name    string
+		filter  AuditFilter
+		wantSeq []int64
+	}{
+		{"all", AuditFilter{}, []int64{1, 2, 3}},
+		{"by service", AuditFilter{Service: "cart"}, []int64{1, 2}},
+		{"by outcome", AuditFilter{Outcome: "scaled_up"}, []int64{3}},
+		{"by action", AuditFilter{Action: actionFlagd}, []int64{1, 2}},
+		{"since", AuditFilter{Since: time.Date(2026, 5, 1, 12, 2, 0, 0, time.UTC)}, []int64{2, 3}},
+		{"until", AuditFilter{Until: time.Date(2026, 5, 1, 12, 2, 0, 0, time.UTC)}, []int64{1}},
+		{"newest N", AuditFilter{Limit: 2}, []int64{2, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := l.Query(tt.filter)
+			if err != nil {
+				t.Fatalf("Query: %v", err)
+			}
+			var seqs []int64
+			for _, r := range got {
+				seqs = append(seqs, r.Seq)
+			}
+			if !slices.Equal(seqs, tt.wantSeq) {
+				t.Errorf("Query seqs = %v, want %v", seqs, tt.wantSeq)
+			}
+		})
+	}
+}
+
+func TestAuditLog_ChainSurvivesReopenAndDetectsTampering(t *testing.T) {
+	l := testAuditLog(t)
+	seedAudit(t, l)
+	l.f.Close()
+
+	reopened, err := OpenAuditLog(l.path)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer reopened.f.Close()
+	rec, err := reopened.Append(AuditRecord{IncidentKey: "HighErrors|cart", Outcome: "restored"})
+	if err != nil {
+		t.Fatalf("Append after reopen: %v", err)
+	}
+	if rec.Seq != 4 {
+		t.Errorf("seq after reopen = %d, want 4", rec.Seq)
+	}
+	if n, err := reopened.Verify(); err != nil || n != 4 {
+		t.Fatalf("Verify = (%d, %v), want 4 valid records", n, err)
+	}
+
+	raw, _ := os.ReadFile(l.path)
+	tampered := strings.Replace(string(raw), `"outcome":"scaled_up"`, `"outcome":"already_stable"`, 1)
+	if err := os.WriteFile(l.path, []byte(tampered), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := reopened.Verify(); err == nil || n != 2 {
+		t.Errorf("Verify after tampering = (%d, %v), want the third record (index 2) flagged", n, err)
+	}
+}
+
+func TestAuditLog_ReplicasSharingTheFileKeepOneChain(t *testing.T) {
+	leader := testAuditLog(t)
+	follower, err := OpenAuditLog(leader.path)
+	if err != nil {
+		t.Fatalf("OpenAuditLog: %v", err)
+	}
+	t.Cleanup(func() { follower.f.Close() })
+
+	// A follower auditing rejected webhooks while the leader records actions.
+	var wg sync.WaitGroup
+	for _, l := range []*AuditLog{leader, follower, leader, follower} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 10 {
+				if _, err := l.Append(AuditRecord{IncidentKey: "HighErrors|cart", Outcome: "rejected"}); err != nil {
+					t.Errorf("Append: %v", err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	records, err := leader.Query(AuditFilter{})
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	for i, r := range records {
+		if r.Seq != int64(i+1) {
+			t.Fatalf("record %d has seq %d, want %d: the replicas forked the sequence", i, r.Seq, i+1)
+		}
+	}
+	if n, err := follower.Verify(); err != nil || n != 40 {
+		t.Errorf("Verify = (%d, %v), want one valid chain of 40 records", n, err)
+	}
+}
+
+func TestRecordAction_WritesAuditRecord(t *testing.T) {
+	auditLog = testAuditLog(t)
+	registry = NewRegistry(false, time.Minute)
+	registry.Register(actionFlagd, NewFlagRemediator(nil, "otel-demo", "flagd-config", "demo.flagd.json"))
+	t.Cleanup(func() { auditLog, registry = nil, nil })
+
+	plan := Plan{Action: actionFlagd, Target: "cartFailure", IncidentKey: "HighErrors|cart",
+		Params: map[string]string{"previousVariant": "on"}}
+	span := noop.Span{}
+	recordAction(span, actionFlagd, plan.IncidentKey, Result{Plan: plan, Outcome: OutcomeDisabled, Executed: true}, nil)
+	recordAction(span, actionFlagd, plan.IncidentKey, Result{Plan: plan, Outcome: OutcomeRestored, Executed: true}, nil)
+	recordAction(span, actionFlagd, plan.IncidentKey,
+		Result{Plan: plan, Outcome: OutcomeApprovalDenied, Actor: "approver:oncall"}, nil)
+
+	got, err := auditLog.Query(AuditFilter{})
+	if err != nil || len(got) != 3 {
+		t.Fatalf("Query = (%d records, %v), want 3", len(got), err)
+	}
+	if r := got[0]; r.Alertname != "HighErrors" || r.Service != "cart" || r.Actor != "remediator" ||
+		r.Before != "defaultVariant=on" || r.After != "defaultVariant=off" {
+		t.Errorf("disable record = %+v, want HighErrors/cart by remediator, on -> off", r)
+	}
+	if r := got[1]; r.Before != "defaultVariant=off" || r.After != "defaultVariant=on" {
+		t.Errorf("restore record before/after = %q -> %q, want off -> on", r.Before, r.After)
+	}
+	if got[2].Actor != "approver:oncall" {
+		t.Errorf("deny record actor = %q, want approver:oncall", got[2].Actor)
+	}
+}
+
+func TestAuditHandler(t *testing.T) {
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	auditLog = testAuditLog(t)
+	t.Cleanup(func() { auditLog = nil })
+	seedAudit(t, auditLog)
+	router := gin.New()
+	router.GET("/audit", adminAuth(), auditHandler)
+
+	get := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/audit"+query, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("?service=cart&outcome=cooldown&verify=true")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	var body struct {
+		Records []AuditRecord
+		Chain   struct{ Valid bool }
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body not JSON: %v", err)
+	}
+	if len(body.Records) != 1 || body.Records[0].Seq != 2 || !body.Chain.Valid {
+		t.Errorf("body = %s, want record 2 and a valid chain", w.Body.String())
+	}
+
+	if w := get("?since=yesterday"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad since status = %d, want 400", w.Code)
+	}
+}
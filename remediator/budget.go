@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/tomjga/OmniObserve/remediator/internal/sink"
+)
+
+// budgetTripped: "is the loop standing down?" — the number of tripped budget scopes.
+var budgetTripped = prometheus.NewGaugeFunc(
+	prometheus.GaugeOpts{
+		Name: "remediator_budget_tripped",
+		Help: "Action-budget scopes (global or per namespace) currently tripped into observe-only.",
+	},
+	func() float64 {
+		if registry == nil || registry.Budget == nil {
+			return 0
+		}
+		return float64(len(registry.Budget.Tripped()))
+	},
+)
+
+func init() { prometheus.MustRegister(budgetTripped) }
+
+// Budget is the loop's global blast-radius limit. Cooldown is per incident, so an alert
+// storm across many services could still have the remediator flip dozens of flags in a
+// minute; the budget caps mutations per sliding window across every incident — overall
+// (Max) and, optionally, per namespace (NamespaceMax). An action that would exceed a limit
+// trips the breaker for that scope: the loop is observe-only there (budget_exhausted)
+// until Reset, or until TripFor has passed.
+type Budget struct {
+	Max          int           // mutations per Window across all namespaces; 0 = no global limit
+	NamespaceMax int           // mutations per Window in any one namespace; 0 = no per-namespace limit
+	Window       time.Duration // sliding window the limits count over
+	TripFor      time.Duration // how long a tripped scope stays tripped; 0 = until Reset
+	// OnTrip is called once each time a scope trips ("" is the global scope), with the
+	// limit it hit — the hook for the loud notification.
+	OnTrip func(scope string, limit int)
+
+	mu      sync.Mutex
+	taken   []budgetEntry
+	tripped map[string]time.Time // scope -> when it tripped
+	now     func() time.Time
+}
+
+type budgetEntry struct {
+	at        time.Time
+	namespace string
+}
+
+// NewBudget builds a budget of max mutations (namespaceMax per namespace) per window.
+func NewBudget(max, namespaceMax int, window, tripFor time.Duration) *Budget {
+	return &Budget{
+		Max:          max,
+		NamespaceMax: namespaceMax,
+		Window:       window,
+		TripFor:      tripFor,
+		tripped:      map[string]time.Time{},
+		now:          time.Now,
+	}
+}
+
+// Take spends one mutation in namespace and reports whether it was allowed. A refused
+// mutation trips the scope whose limit it would exceed; nothing is spent.
+func (b *Budget) Take(namespace string) bool {
+	b.mu.Lock()
+	now := b.now()
+	b.expire(now)
+	if b.isTripped("") || b.isTripped(namespace) {
+		b.mu.Unlock()
+		return false
+	}
+
+	total, inNamespace := 0, 0
+	for _, e := range b.taken {
+		total++
+		if e.namespace == namespace {
+			inNamespace++
+		}
+	}
+	scope, limit := "", 0
+	switch {
+	case b.Max > 0 && total >= b.Max:
+		scope, limit = "", b.Max
+	case b.NamespaceMax > 0 && namespace != "" && inNamespace >= b.NamespaceMax:
+		scope, limit = namespace, b.NamespaceMax
+	default:
+		b.taken = append(b.taken, budgetEntry{at: now, namespace: namespace})
+		b.mu.Unlock()
+		return true
+	}
+	b.tripped[scope] = now
+	onTrip := b.OnTrip
+	b.mu.Unlock()
+
+	if onTrip != nil {
+		onTrip(scope, limit)
+	}
+	return false
+}
+
+// Reset closes the breaker for scope ("" resets every scope) and forgets the mutations
+// counted against it, so the loop doesn't trip again on the next action. It returns the
+// scopes that were tripped.
+func (b *Budget) Reset(scope string) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reset := []string{}
+	for s := range b.tripped {
+		if scope == "" || s == scope {
+			reset = append(reset, s)
+			delete(b.tripped, s)
+		}
+	}
+	if scope == "" {
+		b.taken = nil
+	} else {
+		b.forget(scope)
+	}
+	return reset
+}
+
+// Trips returns when each tripped scope tripped, for the persisted state.
+func (b *Budget) Trips() map[string]time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(b.now())
+	return maps.Clone(b.tripped)
+}
+
+// RestoreTrips replaces the tripped scopes with persisted ones. The mutations behind them
+// aren't persisted: a scope that is tripped refuses everything anyway, and one that isn't
+// starts its window afresh.
+func (b *Budget) RestoreTrips(trips map[string]time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	clear(b.tripped)
+	maps.Copy(b.tripped, trips)
+}
+
+// Tripped lists the scopes currently tripped ("" is the global scope).
+func (b *Budget) Tripped() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire(b.now())
+	out := make([]string, 0, len(b.tripped))
+	for s := range b.tripped {
+		out = append(out, s)
+	}
+	return out
+}
+
+// expire drops mutations older than the window and closes breakers whose TripFor has
+// passed. b.mu must be held.
+func (b *Budget) expire(now time.Time) {
+	keep := b.taken[:0]
+	for _, e := range b.taken {
+		if now.Sub(e.at) < b.Window {
+			keep = append(keep, e)
+		}
+	}
+	b.taken = keep
+	if b.TripFor <= 0 {
+		return
+	}
+	for s, at := range b.tripped {
+		if now.Sub(at) >= b.TripFor {
+			delete(b.tripped, s)
+			if s == "" {
+				b.taken = nil
+			} else {
+				b.forget(s)
+			}
+		}
+	}
+}
+
+// forget drops the mutations counted against one namespace. b.mu must be held.
+func (b *Budget) forget(namespace string) {
+	keep := b.taken[:0]
+	for _, e := range b.taken {
+		if e.namespace != namespace {
+			keep = append(keep, e)
+		}
+	}
+	b.taken = keep
+}
+
+func (b *Budget) isTripped(scope string) bool {
+	_, ok := b.tripped[scope]
+	return ok
+}
+
+// String describes the limits for logs.
+func (b *Budget) String() string {
+	return fmt.Sprintf("%d (%d per namespace) per %s", b.Max, b.NamespaceMax, b.Window)
+}
+
+// initBudget builds the action budget from env, or returns nil (unlimited) when
+// BUDGET_MAX_ACTIONS and BUDGET_NAMESPACE_MAX_ACTIONS are both 0.
+func initBudget() *Budget {
+	b := NewBudget(envInt("BUDGET_MAX_ACTIONS", 5), envInt("BUDGET_NAMESPACE_MAX_ACTIONS", 0),
+		time.Duration(envInt("BUDGET_WINDOW_SECONDS", 600))*time.Second,
+		time.Duration(envInt("BUDGET_TRIP_SECONDS", 1800))*time.Second)
+	if b.Max <= 0 && b.NamespaceMax <= 0 {
+		return nil
+	}
+	b.OnTrip = func(scope string, limit int) { go notifyBudgetTripped(scope, limit) } // never block a webhook on sinks
+	return b
+}
+
+// notifyBudgetTripped is the loud notification for a tripped budget: an error log and a
+// high-priority escalation to every non-corpus sink, since nothing more will be fixed
+// automatically in that scope until a human looks.
+func notifyBudgetTripped(scope string, limit int) {
+	where := "across all namespaces"
+	if scope != "" {
+		where = "in namespace " + scope
+	}
+	b := registry.Budget
+	logger.Errorw("action budget exhausted; loop is observe-only", "scope", scope, "limit", limit,
+		"window", b.Window.String(), "tripFor", b.TripFor.String())
+	if publisher == nil {
+		return
+	}
+	until := "until an operator resets it (POST /admin/budget/reset)"
+	if b.TripFor > 0 {
+		until = fmt.Sprintf("for %s, or until an operator resets it (POST /admin/budget/reset)", b.TripFor)
+	}
+	r := sink.RCA{
+		Title: "[ESCALATION] Remediation budget exhausted " + where,
+		Body: fmt.Sprintf("The remediator has made %d changes %s within %s — its blast-radius "+
+			"budget. It has stopped acting there and stays observe-only %s. Many incidents at "+
+			"once usually means one shared cause: a human should look before the loop resumes.\n",
+			limit, where, b.Window, until),
+		Service:  scope,
+		StartsAt: time.Now(),
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	publishEscalation(ctx, r, "action budget exhausted; escalated", "scope", scope)
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+// testBudget is a budget on a controllable clock that records the scopes it trips.
+func testBudget(max, namespaceMax int, tripFor time.Duration) (*Budget, *time.Time, *[]string) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	var trips []string
+	b := NewBudget(max, namespaceMax, 10*time.Minute, tripFor)
+	b.now = func() time.Time { return now }
+	b.OnTrip = func(scope string, _ int) { trips = append(trips, scope) }
+	return b, &now, &trips
+}
+
+func TestBudget_GlobalLimitTripsEverywhere(t *testing.T) {
+	b, _, trips := testBudget(2, 0, 0)
+	for i, ns := range []string{"shop", "payments"} {
+		if !b.Take(ns) {
+			t.Fatalf("Take #%d refused, want allowed within budget", i+1)
+		}
+	}
+	if b.Take("shop") {
+		t.Fatal("third Take allowed, want budget exhausted")
+	}
+	if b.Take("checkout") {
+		t.Error("tripped global budget allowed a mutation in another namespace")
+	}
+	if !slices.Equal(*trips, []string{""}) {
+		t.Errorf("trips = %q, want one global trip", *trips)
+	}
+}
+
+func TestBudget_NamespaceLimitTripsOnlyThatNamespace(t *testing.T) {
+	b, _, trips := testBudget(0, 1, 0)
+	if !b.Take("shop") {
+		t.Fatal("first Take in shop refused")
+	}
+	if b.Take("shop") {
+		t.Error("second Take in shop allowed, want namespace budget exhausted")
+	}
+	if !b.Take("payments") {
+		t.Error("Take in payments refused; only shop should be tripped")
+	}
+	if !slices.Equal(*trips, []string{"shop"}) {
+		t.Errorf("trips = %q, want [shop]", *trips)
+	}
+}
+
+func TestBudget_WindowSlides(t *testing.T) {
+	b, now, _ := testBudget(1, 0, 0)
+	if !b.Take("shop") {
+		t.Fatal("first Take refused")
+	}
+	*now = now.Add(11 * time.Minute)
+	if !b.Take("shop") {
+		t.Error("Take after the window refused, want the old mutation to have aged out")
+	}
+}
+
+func TestBudget_TripExpiresAfterTripFor(t *testing.T) {
+	b, now, _ := testBudget(1, 0, 30*time.Minute)
+	b.Take("shop")
+	b.Take("shop") // trips
+
+	*now = now.Add(20 * time.Minute)
+	if b.Take("shop") || len(b.Tripped()) != 1 {
+		t.Fatal("budget closed before TripFor elapsed")
+	}
+	*now = now.Add(11 * time.Minute)
+	if len(b.Tripped()) != 0 || !b.Take("shop") {
+		t.Error("budget still tripped after TripFor, want it closed")
+	}
+}
+
+func TestBudget_Reset(t *testing.T) {
+	b, _, _ := testBudget(1, 0, 0) // TripFor 0: only Reset closes it
+	b.Take("shop")
+	b.Take("shop")
+	if got := b.Reset(""); !slices.Equal(got, []string{""}) {
+		t.Errorf("Reset = %q, want the global scope", got)
+	}
+	if !b.Take("shop") {
+		t.Error("Take after Reset refused, want a fresh budget")
+	}
+}
+
+func TestRegistry_BudgetExhaustedSkipsExecute(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+	r.Budget, _, _ = testBudget(1, 0, 0)
+
+	if _, err := r.Run(context.Background(), stubAlert()); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	other := stubAlert()
+	other.Labels = map[string]string{"alertname": "HighLatency", "service": "checkout"}
+	res, err := r.Run(context.Background(), other)
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if res.Outcome != OutcomeBudgetExhausted || res.Executed || a.executed != 1 {
+		t.Errorf("second run = %+v (executed %d), want budget_exhausted without executing", res, a.executed)
+	}
+}
+
+func TestRegistry_BudgetTripSurvivesRestart(t *testing.T) {
+	store := &MemoryStore{}
+	newRegistry := func() *Registry {
+		r := NewRegistry(false, time.Minute)
+		r.Register("stub", &stubAction{verified: true})
+		r.Budget, _, _ = testBudget(1, 0, 0)
+		if err := r.UseStore(context.Background(), store); err != nil {
+			t.Fatalf("UseStore: %v", err)
+		}
+		return r
+	}
+	r := newRegistry()
+	other := stubAlert()
+	other.Labels = map[string]string{"alertname": "HighLatency", "service": "checkout"}
+	r.Run(context.Background(), stubAlert())
+	if res, _ := r.Run(context.Background(), other); res.Outcome != OutcomeBudgetExhausted {
+		t.Fatalf("second run = %s, want budget_exhausted", res.Outcome)
+	}
+
+	r = newRegistry() // restart, or a new leader
+	if got := r.Budget.Tripped(); !slices.Equal(got, []string{""}) {
+		t.Fatalf("tripped after restart = %q, want the global scope still tripped", got)
+	}
+	r.ResetBudget(context.Background(), "")
+	if r = newRegistry(); len(r.Budget.Tripped()) != 0 {
+		t.Error("reset not persisted; a restart tripped the budget again")
+	}
+}
+
+func TestBudgetResetHandler(t *testing.T) {
+	registry = NewRegistry(false, time.Minute)
+	registry.Budget, _, _ = testBudget(1, 0, 0)
+	registry.Budget.Take("shop")
+	registry.Budget.Take("shop") // trips
+	auditLog = testAuditLog(t)
+	t.Cleanup(func() { registry, auditLog = nil, nil })
+
+	tests := []struct {
+		name     string
+		token    string
+		auth     string
+		wantCode int
+	}{
+		{"admin API off without a token", "", "Bearer anything", http.StatusForbidden},
+		{"wrong token", "s3cret", "Bearer nope", http.StatusUnauthorized},
+		{"missing header", "s3cret", "", http.StatusUnauthorized},
+		{"right token resets", "s3cret", "Bearer s3cret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REMEDIATOR_ADMIN_TOKEN", tt.token)
+			router := newRouter()
+			router.Group("/admin", adminAuth()).POST("/budget/reset", budgetResetHandler)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/budget/reset", nil)
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.wantCode {
+				t.Fatalf("status = %d, want %d", w.Code, tt.wantCode)
+			}
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			var body struct{ Reset []string }
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil || !slices.Equal(body.Reset, []string{""}) {
+				t.Errorf("body = %s, want the global scope reset", w.Body.String())
+			}
+			if len(registry.Budget.Tripped()) != 0 {
+				t.Error("budget still tripped after reset")
+			}
+			if got, _ := auditLog.Query(AuditFilter{Action: "admin"}); len(got) != 1 || got[0].Outcome != string(OutcomeBudgetReset) || got[0].Target != "budget" {
+				t.Errorf("audit = %+v, want the reset recorded as action admin", got)
+			}
+		})
+	}
+}
+
+func TestBudgetResetHandler_FollowerRefuses(t *testing.T) {
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	registry = NewRegistry(false, time.Minute)
+	registry.Budget, _, _ = testBudget(1, 0, 0)
+	registry.Budget.Take("shop")
+	registry.Budget.Take("shop") // trips
+	t.Cleanup(func() { registry = nil })
+	followerOf(t, "10.0.0.2:8080")
+
+	router := newRouter()
+	router.Group("/admin", adminAuth()).POST("/budget/reset", budgetResetHandler)
+	w := postAction(router, "/admin/budget/reset", "s3cret")
+	var body map[string]string
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusServiceUnavailable || body["leader"] == "" {
+		t.Errorf("reset on a follower = %d %s, want 503 naming the leader", w.Code, w.Body.String())
+	}
+	if len(registry.Budget.Tripped()) != 1 {
+		t.Error("a follower's reset closed its own breaker, which the leader never sees")
+	}
+}
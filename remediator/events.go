@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// actionEvents posts every remediation decision as a Kubernetes Event on the objects it
+// concerns, so `kubectl describe` on the flagd ConfigMap or a Deployment shows what the
+// remediator did to it. Nil when observe-only.
+var actionEvents *ActionEvents
+
+// eventAnnotation prefixes the machine-readable annotations on each Event.
+const eventAnnotation = "remediator.omniobserve.io/"
+
+// eventObjects is implemented by actions that can name the objects a plan touches: the
+// object it mutates and, when known, the workload that mutation is for.
+type eventObjects interface {
+	Objects(p Plan) []corev1.ObjectReference
+}
+
+// ActionEvents records decisions through client-go's event recorder, which aggregates
+// repeats (a cooldown on every Alertmanager repeat becomes one Event with a count).
+type ActionEvents struct {
+	recorder record.EventRecorder
+}
+
+// NewActionEvents starts an event broadcaster writing to the API server. The returned
+// func stops it.
+func NewActionEvents(k8s kubernetes.Interface, host string) (*ActionEvents, func()) {
+	b := record.NewBroadcaster()
+	b.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: k8s.CoreV1().Events("")})
+	rec := b.NewRecorder(scheme.Scheme, corev1.EventSource{Component: "remediator", Host: host})
+	return &ActionEvents{recorder: rec}, b.Shutdown
+}
+
+// Record posts one decision on each object the action names for the plan. A plan that
+// names none (a cooldown before the target was planned, say) posts nothing.
+func (e *ActionEvents) Record(a Action, name, incidentKey string, res Result, err error) {
+	d, ok := a.(eventObjects)
+	if !ok {
+		return
+	}
+	alertname, _, _ := strings.Cut(incidentKey, "|")
+	eventType, reason := eventReason(res.Outcome, err)
+	msg := eventMessage(name, incidentKey, res, err)
+	annotations := map[string]string{
+		eventAnnotation + "incident-key": incidentKey,
+		eventAnnotation + "alertname":    alertname,
+		eventAnnotation + "action":       name,
+		eventAnnotation + "outcome":      string(res.Outcome),
+	}
+	for _, ref := range d.Objects(res.Plan) {
+		e.recorder.AnnotatedEventf(&ref, annotations, eventType, reason, "%s", msg)
+	}
+}
+
+// warningOutcomes are decisions someone looking at the object should notice.
+var warningOutcomes = map[Outcome]bool{
+	OutcomePolicyDenied:    true,
+	OutcomeBudgetExhausted: true,
+	OutcomeIneffective:     true,
+	OutcomeApprovalDenied:  true,
+	OutcomeApprovalExpired: true,
+}
+
+// eventReason maps an outcome to an Event type and CamelCase reason: dry_run -> DryRun,
+// policy_denied -> PolicyDenied. A failed action is a Warning with reason Failed.
+func eventReason(o Outcome, err error) (string, string) {
+	if err != nil {
+		return corev1.EventTypeWarning, "Failed"
+	}
+	var reason strings.Builder
+	for _, word := range strings.Split(string(o), "_") {
+		if word != "" {
+			reason.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	if warningOutcomes[o] {
+		return corev1.EventTypeWarning, reason.String()
+	}
+	return corev1.EventTypeNormal, reason.String()
+}
+
+// eventMessage is the human line under the reason in `kubectl describe`.
+func eventMessage(name, incidentKey string, res Result, err error) string {
+	what := string(res.Outcome)
+	if res.Executed && res.Plan.Description != "" {
+		what = res.Plan.Description
+	}
+	if err != nil {
+		what = "failed: " + err.Error()
+	}
+	msg := fmt.Sprintf("%s: %s (incident %s)", name, what, incidentKey)
+	if res.Rule != "" {
+		msg += ", policy rule " + res.Rule
+	}
+	if res.Actor != "" {
+		msg += ", by " + res.Actor
+	}
+	return msg
+}
+
+// emitEvent records a decision as Kubernetes Events, when events are enabled.
+func emitEvent(name, incidentKey string, res Result, err error) {
+	if actionEvents == nil {
+		return
+	}
+	if a := registryAction(name); a != nil {
+		actionEvents.Record(a, name, incidentKey, res, err)
+	}
+}
+
+// deploymentRef references a Deployment by namespace and name.
+func deploymentRef(ns, name string) corev1.ObjectReference {
+	return corev1.ObjectReference{APIVersion: "apps/v1", Kind: "Deployment", Namespace: ns, Name: name}
+}
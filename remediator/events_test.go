@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+)
+
+func TestEventReason(t *testing.T) {
+	tests := []struct {
+		outcome    Outcome
+		err        error
+		wantType   string
+		wantReason string
+	}{
+		{OutcomeDisabled, nil, "Normal", "Disabled"},
+		{OutcomeDryRun, nil, "Normal", "DryRun"},
+		{OutcomeCooldown, nil, "Normal", "Cooldown"},
+		{OutcomePolicyDenied, nil, "Warning", "PolicyDenied"},
+		{OutcomeDisabled, errors.New("boom"), "Warning", "Failed"},
+	}
+	for _, tt := range tests {
+		if gotType, gotReason := eventReason(tt.outcome, tt.err); gotType != tt.wantType || gotReason != tt.wantReason {
+			t.Errorf("eventReason(%q, %v) = (%s, %s), want (%s, %s)",
+				tt.outcome, tt.err, gotType, gotReason, tt.wantType, tt.wantReason)
+		}
+	}
+}
+
+func TestActionEvents_RecordsOnConfigMapAndDeployment(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	rec.IncludeObject = true
+	e := &ActionEvents{recorder: rec}
+	flagd := NewFlagRemediator(nil, "otel-demo", "flagd-config", "demo.flagd.json")
+
+	plan := Plan{Action: actionFlagd, Target: "cartFailure", IncidentKey: "HighErrors|cart",
+		Description: "disabled flagd flag cartFailure",
+		Params:      map[string]string{"deployment": "otel-demo/cart"}}
+	e.Record(flagd, actionFlagd, plan.IncidentKey, Result{Plan: plan, Outcome: OutcomeDisabled, Executed: true}, nil)
+
+	for _, kind := range []string{"ConfigMap", "Deployment"} {
+		got := <-rec.Events
+		if !strings.HasPrefix(got, "Normal Disabled flagd: disabled flagd flag cartFailure (incident HighErrors|cart)") ||
+			!strings.Contains(got, "kind="+kind) || !strings.Contains(got, eventAnnotation+"alertname:HighErrors") {
+			t.Errorf("event = %q, want Normal Disabled with the incident key and alertname, on the %s", got, kind)
+		}
+	}
+	if refs := flagd.Objects(plan); refs[0].Name != "flagd-config" || refs[1].Namespace != "otel-demo" || refs[1].Name != "cart" {
+		t.Errorf("Objects = %+v, want otel-demo/flagd-config and otel-demo/cart", refs)
+	}
+
+	// A cooldown hasn't planned a target yet; the ConfigMap is still known.
+	e.Record(flagd, actionFlagd, "HighErrors|cart",
+		Result{Plan: Plan{Action: actionFlagd, IncidentKey: "HighErrors|cart"}, Outcome: OutcomeCooldown}, nil)
+	if got := <-rec.Events; !strings.HasPrefix(got, "Normal Cooldown") || !strings.Contains(got, "kind=ConfigMap") {
+		t.Errorf("cooldown event = %q, want Normal Cooldown on the ConfigMap", got)
+	}
+	if len(rec.Events) != 0 {
+		t.Errorf("%d extra events, want one per object", len(rec.Events))
+	}
+}
+
+func TestActionEvents_SkipsUnplannedDeploymentTarget(t *testing.T) {
+	rec := record.NewFakeRecorder(10)
+	e := &ActionEvents{recorder: rec}
+
+	e.Record(NewScaleRemediator(nil, "default", nil), actionScale, "HighLatency|api",
+		Result{Plan: Plan{Action: actionScale, IncidentKey: "HighLatency|api"}, Outcome: OutcomeCooldown}, nil)
+	if len(rec.Events) != 0 {
+		t.Errorf("got an event for a plan with no target: %q", <-rec.Events)
+	}
+}
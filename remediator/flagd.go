@@ -0,0 +1,641 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+
+	"gopkg.in/yaml.v3"
+)
+
+// actionFlagd is the registry name of the flagd kill switch. It is also the default for
+// alerts that carry only the original remediation_flag annotation.
+const actionFlagd = "flagd"
+
+// defaultSafeVariant is the variant a flag is switched to when the alert names none.
+const defaultSafeVariant = "off"
+
+// Targeting modes: what the kill switch does with a flag's targeting rules, which flagd
+// evaluates before falling back to defaultVariant.
+const (
+	targetingKeep     = "keep"     // leave targeting alone; only defaultVariant changes
+	targetingClear    = "clear"    // remove targeting, so every evaluation gets the safe variant
+	targetingOverride = "override" // replace targeting with the alert's remediation_targeting_rule
+)
+
+// FlagRemediator switches flagd feature flags to a safe variant in response to alerts. It
+// is the bounded action of OmniObserve's control loop: the ONLY mutation it can perform is
+// setting a named flag's defaultVariant to one of the flag's own variants ("off" unless
+// the alert's remediation_variant names another) and, optionally, removing or replacing the
+// flag's targeting — a feature-flag kill switch, the safest possible remediation
+// (reversible, scoped, and exactly undoing the injected fault). It never writes a variant,
+// or targeting that can return a variant, the flag doesn't define.
+type FlagRemediator struct {
+	k8s kubernetes.Interface
+	def FlagTarget // the flagd instance for alerts no entry in Targets matches
+	// Targets are further flagd instances, each chosen by the alert's labels (first match
+	// wins), so one remediator can serve flagd deployments across namespaces and teams.
+	Targets []FlagTarget
+	// AutoRestore puts a disabled flag's original defaultVariant back once the alert has
+	// resolved and soaked, so nobody has to re-enable fault flags by hand after an incident.
+	AutoRestore bool
+	// Targeting is the mode for flags with targeting rules when the alert's
+	// remediation_targeting doesn't say: keep (default), clear, or override (with the
+	// JsonLogic in the alert's remediation_targeting_rule). A flag whose fault is driven by
+	// targeting (e.g. a fractional rollout) stays broken under keep.
+	Targeting string
+	// Git, when set, reads and commits the default instance's flagd config in the git repo
+	// Argo CD syncs its ConfigMap from, instead of editing the ConfigMap — which the next
+	// sync would revert. Targets always edit their ConfigMaps.
+	Git *GitFlags
+}
+
+// FlagTarget is one flagd instance: the ConfigMap key holding its flag config, and the
+// alert labels that select it.
+type FlagTarget struct {
+	Name      string            `yaml:"name"`      // prefixes plan targets: <name>/<flag>
+	Match     map[string]string `yaml:"match"`     // alert labels that must all be equal, e.g. team: payments
+	Namespace string            `yaml:"namespace"` // where the flagd ConfigMap lives (e.g. otel-demo)
+	ConfigMap string            `yaml:"configMap"` // e.g. flagd-config
+	ConfigKey string            `yaml:"configKey"` // e.g. demo.flagd.json
+}
+
+// NewFlagRemediator builds a remediator bound to a flagd ConfigMap.
+func NewFlagRemediator(k8s kubernetes.Interface, namespace, configMap, configKey string) *FlagRemediator {
+	return &FlagRemediator{
+		k8s:       k8s,
+		def:       FlagTarget{Namespace: namespace, ConfigMap: configMap, ConfigKey: configKey},
+		Targeting: targetingKeep,
+	}
+}
+
+// ParseFlagTargets reads FLAGD_TARGETS: a YAML (or JSON) list of FlagTargets. A target
+// without a configMap or configKey uses the default instance's. Unknown keys are errors,
+// as in the policy: a typo'd match would otherwise select every alert.
+func ParseFlagTargets(raw string, def FlagTarget) ([]FlagTarget, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var targets []FlagTarget
+	dec := yaml.NewDecoder(strings.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&targets); err != nil {
+		return nil, fmt.Errorf("parse flagd targets: %w", err)
+	}
+	seen := map[string]bool{}
+	for i := range targets {
+		t := &targets[i]
+		if t.Name == "" || strings.Contains(t.Name, "/") || seen[t.Name] {
+			return nil, fmt.Errorf("flagd target #%d: name %q is empty, contains '/' or is duplicated", i+1, t.Name)
+		}
+		seen[t.Name] = true
+		if len(t.Match) == 0 || t.Namespace == "" {
+			return nil, fmt.Errorf("flagd target %s: needs match labels and a namespace", t.Name)
+		}
+		t.ConfigMap = cmp.Or(t.ConfigMap, def.ConfigMap)
+		t.ConfigKey = cmp.Or(t.ConfigKey, def.ConfigKey)
+	}
+	return targets, nil
+}
+
+// Plan looks the alert's remediation_flag up in the flagd config, checks the safe variant
+// (and every variant an override rule can return) is one the flag defines, and records the
+// variant (and targeting) it would replace, so Undo can put them back. A missing flag, a
+// missing variant, or a flag already serving the safe variant is a no-op.
+func (r *FlagRemediator) Plan(ctx context.Context, alert Alert) (Plan, error) {
+	flag := alert.remediationFlag()
+	variant := alert.Annotations["remediation_variant"]
+	if variant == "" {
+		variant = defaultSafeVariant
+	}
+	mode := alert.Annotations["remediation_targeting"]
+	if mode == "" {
+		mode = r.Targeting
+	}
+	t := r.targetFor(alert)
+	p := planFor(t, flag)
+	p.Description = "disabled flagd flag " + flag
+	if variant != defaultSafeVariant {
+		p.Description = fmt.Sprintf("switched flagd flag %s to variant %s", flag, variant)
+	}
+	if flag == "" {
+		return p, fmt.Errorf("alert has no remediation_flag annotation")
+	}
+	var rule map[string]any
+	switch mode {
+	case targetingKeep, targetingClear:
+	case targetingOverride:
+		raw := alert.Annotations["remediation_targeting_rule"]
+		if err := json.Unmarshal([]byte(raw), &rule); err != nil || rule == nil {
+			return p, fmt.Errorf("remediation_targeting override: remediation_targeting_rule %q is not a JsonLogic object", raw)
+		}
+	default:
+		return p, fmt.Errorf("unknown remediation_targeting %q (want keep, clear or override)", mode)
+	}
+
+	doc, err := r.document(ctx, t, "")
+	if err != nil {
+		return p, err
+	}
+	entry, ok := flagEntry(doc, flag)
+	if !ok {
+		p.Noop = OutcomeFlagMissing
+		return p, nil
+	}
+	if !hasVariant(entry, variant) {
+		p.Noop = OutcomeVariantMissing // writing it would leave flagd with an invalid flag
+		return p, nil
+	}
+	if rule != nil {
+		variants, err := ruleVariants(rule)
+		if err != nil {
+			return p, fmt.Errorf("flag %s: remediation_targeting_rule: %w", flag, err)
+		}
+		for _, v := range variants {
+			if !hasVariant(entry, v) {
+				p.Noop = OutcomeVariantMissing // flagd would reject the rule
+				return p, nil
+			}
+		}
+		raw, _ := json.Marshal(rule) // canonical: map keys sorted, so isRemediated can compare
+		p.Params["targeting"] = string(raw)
+	}
+	targeting, hasTargeting := entry["targeting"]
+	replaceTargeting := (mode == targetingClear && hasTargeting) ||
+		(rule != nil && !sameTargeting(entry, p.Params["targeting"]))
+	if entry["defaultVariant"] == variant && !replaceTargeting {
+		p.Noop = OutcomeAlreadyOff // idempotent: already remediated
+		return p, nil
+	}
+	prev, _ := entry["defaultVariant"].(string)
+	p.Params["previousVariant"], p.Params["variant"] = prev, variant
+	if replaceTargeting && hasTargeting {
+		raw, err := json.Marshal(targeting)
+		if err != nil {
+			return p, fmt.Errorf("flag %s: marshal targeting: %w", flag, err)
+		}
+		p.Params["previousTargeting"] = string(raw)
+	}
+	switch {
+	case rule != nil:
+		p.Description += " and overrode its targeting"
+	case replaceTargeting:
+		p.Description += " and cleared its targeting"
+	}
+	if d := alert.Labels["deployment"]; d != "" {
+		p.Params["deployment"] = alert.namespace(t.Namespace) + "/" + d // the workload the flag is breaking
+	}
+	return p, nil
+}
+
+// Execute switches the planned flag to its safe variant (clearing or overriding targeting,
+// if planned).
+// The flagd config is a JSON document in a ConfigMap key; flagd hot-reloads the mounted
+// file, so updating the ConfigMap is enough to stop the fault — no pod restart. With the
+// git backend the change is committed instead, and is pr_opened until someone merges it.
+func (r *FlagRemediator) Execute(ctx context.Context, p Plan) (Outcome, error) {
+	return r.write(ctx, p, "disable", OutcomeDisabled, func(entry map[string]any) error {
+		if err := setDefaultVariant(entry, safeVariant(p)); err != nil {
+			return err
+		}
+		switch {
+		case p.Params["targeting"] != "":
+			var rule any
+			if err := json.Unmarshal([]byte(p.Params["targeting"]), &rule); err != nil {
+				return fmt.Errorf("parse targeting: %w", err)
+			}
+			entry["targeting"] = rule
+		case p.Params["previousTargeting"] != "":
+			delete(entry, "targeting")
+		}
+		return nil
+	})
+}
+
+// Verify re-reads the config and reports whether the flag now serves the safe variant —
+// with the git backend, on the branch Execute committed to.
+func (r *FlagRemediator) Verify(ctx context.Context, p Plan) (bool, error) {
+	t, ref := r.targetOf(p), ""
+	if g := r.git(t); g != nil {
+		ref = g.Ref(p, "disable")
+	}
+	doc, err := r.document(ctx, t, ref)
+	if err != nil {
+		return false, err
+	}
+	entry, ok := flagEntry(doc, flagOf(p))
+	return ok && isRemediated(entry, p), nil
+}
+
+// Undo restores the variant (and targeting) the flag had before Execute. If the flag is no
+// longer as Execute left it, someone has changed it since, and their change wins
+// (restore_skipped).
+func (r *FlagRemediator) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	prev := p.Params["previousVariant"]
+	if prev == "" {
+		return "", fmt.Errorf("plan for %s has no previous variant to restore", p.Target)
+	}
+	var targeting any
+	if raw := p.Params["previousTargeting"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &targeting); err != nil {
+			return "", fmt.Errorf("plan for %s: parse previous targeting: %w", p.Target, err)
+		}
+	}
+	doc, err := r.document(ctx, r.targetOf(p), "")
+	if err != nil {
+		return "", err
+	}
+	if entry, ok := flagEntry(doc, flagOf(p)); !ok || !isRemediated(entry, p) {
+		return OutcomeRestoreSkipped, nil // with the git backend, also: the PR was never merged
+	}
+	return r.write(ctx, p, "restore", OutcomeRestored, func(entry map[string]any) error {
+		if err := setDefaultVariant(entry, prev); err != nil {
+			return err
+		}
+		if targeting != nil {
+			entry["targeting"] = targeting
+		} else if p.Params["targeting"] != "" {
+			delete(entry, "targeting") // the override was the flag's only targeting
+		}
+		return nil
+	})
+}
+
+// Change reports the flag's defaultVariant (and targeting, when cleared or overridden)
+// before and after Execute.
+func (r *FlagRemediator) Change(p Plan) (string, string) {
+	before, after := "defaultVariant="+p.Params["previousVariant"], "defaultVariant="+safeVariant(p)
+	if rule := p.Params["targeting"]; rule != "" {
+		return before + " targeting=" + cmp.Or(p.Params["previousTargeting"], "none"), after + " targeting=" + rule
+	}
+	if p.Params["previousTargeting"] != "" {
+		before, after = before+" targeting="+p.Params["previousTargeting"], after+" targeting=none"
+	}
+	return before, after
+}
+
+// Objects names the flagd ConfigMap and, when the alert carried a deployment label, the
+// Deployment the flag was breaking.
+func (r *FlagRemediator) Objects(p Plan) []corev1.ObjectReference {
+	t := r.targetOf(p)
+	refs := []corev1.ObjectReference{{APIVersion: "v1", Kind: "ConfigMap", Namespace: t.Namespace, Name: t.ConfigMap}}
+	if ns, name, ok := strings.Cut(p.Params["deployment"], "/"); ok {
+		refs = append(refs, deploymentRef(ns, name))
+	}
+	return refs
+}
+
+// Permissions are reading and updating each flagd instance's ConfigMap — not the default
+// one's with the git backend, which commits instead — and posting Events next to it.
+func (r *FlagRemediator) Permissions() []authorizationv1.ResourceAttributes {
+	var out []authorizationv1.ResourceAttributes
+	for _, t := range append([]FlagTarget{r.def}, r.Targets...) {
+		if r.git(t) == nil {
+			out = append(out, t.permissions()...)
+		}
+		out = append(out, permissions(t.Namespace, "", "events", "", "create")...)
+	}
+	return out
+}
+
+// permissions are reading and updating t's flagd ConfigMap.
+func (t FlagTarget) permissions() []authorizationv1.ResourceAttributes {
+	return permissions(t.Namespace, "", "configmaps", t.ConfigMap, "get", "update")
+}
+
+// UndoOnResolve opts the kill switch into automatic restore when AutoRestore is set.
+func (r *FlagRemediator) UndoOnResolve() bool { return r.AutoRestore }
+
+// targetFor picks the flagd instance an alert is about: the first of Targets whose match
+// labels all equal the alert's, else the default.
+func (r *FlagRemediator) targetFor(alert Alert) FlagTarget {
+	for _, t := range r.Targets {
+		matched := true
+		for k, v := range t.Match {
+			matched = matched && alert.Labels[k] == v
+		}
+		if matched {
+			return t
+		}
+	}
+	return r.def
+}
+
+// targetOf is the flagd instance p was planned against. Plans without a target (the
+// default, and every plan persisted before there were others) are on the default; so is
+// one whose target has since been removed from the config.
+func (r *FlagRemediator) targetOf(p Plan) FlagTarget {
+	if name := p.Params["target"]; name != "" {
+		for _, t := range r.Targets {
+			if t.Name == name {
+				return t
+			}
+		}
+	}
+	return r.def
+}
+
+// planFor starts a plan for flag on t. On a named target the plan's Target is
+// <name>/<flag>, so metrics, audit records and policy targets tell instances apart.
+func planFor(t FlagTarget, flag string) Plan {
+	p := Plan{Target: flag, Params: map[string]string{"namespace": t.Namespace}}
+	if t.Name != "" {
+		p.Target = t.Name + "/" + flag
+		p.Params["target"], p.Params["flag"] = t.Name, flag
+	}
+	return p
+}
+
+// flagOf is the name of the flag p acts on.
+func flagOf(p Plan) string {
+	if f := p.Params["flag"]; f != "" {
+		return f
+	}
+	return p.Target
+}
+
+// git is the git backend for t: only the default instance has one.
+func (r *FlagRemediator) git(t FlagTarget) *GitFlags {
+	if t.Name != "" {
+		return nil
+	}
+	return r.Git
+}
+
+// load reads t's flagd ConfigMap and parses its config key.
+func (r *FlagRemediator) load(ctx context.Context, t FlagTarget) (*corev1.ConfigMap, map[string]any, error) {
+	cm, err := r.k8s.CoreV1().ConfigMaps(t.Namespace).Get(ctx, t.ConfigMap, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("get configmap %s/%s: %w", t.Namespace, t.ConfigMap, err)
+	}
+
+	raw, ok := cm.Data[t.ConfigKey]
+	if !ok {
+		return nil, nil, fmt.Errorf("key %q not in configmap %s/%s", t.ConfigKey, t.Namespace, t.ConfigMap)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(raw), &doc); err != nil {
+		return nil, nil, fmt.Errorf("parse flagd config: %w", err)
+	}
+	return cm, doc, nil
+}
+
+// document reads t's flagd document: from ref (Base when empty) with the git backend,
+// otherwise from the ConfigMap.
+func (r *FlagRemediator) document(ctx context.Context, t FlagTarget, ref string) (map[string]any, error) {
+	g := r.git(t)
+	if g == nil {
+		_, doc, err := r.load(ctx, t)
+		return doc, err
+	}
+	if ref == "" {
+		ref = g.Base
+	}
+	return g.Load(ctx, ref)
+}
+
+// write applies change to p's flag through the configured backend and returns applied —
+// or, when the git backend committed it to a branch for review, pr_opened. verb names the
+// branch, so a change and its undo get separate pull requests.
+func (r *FlagRemediator) write(ctx context.Context, p Plan, verb string, applied Outcome, change func(entry map[string]any) error) (Outcome, error) {
+	t, flag := r.targetOf(p), flagOf(p)
+	g := r.git(t)
+	if g == nil {
+		if err := r.update(ctx, t, flag, change); err != nil {
+			return "", err
+		}
+		return applied, nil
+	}
+	before, after := r.Change(p)
+	if verb == "restore" {
+		before, after = after, before
+	}
+	summary := p.Description
+	if verb == "restore" {
+		summary = "restored flagd flag " + flag
+	}
+	message := fmt.Sprintf("remediator: %s\n\nIncident: %s\nBefore: %s\nAfter: %s\n", summary, p.IncidentKey, before, after)
+	ref := g.Ref(p, verb)
+	url, err := g.Commit(ctx, ref, flag, message, change)
+	if err != nil {
+		return "", err
+	}
+	if ref == g.Base {
+		return applied, nil
+	}
+	logger.Infow("flagd change awaiting merge", "flag", flag, "branch", ref, "pull_request", url)
+	return OutcomePROpened, nil
+}
+
+// update applies change to flag's entry and writes the document back to t's ConfigMap.
+// The write carries the resourceVersion it read, so a concurrent edit (Argo CD, a human,
+// another flag's remediation) makes it conflict rather than be overwritten; on conflict
+// the change is re-applied to a fresh read. If change fails, nothing is written.
+func (r *FlagRemediator) update(ctx context.Context, t FlagTarget, flag string, change func(entry map[string]any) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, doc, err := r.load(ctx, t)
+		if err != nil {
+			return err
+		}
+		entry, ok := flagEntry(doc, flag)
+		if !ok {
+			return fmt.Errorf("flag %q not in flagd config", flag)
+		}
+		if err := change(entry); err != nil {
+			return fmt.Errorf("flag %q: %w", flag, err)
+		}
+
+		patched, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshal flagd config: %w", err)
+		}
+		cm.Data[t.ConfigKey] = string(patched)
+
+		_, err = r.k8s.CoreV1().ConfigMaps(t.Namespace).Update(ctx, cm, metav1.UpdateOptions{})
+		if apierrors.IsConflict(err) {
+			return err // unwrapped, so RetryOnConflict sees it
+		}
+		if err != nil {
+			return fmt.Errorf("update configmap %s/%s: %w", t.Namespace, t.ConfigMap, err)
+		}
+		return nil
+	})
+}
+
+// Drifted reports reverted_by_gitops (or reverted) once the flag no longer serves the
+// safe variant Execute set. With the git backend, git is the desired state: a direct commit
+// that's been reverted there is reverted, and a branch awaiting merge can't have drifted.
+func (r *FlagRemediator) Drifted(ctx context.Context, p Plan) (Outcome, error) {
+	t := r.targetOf(p)
+	if g := r.git(t); g != nil {
+		if !g.Direct {
+			return "", nil
+		}
+		doc, err := g.Load(ctx, g.Base)
+		if err != nil {
+			return "", err
+		}
+		if entry, ok := flagEntry(doc, flagOf(p)); ok && isRemediated(entry, p) {
+			return "", nil
+		}
+		return OutcomeReverted, nil
+	}
+	cm, doc, err := r.load(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	if entry, ok := flagEntry(doc, flagOf(p)); ok && isRemediated(entry, p) {
+		return "", nil
+	}
+	return revertedBy(cm, t.ConfigKey), nil
+}
+
+// gitOpsManagers are the field managers of the GitOps controllers that sync ConfigMaps.
+var gitOpsManagers = []string{"argocd-controller", "argocd-application-controller", "kustomize-controller", "helm-controller"}
+
+// revertedBy tells a GitOps re-sync from any other edit: the field manager that owns the
+// config key (ownership moves to whoever last changed it) is a GitOps controller, or, when
+// the ConfigMap has no managedFields to go by, it carries Argo CD or Flux tracking metadata.
+func revertedBy(cm *corev1.ConfigMap, key string) Outcome {
+	var owner metav1.ManagedFieldsEntry
+	for _, mf := range cm.ManagedFields {
+		if mf.FieldsV1 == nil || !strings.Contains(string(mf.FieldsV1.Raw), `"f:`+key+`"`) {
+			continue
+		}
+		if owner.Manager == "" || (mf.Time != nil && owner.Time != nil && owner.Time.Before(mf.Time)) {
+			owner = mf
+		}
+	}
+	if owner.Manager != "" {
+		if slices.Contains(gitOpsManagers, owner.Manager) {
+			return OutcomeRevertedByGitOps
+		}
+		return OutcomeReverted
+	}
+	_, argo := cm.Annotations["argocd.argoproj.io/tracking-id"]
+	_, flux := cm.Labels["kustomize.toolkit.fluxcd.io/name"]
+	if argo || flux || cm.Labels["app.kubernetes.io/managed-by"] == "argocd" {
+		return OutcomeRevertedByGitOps
+	}
+	return OutcomeReverted
+}
+
+// flagEntry returns the named flag's object from a parsed flagd document.
+func flagEntry(doc map[string]any, flag string) (map[string]any, bool) {
+	flags, _ := doc["flags"].(map[string]any)
+	entry, ok := flags[flag].(map[string]any)
+	return entry, ok
+}
+
+// hasVariant reports whether the flag defines variant in its variants map — flagd rejects
+// a defaultVariant that isn't one of them.
+func hasVariant(entry map[string]any, variant string) bool {
+	variants, _ := entry["variants"].(map[string]any)
+	_, ok := variants[variant]
+	return ok
+}
+
+// setDefaultVariant sets the flag's defaultVariant, refusing a variant it doesn't define.
+func setDefaultVariant(entry map[string]any, variant string) error {
+	if !hasVariant(entry, variant) {
+		return fmt.Errorf("no variant %q", variant)
+	}
+	entry["defaultVariant"] = variant
+	return nil
+}
+
+// isRemediated reports whether the flag is as Execute leaves it for p.
+func isRemediated(entry map[string]any, p Plan) bool {
+	if entry["defaultVariant"] != safeVariant(p) {
+		return false
+	}
+	if rule := p.Params["targeting"]; rule != "" {
+		return sameTargeting(entry, rule)
+	}
+	_, hasTargeting := entry["targeting"]
+	return p.Params["previousTargeting"] == "" || !hasTargeting
+}
+
+// sameTargeting reports whether the flag's targeting marshals to rule, which Plan stored
+// canonically (encoding/json sorts map keys).
+func sameTargeting(entry map[string]any, rule string) bool {
+	targeting, ok := entry["targeting"]
+	if !ok {
+		return false
+	}
+	raw, err := json.Marshal(targeting)
+	return err == nil && string(raw) == rule
+}
+
+// ruleVariants lists the variants a JsonLogic targeting rule can evaluate to: the string
+// results of its if/else branches and the buckets of its fractionals. Conditions aren't
+// results and aren't checked; a result computed any other way (var, cat, ...) can't be
+// checked against the flag's variants, so it's an error. A null result falls back to
+// defaultVariant.
+func ruleVariants(rule any) ([]string, error) {
+	switch r := rule.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{r}, nil
+	case map[string]any:
+		if len(r) != 1 {
+			return nil, fmt.Errorf("want one operation per object, got %d", len(r))
+		}
+		for op, arg := range r {
+			args, _ := arg.([]any)
+			var results []any
+			switch op {
+			case "if":
+				for i, a := range args {
+					if i%2 == 1 || i == len(args)-1 { // then-branches, and the trailing else
+						results = append(results, a)
+					}
+				}
+			case "fractional":
+				for _, a := range args {
+					bucket, ok := a.([]any)
+					if !ok {
+						continue // the optional bucketing expression
+					}
+					if len(bucket) == 0 || len(bucket) > 2 {
+						return nil, fmt.Errorf("fractional bucket %v: want [variant, weight]", bucket)
+					}
+					results = append(results, bucket[0])
+				}
+			default:
+				return nil, fmt.Errorf("can't tell which variants %q returns", op)
+			}
+			var variants []string
+			for _, res := range results {
+				vs, err := ruleVariants(res)
+				if err != nil {
+					return nil, err
+				}
+				variants = append(variants, vs...)
+			}
+			return variants, nil
+		}
+	}
+	return nil, fmt.Errorf("result %v is not a variant name", rule)
+}
+
+// safeVariant is the variant p switches its flag to. Plans persisted before variants were
+// configurable don't record one, and always meant "off".
+func safeVariant(p Plan) string {
+	if v := p.Params["variant"]; v != "" {
+		return v
+	}
+	return defaultSafeVariant
+}
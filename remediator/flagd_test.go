@@ -0,0 +1,587 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace/noop"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// flagdConfig builds a minimal demo.flagd.json with productCatalogFailure at the given
+// defaultVariant, so tests can start from "on" (fault active) or "off" (already healed).
+func flagdConfig(defaultVariant string) string {
+	doc := map[string]any{
+		"flags": map[string]any{
+			"productCatalogFailure": map[string]any{
+				"state":          "ENABLED",
+				"variants":       map[string]any{"on": true, "off": false},
+				"defaultVariant": defaultVariant,
+			},
+		},
+	}
+	b, _ := json.Marshal(doc)
+	return string(b)
+}
+
+// newFakeRemediator registers the flagd action against a fake cluster holding the flagd
+// ConfigMap, behind a registry with the given rails.
+func newFakeRemediator(t *testing.T, defaultVariant string, dryRun bool, cooldown time.Duration) (*Registry, *fake.Clientset) {
+	t.Helper()
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "otel-demo"},
+		Data:       map[string]string{"demo.flagd.json": flagdConfig(defaultVariant)},
+	}
+	cs := fake.NewClientset(cm)
+	r := NewRegistry(dryRun, cooldown)
+	r.Register(actionFlagd, NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json"))
+	return r, cs
+}
+
+// disableFlag runs the registry for a firing alert naming flag via remediation_flag.
+func disableFlag(r *Registry, flag, service string) (Outcome, error) {
+	res, err := r.Run(context.Background(), Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": service},
+		Annotations: map[string]string{"remediation_flag": flag},
+	})
+	return res.Outcome, err
+}
+
+// currentVariant reads productCatalogFailure's defaultVariant back from the cluster.
+func currentVariant(t *testing.T, cs *fake.Clientset) string {
+	t.Helper()
+	cm, err := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(cm.Data["demo.flagd.json"]), &doc); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	flags := doc["flags"].(map[string]any)
+	return flags["productCatalogFailure"].(map[string]any)["defaultVariant"].(string)
+}
+
+func TestDisableFlag_TurnsOff(t *testing.T) {
+	r, cs := newFakeRemediator(t, "on", false, time.Minute)
+	got, err := disableFlag(r, "productCatalogFailure", "inc1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != OutcomeDisabled {
+		t.Errorf("outcome = %q, want disabled", got)
+	}
+	if v := currentVariant(t, cs); v != "off" {
+		t.Errorf("flag defaultVariant = %q, want off", v)
+	}
+}
+
+func TestDisableFlag_Idempotent(t *testing.T) {
+	r, cs := newFakeRemediator(t, "off", false, time.Minute)
+	got, err := disableFlag(r, "productCatalogFailure", "inc1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != OutcomeAlreadyOff {
+		t.Errorf("outcome = %q, want already_off", got)
+	}
+	if v := currentVariant(t, cs); v != "off" {
+		t.Errorf("flag defaultVariant = %q, want off", v)
+	}
+}
+
+func TestDisableFlag_DryRunDoesNotMutate(t *testing.T) {
+	r, cs := newFakeRemediator(t, "on", true, time.Minute)
+	got, err := disableFlag(r, "productCatalogFailure", "inc1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != OutcomeDryRun {
+		t.Errorf("outcome = %q, want dry_run", got)
+	}
+	if v := currentVariant(t, cs); v != "on" {
+		t.Errorf("dry-run mutated the flag to %q, want it left on", v)
+	}
+}
+
+func TestDisableFlag_Cooldown(t *testing.T) {
+	r, _ := newFakeRemediator(t, "on", false, time.Minute)
+	if _, err := disableFlag(r, "productCatalogFailure", "inc1"); err != nil {
+		t.Fatalf("first call: %v", err)
+	}
+	// Same incident again within the window: must not act.
+	got, err := disableFlag(r, "productCatalogFailure", "inc1")
+	if err != nil {
+		t.Fatalf("second call: %v", err)
+	}
+	if got != OutcomeCooldown {
+		t.Errorf("outcome = %q, want cooldown", got)
+	}
+}
+
+func TestDisableFlag_Missing(t *testing.T) {
+	r, _ := newFakeRemediator(t, "on", false, time.Minute)
+	got, err := disableFlag(r, "noSuchFlag", "inc1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != OutcomeFlagMissing {
+		t.Errorf("outcome = %q, want flag_missing", got)
+	}
+}
+
+func TestDisableFlag_UndoRestoresPreviousVariant(t *testing.T) {
+	r, cs := newFakeRemediator(t, "on", false, time.Minute)
+	alert := Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "inc1"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	}
+	res, err := r.Run(context.Background(), alert)
+	if err != nil || !res.Executed {
+		t.Fatalf("run: executed=%v err=%v", res.Executed, err)
+	}
+	if got := res.Plan.Params["previousVariant"]; got != "on" {
+		t.Errorf("plan previousVariant = %q, want on", got)
+	}
+
+	got, err := r.Undo(context.Background(), res.Plan)
+	if err != nil {
+		t.Fatalf("undo: %v", err)
+	}
+	if got != OutcomeRestored {
+		t.Errorf("outcome = %q, want restored", got)
+	}
+	if v := currentVariant(t, cs); v != "on" {
+		t.Errorf("flag defaultVariant = %q, want on after undo", v)
+	}
+}
+
+// soakingRemediator is an auto-restoring flagd registry with a 10m soak and a clock the
+// test moves by hand.
+func soakingRemediator(t *testing.T) (*Registry, *fake.Clientset, *time.Time) {
+	t.Helper()
+	r, cs := newFakeRemediator(t, "on", false, time.Minute)
+	r.actions[actionFlagd].(*FlagRemediator).AutoRestore = true
+	r.Soak = 10 * time.Minute
+	clock := time.Date(2026, 6, 4, 12, 0, 0, 0, time.UTC)
+	r.now = func() time.Time { return clock }
+	return r, cs, &clock
+}
+
+func flagAlert(status string) Alert {
+	return Alert{
+		Status:      status,
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "inc1"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	}
+}
+
+func TestDisableFlag_RestoresAfterSoak(t *testing.T) {
+	r, cs, clock := soakingRemediator(t)
+	if _, err := r.Run(context.Background(), flagAlert("firing")); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	res, pending, err := r.Resolve(context.Background(), flagAlert("resolved"))
+	if err != nil || !pending || res.Outcome != OutcomeRestorePending {
+		t.Fatalf("resolve = %q (pending %v, err %v), want restore_pending", res.Outcome, pending, err)
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	if got := r.RestoreDue(context.Background()); len(got) != 0 {
+		t.Fatalf("restored %d plans mid-soak, want none", len(got))
+	}
+	if v := currentVariant(t, cs); v != "off" {
+		t.Fatalf("flag = %q mid-soak, want still off", v)
+	}
+
+	*clock = clock.Add(5 * time.Minute)
+	got := r.RestoreDue(context.Background())
+	if len(got) != 1 || got[0].Err != nil || got[0].Outcome != OutcomeRestored {
+		t.Fatalf("RestoreDue = %+v, want one restored", got)
+	}
+	if v := currentVariant(t, cs); v != "on" {
+		t.Errorf("flag = %q after soak, want the original on", v)
+	}
+	if got := r.RestoreDue(context.Background()); len(got) != 0 {
+		t.Errorf("restored again: %+v", got)
+	}
+}
+
+func TestDisableFlag_RefiringCancelsRestore(t *testing.T) {
+	r, cs, clock := soakingRemediator(t)
+	if _, err := r.Run(context.Background(), flagAlert("firing")); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if _, _, err := r.Resolve(context.Background(), flagAlert("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+
+	// The alert flaps back before the soak ends: the flag must stay off.
+	*clock = clock.Add(3 * time.Minute)
+	if _, err := r.Run(context.Background(), flagAlert("firing")); err != nil {
+		t.Fatalf("re-fire: %v", err)
+	}
+	*clock = clock.Add(20 * time.Minute)
+	if got := r.RestoreDue(context.Background()); len(got) != 0 {
+		t.Errorf("restored %d plans after the alert re-fired, want none", len(got))
+	}
+	if v := currentVariant(t, cs); v != "off" {
+		t.Errorf("flag = %q, want still off", v)
+	}
+}
+
+func TestDisableFlag_RestoreSkipsHumanChange(t *testing.T) {
+	r, cs, clock := soakingRemediator(t)
+	res, err := r.Run(context.Background(), flagAlert("firing"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	human := func(entry map[string]any) error { return setDefaultVariant(entry, "on") }
+	flagd := r.actions[actionFlagd].(*FlagRemediator)
+	if err := flagd.update(context.Background(), flagd.def, "productCatalogFailure", human); err != nil {
+		t.Fatalf("simulate human edit: %v", err)
+	}
+	if _, _, err := r.Resolve(context.Background(), flagAlert("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	*clock = clock.Add(10 * time.Minute)
+	got := r.RestoreDue(context.Background())
+	if len(got) != 1 || got[0].Outcome != OutcomeRestoreSkipped {
+		t.Fatalf("RestoreDue = %+v, want restore_skipped", got)
+	}
+	if got[0].Plan.Params["previousVariant"] != res.Plan.Params["previousVariant"] {
+		t.Errorf("restore used a different plan than the one executed")
+	}
+	if v := currentVariant(t, cs); v != "on" {
+		t.Errorf("flag = %q, want the human's on left alone", v)
+	}
+}
+
+// targetedRemediator holds a flag whose fault comes from fractional targeting, with
+// variants that have no "off": a kill switch that forced "off" would corrupt it.
+func targetedRemediator(t *testing.T) (*Registry, *fake.Clientset) {
+	t.Helper()
+	doc := `{"flags": {"paymentFailure": {
+		"state": "ENABLED",
+		"variants": {"100%": 1, "50%": 0.5, "none": 0},
+		"defaultVariant": "none",
+		"targeting": {"fractional": [["100%", 50], ["none", 50]]}}}}`
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "otel-demo"},
+		Data:       map[string]string{"demo.flagd.json": doc},
+	}
+	cs := fake.NewClientset(cm)
+	r := NewRegistry(false, time.Minute)
+	r.Register(actionFlagd, NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json"))
+	return r, cs
+}
+
+func paymentAlert(annotations map[string]string) Alert {
+	annotations["remediation_flag"] = "paymentFailure"
+	return Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "payment"},
+		Annotations: annotations,
+	}
+}
+
+// paymentFlag reads paymentFailure back from the cluster.
+func paymentFlag(t *testing.T, cs *fake.Clientset) map[string]any {
+	t.Helper()
+	cm, err := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get configmap: %v", err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal([]byte(cm.Data["demo.flagd.json"]), &doc); err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	entry, _ := flagEntry(doc, "paymentFailure")
+	return entry
+}
+
+func TestFlagd_VariantMissingLeavesDocumentAlone(t *testing.T) {
+	r, cs := targetedRemediator(t)
+	before := paymentFlag(t, cs)
+
+	for _, annotations := range []map[string]string{{}, {"remediation_variant": "disabled"}} {
+		res, err := r.Run(context.Background(), paymentAlert(annotations))
+		if err != nil || res.Outcome != OutcomeVariantMissing {
+			t.Errorf("Run(%v) = (%s, %v), want variant_missing", annotations, res.Outcome, err)
+		}
+	}
+	if after := paymentFlag(t, cs); after["defaultVariant"] != before["defaultVariant"] || after["targeting"] == nil {
+		t.Errorf("flag = %v, want it untouched", after)
+	}
+}
+
+func TestFlagd_ClearTargetingAndRestore(t *testing.T) {
+	r, cs := targetedRemediator(t)
+	alert := paymentAlert(map[string]string{"remediation_variant": "none", "remediation_targeting": "clear"})
+
+	res, err := r.Run(context.Background(), alert)
+	if err != nil || res.Outcome != OutcomeDisabled {
+		t.Fatalf("Run = (%s, %v), want disabled", res.Outcome, err)
+	}
+	if entry := paymentFlag(t, cs); entry["defaultVariant"] != "none" || entry["targeting"] != nil {
+		t.Errorf("flag = %v, want defaultVariant none and no targeting", entry)
+	}
+
+	if out, err := r.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestored {
+		t.Fatalf("Undo = (%s, %v), want restored", out, err)
+	}
+	if entry := paymentFlag(t, cs); entry["targeting"] == nil {
+		t.Errorf("flag = %v, want its fractional targeting back", entry)
+	}
+}
+
+func TestFlagd_OverrideTargetingAndRestore(t *testing.T) {
+	r, cs := targetedRemediator(t)
+	rule := `{"if": [{"==": [{"var": "region"}, "eu"]}, "50%", {"fractional": [["none", 90], ["50%", 10]]}]}`
+	alert := paymentAlert(map[string]string{"remediation_variant": "none", "remediation_targeting": "override",
+		"remediation_targeting_rule": rule})
+
+	res, err := r.Run(context.Background(), alert)
+	if err != nil || res.Outcome != OutcomeDisabled {
+		t.Fatalf("Run = (%s, %v), want disabled", res.Outcome, err)
+	}
+	entry := paymentFlag(t, cs)
+	if _, ok := entry["targeting"].(map[string]any)["if"]; !ok || entry["defaultVariant"] != "none" {
+		t.Errorf("flag = %v, want defaultVariant none and the override rule", entry)
+	}
+	if p, err := r.actions[actionFlagd].Plan(context.Background(), alert); err != nil || p.Noop != OutcomeAlreadyOff {
+		t.Errorf("Plan again = (%s, %v), want already_off", p.Noop, err)
+	}
+
+	if out, err := r.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestored {
+		t.Fatalf("Undo = (%s, %v), want restored", out, err)
+	}
+	if _, ok := paymentFlag(t, cs)["targeting"].(map[string]any)["fractional"]; !ok {
+		t.Errorf("flag = %v, want its fractional targeting back", paymentFlag(t, cs))
+	}
+}
+
+func TestFlagd_OverrideRuleMustReturnTheFlagsVariants(t *testing.T) {
+	r, cs := targetedRemediator(t)
+	before := paymentFlag(t, cs)
+
+	res, err := r.Run(context.Background(), paymentAlert(map[string]string{"remediation_variant": "none",
+		"remediation_targeting": "override", "remediation_targeting_rule": `{"fractional": [["none", 50], ["off", 50]]}`}))
+	if err != nil || res.Outcome != OutcomeVariantMissing {
+		t.Errorf("Run with an undefined variant in the rule = (%s, %v), want variant_missing", res.Outcome, err)
+	}
+	for _, rule := range []string{"", `"none"`, `{"var": "variant"}`, `{"if": [true, {"cat": ["no", "ne"]}]}`} {
+		alert := paymentAlert(map[string]string{"remediation_variant": "none", "remediation_targeting": "override",
+			"remediation_targeting_rule": rule})
+		if _, err := r.Run(context.Background(), alert); err == nil {
+			t.Errorf("Run with rule %q succeeded, want an error", rule)
+		}
+	}
+	if after := paymentFlag(t, cs); after["targeting"] == nil || !reflect.DeepEqual(after, before) {
+		t.Errorf("flag = %v, want it untouched", after)
+	}
+}
+
+func TestFlagd_KeepTargetingOnSafeDefaultIsNoop(t *testing.T) {
+	r, _ := targetedRemediator(t)
+
+	res, err := r.Run(context.Background(), paymentAlert(map[string]string{"remediation_variant": "none"}))
+	if err != nil || res.Outcome != OutcomeAlreadyOff {
+		t.Errorf("Run = (%s, %v), want already_off: keep mode only changes defaultVariant", res.Outcome, err)
+	}
+	if _, err := r.Run(context.Background(), paymentAlert(map[string]string{"remediation_targeting": "drop"})); err == nil {
+		t.Error("Run with an unknown remediation_targeting succeeded, want an error")
+	}
+}
+
+func TestDisableFlag_RetriesOnConflictWithoutLosingTheOtherEdit(t *testing.T) {
+	r, cs := newFakeRemediator(t, "on", false, time.Minute)
+	// Argo CD (say) writes the ConfigMap between our read and our update: the first update
+	// conflicts, and the retry must re-apply our change on top of theirs.
+	conflicted := false
+	cs.PrependReactor("update", "configmaps", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if conflicted {
+			return false, nil, nil
+		}
+		conflicted = true
+		cm, _ := cs.Tracker().Get(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, "otel-demo", "flagd-config")
+		edited := cm.(*corev1.ConfigMap).DeepCopy()
+		edited.Labels = map[string]string{"edited-by": "argocd"}
+		_ = cs.Tracker().Update(schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, edited, "otel-demo")
+		return true, nil, apierrors.NewConflict(schema.GroupResource{Resource: "configmaps"}, "flagd-config", nil)
+	})
+
+	got, err := disableFlag(r, "productCatalogFailure", "inc1")
+	if err != nil || got != OutcomeDisabled {
+		t.Fatalf("disable = (%s, %v), want disabled after a retry", got, err)
+	}
+	cm, _ := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
+	if !conflicted || cm.Labels["edited-by"] != "argocd" || currentVariant(t, cs) != "off" {
+		t.Errorf("after retry: labels %v, flag %q; want the concurrent edit kept and the flag off",
+			cm.Labels, currentVariant(t, cs))
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	tests := []struct {
+		manager string // field manager of the write that put the flag back on
+		want    Outcome
+	}{
+		{"argocd-controller", OutcomeRevertedByGitOps},
+		{"kustomize-controller", OutcomeRevertedByGitOps},
+		{"kubectl-edit", OutcomeReverted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.manager, func(t *testing.T) {
+			r, cs := newFakeRemediator(t, "on", false, time.Minute)
+			if _, err := disableFlag(r, "productCatalogFailure", "inc1"); err != nil {
+				t.Fatalf("disable: %v", err)
+			}
+			if got := r.CheckDrift(context.Background()); len(got) != 0 {
+				t.Fatalf("CheckDrift with the change in place = %+v, want nothing", got)
+			}
+
+			cm, _ := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
+			cm.Data["demo.flagd.json"] = flagdConfig("on") // synced back to git
+			if _, err := cs.CoreV1().ConfigMaps("otel-demo").Update(context.Background(), cm,
+				metav1.UpdateOptions{FieldManager: tt.manager}); err != nil {
+				t.Fatal(err)
+			}
+
+			got := r.CheckDrift(context.Background())
+			if len(got) != 1 || got[0].Outcome != tt.want || got[0].Err != nil {
+				t.Fatalf("CheckDrift = %+v, want one %s", got, tt.want)
+			}
+			if again := r.CheckDrift(context.Background()); len(again) != 0 {
+				t.Errorf("drift reported twice; the reverted plan should be forgotten")
+			}
+			if !r.cooling("HighErrorRate|inc1") {
+				t.Error("drift cleared the cooldown; the loop would flip the flag straight back")
+			}
+		})
+	}
+}
+
+func TestRevertedBy_TrackingMetadata(t *testing.T) {
+	// Without managedFields to go by, Argo CD's and Flux's tracking metadata decide.
+	argo := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Annotations: map[string]string{"argocd.argoproj.io/tracking-id": "demo:/ConfigMap:otel-demo/flagd-config"}}}
+	flux := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{
+		Labels: map[string]string{"kustomize.toolkit.fluxcd.io/name": "demo"}}}
+	for _, cm := range []*corev1.ConfigMap{argo, flux} {
+		if got := revertedBy(cm, "demo.flagd.json"); got != OutcomeRevertedByGitOps {
+			t.Errorf("revertedBy(%v) = %s, want reverted_by_gitops", cm.ObjectMeta, got)
+		}
+	}
+	if got := revertedBy(&corev1.ConfigMap{}, "demo.flagd.json"); got != OutcomeReverted {
+		t.Errorf("revertedBy(untracked) = %s, want reverted", got)
+	}
+}
+
+func TestParseFlagTargets(t *testing.T) {
+	def := FlagTarget{Namespace: "otel-demo", ConfigMap: "flagd-config", ConfigKey: "demo.flagd.json"}
+	got, err := ParseFlagTargets(`
+- name: payments
+  match: {team: payments}
+  namespace: payments
+  configKey: flags.json
+- {"name": "shop", "match": {"namespace": "shop"}, "namespace": "shop", "configMap": "flagd"}`, def)
+	if err != nil {
+		t.Fatalf("ParseFlagTargets: %v", err)
+	}
+	if len(got) != 2 || got[0].ConfigMap != "flagd-config" || got[0].ConfigKey != "flags.json" ||
+		got[1].ConfigMap != "flagd" || got[1].ConfigKey != "demo.flagd.json" || got[1].Match["namespace"] != "shop" {
+		t.Errorf("targets = %+v, want the defaults filled into whatever each leaves out", got)
+	}
+
+	for _, bad := range []string{
+		`[{match: {team: a}, namespace: a}]`,                                                     // no name
+		`[{name: a/b, match: {team: a}, namespace: a}]`,                                          // '/' in name
+		`[{name: a, match: {team: a}, namespace: a}, {name: a, match: {team: b}, namespace: b}]`, // duplicate
+		`[{name: a, namespace: a}]`,                                                              // no match
+		`[{name: a, match: {team: a}}]`,                                                          // no namespace
+		`[{name: a, matches: {team: a}, namespace: a}]`,                                          // typo'd key
+	} {
+		if _, err := ParseFlagTargets(bad, def); err == nil {
+			t.Errorf("ParseFlagTargets(%s) succeeded, want an error", bad)
+		}
+	}
+	if got, err := ParseFlagTargets("", def); err != nil || got != nil {
+		t.Errorf("ParseFlagTargets(\"\") = (%v, %v), want no targets", got, err)
+	}
+}
+
+func TestFlagd_AlertLabelsSelectTheFlagdInstance(t *testing.T) {
+	cs := fake.NewClientset(
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "otel-demo"},
+			Data:       map[string]string{"demo.flagd.json": flagdConfig("on")},
+		},
+		&corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "payments"},
+			Data:       map[string]string{"flags.json": flagdConfig("on")},
+		},
+	)
+	flagd := NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json")
+	flagd.Targets = []FlagTarget{{Name: "payments", Match: map[string]string{"team": "payments"},
+		Namespace: "payments", ConfigMap: "flagd-config", ConfigKey: "flags.json"}}
+	registry = NewRegistry(false, time.Minute)
+	registry.Register(actionFlagd, flagd)
+	auditLog = testAuditLog(t)
+	t.Cleanup(func() { registry, auditLog = nil, nil })
+
+	alert := Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "checkout", "team": "payments"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	}
+	res, err := registry.Run(context.Background(), alert)
+	if err != nil || res.Outcome != OutcomeDisabled || res.Plan.Target != "payments/productCatalogFailure" {
+		t.Fatalf("Run = (%s %s, %v), want payments/productCatalogFailure disabled", res.Outcome, res.Plan.Target, err)
+	}
+	recordAction(noop.Span{}, actionFlagd, alert.incidentKey(), res, nil)
+	if got, _ := auditLog.Query(AuditFilter{}); len(got) != 1 || got[0].Target != "payments/productCatalogFailure" ||
+		got[0].Params["target"] != "payments" || got[0].Params["namespace"] != "payments" {
+		t.Errorf("audit records = %+v, want one labelled with the payments target", got)
+	}
+
+	for tgt, want := range map[*FlagTarget]string{&flagd.Targets[0]: "off", &flagd.def: "on"} {
+		_, doc, err := flagd.load(context.Background(), *tgt)
+		if err != nil {
+			t.Fatalf("load %s: %v", tgt.Namespace, err)
+		}
+		if entry, _ := flagEntry(doc, "productCatalogFailure"); entry["defaultVariant"] != want {
+			t.Errorf("%s defaultVariant = %v, want %s", tgt.Namespace, entry["defaultVariant"], want)
+		}
+	}
+	if refs := flagd.Objects(res.Plan); refs[0].Namespace != "payments" {
+		t.Errorf("Objects = %+v, want the payments ConfigMap", refs)
+	}
+
+	// Undo goes back to the instance the plan was made against.
+	if out, err := flagd.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestored {
+		t.Fatalf("Undo = (%s, %v), want restored", out, err)
+	}
+
+	// Without a matching label the default instance is used, and the target is the bare flag.
+	delete(alert.Labels, "team")
+	alert.Labels["service"] = "frontend"
+	if res, err := registry.Run(context.Background(), alert); err != nil || res.Plan.Target != "productCatalogFailure" {
+		t.Errorf("Run without team = (%s, %v), want the default instance", res.Plan.Target, err)
+	}
+	if got := currentVariant(t, cs); got != "off" {
+		t.Errorf("default instance defaultVariant = %s, want off", got)
+	}
+}
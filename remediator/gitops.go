@@ -0,0 +1,314 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitFlags is the GitOps backend for the flagd action. When Argo CD syncs the flagd
+// ConfigMap from git, editing the ConfigMap only lasts until the next sync; instead the
+// change is committed to the file Argo CD syncs from — on a branch with a pull request for
+// a human to merge, or (Direct) straight onto the synced branch, optionally followed by an
+// Argo CD sync so it lands now rather than on the next poll. Git is driven through the git
+// CLI, so Repo can be any URL git can push to, including a local bare repository.
+type GitFlags struct {
+	Repo   string // clone URL, e.g. https://github.com/org/deploy.git, or a local path
+	Base   string // the branch Argo CD syncs from, e.g. main
+	Path   string // the flagd JSON file within the repo
+	Direct bool   // commit to Base instead of a branch + pull request
+	// Token authenticates HTTPS clones and pushes (GitHub: any token with contents:write).
+	Token       string
+	AuthorName  string
+	AuthorEmail string
+	// PR opens the pull request for a pushed branch; nil leaves opening it to humans.
+	PR PullRequester
+	// Sync asks Argo CD to sync after a direct commit; nil waits for its own polling.
+	Sync Syncer
+
+	mu sync.Mutex // one clone/commit/push at a time
+}
+
+// PullRequester opens a pull request from head into base and returns its URL.
+type PullRequester interface {
+	OpenPR(ctx context.Context, head, base, title, body string) (string, error)
+}
+
+// Syncer triggers a GitOps sync.
+type Syncer interface {
+	Sync(ctx context.Context) error
+}
+
+// pushAttempts bounds retries of a direct commit whose push lost a race with another.
+const pushAttempts = 5
+
+// Load reads the flagd document as committed on ref.
+func (g *GitFlags) Load(ctx context.Context, ref string) (map[string]any, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	dir, cleanup, err := g.clone(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return g.read(dir)
+}
+
+// Ref is the branch the change for p is committed to: Base when committing directly,
+// otherwise a branch of its own, named after the flag and incident so a re-run updates it
+// rather than opening another. verb tells a change from its undo.
+func (g *GitFlags) Ref(p Plan, verb string) string {
+	if g.Direct {
+		return g.Base
+	}
+	sum := sha256.Sum256([]byte(p.IncidentKey))
+	return fmt.Sprintf("remediator/%s-%s-%s", verb, p.Target, hex.EncodeToString(sum[:4]))
+}
+
+// Commit applies change to flag's entry on top of Base and pushes it to ref, opening a pull
+// request when ref isn't Base and syncing Argo CD when it is. A direct push that loses a
+// race with another commit is retried on a fresh clone, re-applying the change. It returns
+// the pull request URL, if one was opened.
+func (g *GitFlags) Commit(ctx context.Context, ref, flag, message string, change func(entry map[string]any) error) (string, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var err error
+	for range pushAttempts {
+		if err = g.commitOnce(ctx, ref, flag, message, change); !isRejectedPush(err) {
+			break
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+	if ref == g.Base {
+		if g.Sync != nil {
+			if err := g.Sync.Sync(ctx); err != nil {
+				// The commit is in git; Argo CD's polling will still apply it.
+				logger.Warnw("argo cd sync failed; waiting for its next poll", "error", err)
+			}
+		}
+		return "", nil
+	}
+	if g.PR == nil {
+		logger.Infow("pushed remediation branch; open a pull request to apply it", "branch", ref)
+		return "", nil
+	}
+	title, body, _ := strings.Cut(message, "\n")
+	return g.PR.OpenPR(ctx, ref, g.Base, title, strings.TrimSpace(body))
+}
+
+func (g *GitFlags) commitOnce(ctx context.Context, ref, flag, message string, change func(entry map[string]any) error) error {
+	dir, cleanup, err := g.clone(ctx, g.Base)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	doc, err := g.read(dir)
+	if err != nil {
+		return err
+	}
+	entry, ok := flagEntry(doc, flag)
+	if !ok {
+		return fmt.Errorf("flag %q not in %s", flag, g.Path)
+	}
+	if err := change(entry); err != nil {
+		return fmt.Errorf("flag %q: %w", flag, err)
+	}
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshal flagd config: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, g.Path), append(raw, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write %s: %w", g.Path, err)
+	}
+	if _, err := g.git(ctx, dir, "-c", "user.name="+g.AuthorName, "-c", "user.email="+g.AuthorEmail,
+		"commit", "--quiet", "--all", "--message", message); err != nil {
+		return err
+	}
+	push := []string{"push", "--quiet", "origin", "HEAD:refs/heads/" + ref}
+	if ref != g.Base {
+		push = append(push, "--force") // our own branch: the newest plan replaces the last
+	}
+	_, err = g.git(ctx, dir, push...)
+	return err
+}
+
+// clone makes a shallow clone of ref in a temporary directory, removed by cleanup.
+func (g *GitFlags) clone(ctx context.Context, ref string) (string, func(), error) {
+	dir, err := os.MkdirTemp("", "remediator-git-")
+	if err != nil {
+		return "", nil, fmt.Errorf("git workdir: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	if _, err := g.git(ctx, "", "clone", "--quiet", "--depth", "1", "--branch", ref, g.Repo, dir); err != nil {
+		cleanup()
+		return "", nil, err
+	}
+	return dir, cleanup, nil
+}
+
+func (g *GitFlags) read(dir string) (map[string]any, error) {
+	raw, err := os.ReadFile(filepath.Join(dir, g.Path))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", g.Path, err)
+	}
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil, fmt.Errorf("parse flagd config: %w", err)
+	}
+	return doc, nil
+}
+
+// errRejectedPush marks a push the remote refused because the branch moved on.
+type errRejectedPush struct{ error }
+
+func isRejectedPush(err error) bool {
+	_, ok := err.(errRejectedPush)
+	return ok
+}
+
+// git runs a git command in dir (if set), never prompting for credentials.
+func (g *GitFlags) git(ctx context.Context, dir string, args ...string) (string, error) {
+	sub := args[0]
+	for i := 0; i+1 < len(args) && args[i] == "-c"; i += 2 {
+		sub = args[i+2]
+	}
+	if g.Token != "" {
+		basic := base64.StdEncoding.EncodeToString([]byte("x-access-token:" + g.Token))
+		args = append([]string{"-c", "http.extraHeader=Authorization: Basic " + basic}, args...)
+	}
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_CONFIG_NOSYSTEM=1", "HOME="+os.TempDir())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		msg := strings.TrimSpace(string(out))
+		if g.Token != "" {
+			msg = strings.ReplaceAll(msg, g.Token, "***")
+		}
+		err = fmt.Errorf("git %s: %w: %s", sub, err, msg)
+		if strings.Contains(msg, "[rejected]") || strings.Contains(msg, "non-fast-forward") || strings.Contains(msg, "fetch first") {
+			return "", errRejectedPush{err}
+		}
+		return "", err
+	}
+	return string(out), nil
+}
+
+// GitHubPRs opens pull requests through the GitHub REST API.
+type GitHubPRs struct {
+	Repo    string // owner/name
+	Token   string
+	BaseURL string // https://api.github.com unless overridden (tests, GitHub Enterprise)
+	HTTP    *http.Client
+}
+
+// OpenPR opens a pull request. One already open for the branch isn't an error: a re-run
+// force-pushes the branch, which updates that pull request.
+func (g GitHubPRs) OpenPR(ctx context.Context, head, base, title, body string) (string, error) {
+	payload, _ := json.Marshal(map[string]string{"title": title, "head": head, "base": base, "body": body})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.BaseURL+"/repos/"+g.Repo+"/pulls", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+g.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	resp, err := g.HTTP.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("open pull request: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	raw, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode == http.StatusUnprocessableEntity && strings.Contains(string(raw), "already exists") {
+		return "", nil
+	}
+	if resp.StatusCode >= 300 {
+		return "", fmt.Errorf("open pull request: http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	var pr struct {
+		HTMLURL string `json:"html_url"`
+	}
+	_ = json.Unmarshal(raw, &pr)
+	return pr.HTMLURL, nil
+}
+
+// ArgoCDSync triggers a sync of one Argo CD Application through its API.
+type ArgoCDSync struct {
+	URL   string // e.g. https://argocd-server.argocd
+	App   string
+	Token string
+	HTTP  *http.Client
+}
+
+func (a ArgoCDSync) Sync(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		a.URL+"/api/v1/applications/"+a.App+"/sync", strings.NewReader(`{}`))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := a.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("argo cd sync %s: %w", a.App, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode >= 300 {
+		raw, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("argo cd sync %s: http %d: %s", a.App, resp.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	return nil
+}
+
+// githubRepo extracts owner/name from a GitHub clone URL, or "" for anything else.
+func githubRepo(url string) string {
+	rest, ok := strings.CutPrefix(url, "https://github.com/")
+	if !ok {
+		return ""
+	}
+	return strings.TrimSuffix(rest, ".git")
+}
+
+// initGitFlags builds the flagd action's git backend from FLAGD_GIT_* config, or returns
+// nil (edit the ConfigMap) when FLAGD_GIT_REPO is unset. Pull requests are opened through
+// GitHub when the repo is on github.com (or FLAGD_GIT_GITHUB_REPO names it), and a direct
+// commit triggers an Argo CD sync when ARGOCD_URL and ARGOCD_APP are set.
+func initGitFlags() *GitFlags {
+	repo := os.Getenv("FLAGD_GIT_REPO")
+	if repo == "" {
+		return nil
+	}
+	httpc := &http.Client{Timeout: 20 * time.Second}
+	token := envStr("FLAGD_GIT_TOKEN", os.Getenv("GITHUB_TOKEN"))
+	g := &GitFlags{
+		Repo:        repo,
+		Base:        envStr("FLAGD_GIT_BRANCH", "main"),
+		Path:        envStr("FLAGD_GIT_PATH", "deploy/flagd/demo.flagd.json"),
+		Direct:      envStr("FLAGD_GIT_MODE", "pr") == "direct",
+		Token:       token,
+		AuthorName:  envStr("FLAGD_GIT_AUTHOR_NAME", "OmniObserve remediator"),
+		AuthorEmail: envStr("FLAGD_GIT_AUTHOR_EMAIL", "remediator@omniobserve.local"),
+	}
+	if gh := envStr("FLAGD_GIT_GITHUB_REPO", githubRepo(repo)); gh != "" && token != "" {
+		g.PR = GitHubPRs{Repo: gh, Token: token, BaseURL: "https://api.github.com", HTTP: httpc}
+	}
+	if url, app := os.Getenv("ARGOCD_URL"), os.Getenv("ARGOCD_APP"); url != "" && app != "" {
+		g.Sync = ArgoCDSync{URL: url, App: app, Token: os.Getenv("ARGOCD_TOKEN"), HTTP: httpc}
+	}
+	logger.Infow("flagd git backend", "repo", repo, "branch", g.Base, "path", g.Path,
+		"direct", g.Direct, "pullRequests", g.PR != nil, "argoSync", g.Sync != nil)
+	return g
+}
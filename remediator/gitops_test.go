@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const gitFlagdPath = "flagd/demo.flagd.json"
+
+// runGit runs git in dir with a fixed identity, failing the test on error.
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+t.TempDir())
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return string(out)
+}
+
+// bareRepo is a local bare repository whose main branch holds flagdConfig(variant) at
+// gitFlagdPath, standing in for the repo Argo CD syncs from.
+func bareRepo(t *testing.T, variant string) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	bare := filepath.Join(t.TempDir(), "deploy.git")
+	runGit(t, "", "init", "--quiet", "--bare", "--initial-branch=main", bare)
+	pushFile(t, bare, gitFlagdPath, flagdConfig(variant))
+	return bare
+}
+
+// pushFile commits content at path onto main — another writer's commit.
+func pushFile(t *testing.T, bare, path, content string) {
+	t.Helper()
+	work := t.TempDir()
+	runGit(t, "", "clone", "--quiet", bare, work)
+	runGit(t, work, "checkout", "--quiet", "-B", "main")
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(work, path)), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(work, path), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, work, "add", path)
+	runGit(t, work, "commit", "--quiet", "-m", "update "+path)
+	runGit(t, work, "push", "--quiet", "origin", "HEAD:main")
+}
+
+// fakePRs records the pull requests it's asked to open.
+type fakePRs struct{ heads, bases, titles []string }
+
+func (f *fakePRs) OpenPR(_ context.Context, head, base, title, _ string) (string, error) {
+	f.heads, f.bases, f.titles = append(f.heads, head), append(f.bases, base), append(f.titles, title)
+	return "https://github.com/org/deploy/pull/1", nil
+}
+
+// gitRemediator is a registry whose flagd action commits to a bare repo seeded with
+// productCatalogFailure on "on". There is no cluster: the git backend is the only store.
+func gitRemediator(t *testing.T, direct bool) (*Registry, *FlagRemediator, *GitFlags) {
+	t.Helper()
+	g := &GitFlags{Repo: bareRepo(t, "on"), Base: "main", Path: gitFlagdPath, Direct: direct,
+		AuthorName: "remediator", AuthorEmail: "remediator@example.com"}
+	flagd := NewFlagRemediator(nil, "otel-demo", "flagd-config", "demo.flagd.json")
+	flagd.Git = g
+	r := NewRegistry(false, time.Minute)
+	r.Register(actionFlagd, flagd)
+	return r, flagd, g
+}
+
+// gitVariant reads productCatalogFailure's defaultVariant as committed on ref.
+func gitVariant(t *testing.T, g *GitFlags, ref string) string {
+	t.Helper()
+	doc, err := g.Load(context.Background(), ref)
+	if err != nil {
+		t.Fatalf("load %s: %v", ref, err)
+	}
+	entry, _ := flagEntry(doc, "productCatalogFailure")
+	v, _ := entry["defaultVariant"].(string)
+	return v
+}
+
+func TestGitFlags_DirectCommitSyncsArgoCD(t *testing.T) {
+	r, flagd, g := gitRemediator(t, true)
+	var synced string
+	argo := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		synced = req.Method + " " + req.URL.Path + " " + req.Header.Get("Authorization")
+	}))
+	defer argo.Close()
+	g.Sync = ArgoCDSync{URL: argo.URL, App: "otel-demo", Token: "argo-token", HTTP: argo.Client()}
+
+	res, err := r.Run(context.Background(), Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "product-catalog"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	})
+	if err != nil || res.Outcome != OutcomeDisabled {
+		t.Fatalf("Run = (%s, %v), want disabled", res.Outcome, err)
+	}
+	if v := gitVariant(t, g, "main"); v != "off" {
+		t.Errorf("main has defaultVariant %q, want off", v)
+	}
+	if want := "POST /api/v1/applications/otel-demo/sync Bearer argo-token"; synced != want {
+		t.Errorf("argo cd request = %q, want %q", synced, want)
+	}
+	if log := runGit(t, g.Repo, "log", "-1", "--format=%an%n%B", "main"); !strings.Contains(log, "remediator\nremediator: disabled flagd flag productCatalogFailure") ||
+		!strings.Contains(log, "Incident: HighErrorRate|product-catalog") {
+		t.Errorf("commit = %q, want the remediator's description and incident key", log)
+	}
+
+	if out, err := flagd.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestored {
+		t.Fatalf("Undo = (%s, %v), want restored", out, err)
+	}
+	if v := gitVariant(t, g, "main"); v != "on" {
+		t.Errorf("after undo main has defaultVariant %q, want on", v)
+	}
+}
+
+func TestGitFlags_PullRequestLeavesBaseUntouched(t *testing.T) {
+	r, flagd, g := gitRemediator(t, false)
+	prs := &fakePRs{}
+	g.PR = prs
+
+	res, err := r.Run(context.Background(), Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "product-catalog"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	})
+	if err != nil || res.Outcome != OutcomePROpened || !res.Executed {
+		t.Fatalf("Run = (%+v, %v), want an executed pr_opened", res, err)
+	}
+	branch := g.Ref(res.Plan, "disable")
+	if !strings.HasPrefix(branch, "remediator/disable-productCatalogFailure-") {
+		t.Errorf("branch = %q, want one named after the flag", branch)
+	}
+	if v := gitVariant(t, g, "main"); v != "on" {
+		t.Errorf("main has defaultVariant %q, want it unchanged until the PR merges", v)
+	}
+	if v := gitVariant(t, g, branch); v != "off" {
+		t.Errorf("%s has defaultVariant %q, want off", branch, v)
+	}
+	if len(prs.heads) != 1 || prs.heads[0] != branch || prs.bases[0] != "main" ||
+		prs.titles[0] != "remediator: disabled flagd flag productCatalogFailure" {
+		t.Errorf("pull requests = %+v, want one from %s into main", prs, branch)
+	}
+
+	// Nothing was merged: there's nothing to restore, and nothing has drifted.
+	if out, err := flagd.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestoreSkipped {
+		t.Errorf("Undo before merge = (%s, %v), want restore_skipped", out, err)
+	}
+	if out, err := flagd.Drifted(context.Background(), res.Plan); err != nil || out != "" {
+		t.Errorf("Drifted = (%q, %v), want no drift for an unmerged PR", out, err)
+	}
+}
+
+func TestGitFlags_DirectPushRetriesAfterLosingARace(t *testing.T) {
+	_, flagd, g := gitRemediator(t, true)
+	p, err := flagd.Plan(context.Background(), Alert{Annotations: map[string]string{"remediation_flag": "productCatalogFailure"}})
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+
+	raced := false
+	_, err = g.Commit(context.Background(), "main", p.Target, "remediator: test", func(entry map[string]any) error {
+		if !raced {
+			raced = true // someone else pushes between our clone and our push
+			pushFile(t, g.Repo, "README.md", "hello\n")
+		}
+		return setDefaultVariant(entry, "off")
+	})
+	if err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	if v := gitVariant(t, g, "main"); v != "off" {
+		t.Errorf("main has defaultVariant %q, want off", v)
+	}
+	if files := runGit(t, g.Repo, "ls-tree", "--name-only", "main"); !strings.Contains(files, "README.md") {
+		t.Errorf("main = %q, want the other writer's commit kept", files)
+	}
+}
+
+func TestGitFlags_RevertInGitIsDrift(t *testing.T) {
+	r, flagd, g := gitRemediator(t, true)
+	res, err := r.Run(context.Background(), Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "HighErrorRate", "service": "product-catalog"},
+		Annotations: map[string]string{"remediation_flag": "productCatalogFailure"},
+	})
+	if err != nil || res.Outcome != OutcomeDisabled {
+		t.Fatalf("Run = (%s, %v), want disabled", res.Outcome, err)
+	}
+	if out, err := flagd.Drifted(context.Background(), res.Plan); err != nil || out != "" {
+		t.Fatalf("Drifted = (%q, %v), want none yet", out, err)
+	}
+	pushFile(t, g.Repo, gitFlagdPath, flagdConfig("on"))
+	if out, err := flagd.Drifted(context.Background(), res.Plan); err != nil || out != OutcomeReverted {
+		t.Errorf("Drifted after a revert commit = (%q, %v), want reverted", out, err)
+	}
+}
+
+func TestGitHubPRs_OpenPR(t *testing.T) {
+	var got map[string]string
+	status := http.StatusCreated
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/repos/org/deploy/pulls" || req.Header.Get("Authorization") != "Bearer gh-token" {
+			t.Errorf("request = %s %s, want the pulls API with the token", req.Method, req.URL.Path)
+		}
+		_ = json.NewDecoder(req.Body).Decode(&got)
+		w.WriteHeader(status)
+		if status == http.StatusCreated {
+			_, _ = w.Write([]byte(`{"html_url": "https://github.com/org/deploy/pull/7"}`))
+		} else {
+			_, _ = w.Write([]byte(`{"message": "Validation Failed", "errors": [{"message": "A pull request already exists for org:remediator/x."}]}`))
+		}
+	}))
+	defer srv.Close()
+	prs := GitHubPRs{Repo: "org/deploy", Token: "gh-token", BaseURL: srv.URL, HTTP: srv.Client()}
+
+	url, err := prs.OpenPR(context.Background(), "remediator/x", "main", "title", "body")
+	if err != nil || url != "https://github.com/org/deploy/pull/7" {
+		t.Errorf("OpenPR = (%q, %v), want the new PR's URL", url, err)
+	}
+	if got["head"] != "remediator/x" || got["base"] != "main" {
+		t.Errorf("payload = %v, want head remediator/x into main", got)
+	}
+
+	status = http.StatusUnprocessableEntity
+	if _, err := prs.OpenPR(context.Background(), "remediator/x", "main", "title", "body"); err != nil {
+		t.Errorf("OpenPR for a branch with an open PR = %v, want no error", err)
+	}
+}
+
+func TestGithubRepo(t *testing.T) {
+	for in, want := range map[string]string{
+		"https://github.com/org/deploy.git": "org/deploy",
+		"https://github.com/org/deploy":     "org/deploy",
+		"git@github.com:org/deploy.git":     "",
+		"/srv/git/deploy.git":               "",
+	} {
+		if got := githubRepo(in); got != want {
+			t.Errorf("githubRepo(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,653 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/tomjga/OmniObserve/remediator/internal/sink"
+)
+
+// incidents follows each incident the remediator is asked to act on through its
+// lifecycle. Nil (observe-only) means alerts are handled one webhook at a time.
+var incidents *Incidents
+
+// IncidentState is where an incident is in its lifecycle:
+//
+//	firing → mitigating → mitigated → resolved
+//	              ↓                      ↑
+//	         ineffective ────────────────┘
+//
+// An incident that fires again after resolving goes back to firing (a flap); one whose
+// fix is reverted goes back to firing; so does one whose fix can't be judged against the
+// SLO (no Prometheus), leaving the cooldown to pace further actions as before.
+type IncidentState string
+
+const (
+	IncidentFiring      IncidentState = "firing"      // alerting; nothing has been done that is known to help
+	IncidentMitigating  IncidentState = "mitigating"  // an action executed; its effect on the SLO is being checked
+	IncidentMitigated   IncidentState = "mitigated"   // the SLO recovered after the action
+	IncidentIneffective IncidentState = "ineffective" // the SLO kept burning after the action; escalated
+	IncidentResolved    IncidentState = "resolved"    // Alertmanager sent resolved
+)
+
+var incidentStates = []IncidentState{IncidentFiring, IncidentMitigating, IncidentMitigated, IncidentIneffective, IncidentResolved}
+
+// acts reports whether a firing notification in this state runs the incident's action:
+// not while the last one is being checked, nor once it has worked.
+func (s IncidentState) acts() bool { return s == IncidentFiring || s == IncidentIneffective }
+
+// incidentTransitions: "how do incidents move?" — many resolved→firing is flapping.
+var incidentTransitions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "remediator_incident_transitions_total",
+		Help: "Incident lifecycle transitions, by from and to state (from=new for a new incident).",
+	},
+	[]string{"from", "to"},
+)
+
+func init() {
+	prometheus.MustRegister(incidentTransitions)
+	for _, s := range incidentStates {
+		prometheus.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "remediator_incidents",
+			Help:        "Incidents currently in each lifecycle state.",
+			ConstLabels: prometheus.Labels{"state": string(s)},
+		}, func() float64 {
+			if incidents == nil {
+				return 0
+			}
+			return float64(incidents.count(s))
+		}))
+	}
+}
+
+// Incident is one incidentKey's story: when it was first and last seen, the alerts
+// received, every decision the remediator took for it, the RCA drafted and where it was
+// published, and each state it went through.
+type Incident struct {
+	Key        string           `json:"key"`
+	Alertname  string           `json:"alertname"`
+	Service    string           `json:"service"`
+	State      IncidentState    `json:"state"`
+	FirstSeen  time.Time        `json:"firstSeen"`
+	LastSeen   time.Time        `json:"lastSeen"`
+	ResolvedAt time.Time        `json:"resolvedAt,omitzero"`
+	Flaps      int              `json:"flaps,omitempty"` // times it fired again after resolving
+	Alerts     []ReceivedAlert  `json:"alerts,omitempty"`
+	Actions    []IncidentAction `json:"actions,omitempty"`
+	RCA        *IncidentRCA     `json:"rca,omitempty"`
+	Publishes  []Publish        `json:"publishes,omitempty"`
+	History    []Transition     `json:"history"`
+
+	Alert     Alert `json:"-"` // the latest notification, for what follows a transition
+	rcaQueued bool
+}
+
+// ReceivedAlert is one Alertmanager notification for an incident.
+type ReceivedAlert struct {
+	At    time.Time `json:"at"`
+	Alert Alert     `json:"alert"`
+}
+
+// IncidentAction is one decision taken for an incident, as recorded by recordAction.
+type IncidentAction struct {
+	At         time.Time `json:"at"`
+	Action     string    `json:"action"`
+	Target     string    `json:"target,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Executed   bool      `json:"executed,omitempty"`
+	ErrorRatio float64   `json:"errorRatio,omitempty"` // for a verification, the ratio it judged by
+	Error      string    `json:"error,omitempty"`
+}
+
+// IncidentRCA is the copilot's draft for an incident and what it was grounded in: the
+// Prometheus evidence gathered and the prior incidents retrieved from the corpus. A draft
+// the LLM failed on keeps its grounding and the error; a retry replaces it.
+type IncidentRCA struct {
+	At        time.Time   `json:"at"`
+	Model     string      `json:"model,omitempty"`
+	Body      string      `json:"body,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	Evidence  []Evidence  `json:"evidence"`
+	Precedent []Precedent `json:"precedent"`
+}
+
+// Evidence is one Prometheus query result the copilot was shown.
+type Evidence struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Precedent is a prior incident the copilot retrieved from the corpus.
+type Precedent struct {
+	ID    string   `json:"id"`
+	Title string   `json:"title"`
+	Tags  []string `json:"tags,omitempty"`
+}
+
+// Publish is one sink's result for an RCA or an escalation.
+type Publish struct {
+	At    time.Time `json:"at"`
+	Kind  string    `json:"kind"` // "rca" or "escalation"
+	Sink  string    `json:"sink"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Transition is one change of an incident's state, and what caused it.
+type Transition struct {
+	At     time.Time     `json:"at"`
+	From   IncidentState `json:"from,omitempty"` // empty when the incident was opened
+	To     IncidentState `json:"to"`
+	Reason string        `json:"reason"` // the notification ("firing", "resolved") or decision outcome
+}
+
+// maxIncidentActions bounds an incident's decision list and maxIncidentAlerts the
+// notifications kept — only those that changed its status (Alertmanager repeats a firing
+// alert), and always the first; the audit log keeps every decision.
+const (
+	maxIncidentActions = 100
+	maxIncidentAlerts  = 50
+)
+
+// Incidents tracks incidents by key. Resolved incidents are forgotten after Retention,
+// and beyond MaxIncidents the longest-resolved go first. It lives in memory on the acting
+// replica: after a restart or failover an incident starts again as firing, and the
+// persisted cooldown stops that from acting twice.
+type Incidents struct {
+	Retention    time.Duration
+	MaxIncidents int
+
+	mu    sync.Mutex
+	byKey map[string]*Incident
+	now   func() time.Time
+}
+
+// NewIncidents builds an empty tracker that keeps resolved incidents for a day.
+func NewIncidents() *Incidents {
+	return &Incidents{Retention: 24 * time.Hour, MaxIncidents: 1000, byKey: map[string]*Incident{}, now: time.Now}
+}
+
+// Observe applies an Alertmanager notification: a firing alert opens the incident (or
+// reopens a resolved one), a resolved alert resolves it. It returns the incident and, if
+// the state changed, the transition.
+func (s *Incidents) Observe(alert Alert) (Incident, *Transition) {
+	if s == nil {
+		return Incident{State: IncidentFiring}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune()
+	now := s.now()
+	inc := s.get(alert.incidentKey(), now)
+	inc.Alert, inc.LastSeen = alert, now
+	if n := len(inc.Alerts); n == 0 || inc.Alerts[n-1].Alert.Status != alert.Status {
+		inc.Alerts = append(inc.Alerts, ReceivedAlert{At: now, Alert: alert})
+		if len(inc.Alerts) > maxIncidentAlerts {
+			inc.Alerts = slices.Delete(inc.Alerts, 1, 2) // keep the first: when it fired
+		}
+	}
+	var tr *Transition
+	switch alert.Status {
+	case "firing":
+		if inc.State == IncidentResolved {
+			inc.Flaps++
+			inc.ResolvedAt = time.Time{}
+			tr = s.move(inc, IncidentFiring, "firing", now)
+		}
+	case "resolved":
+		if inc.State != IncidentResolved {
+			inc.ResolvedAt = now
+			tr = s.move(inc, IncidentResolved, "resolved", now)
+		}
+	}
+	return inc.copy(), tr
+}
+
+// Record adds a decision to its incident — opening one if the key is unknown, e.g. an
+// undo after a restart — and moves it on: an executed action to mitigating, a
+// verification to mitigated or ineffective, and a reverted fix, or one nobody can judge,
+// back to firing. A resolved incident only collects the decision. It returns the
+// incident and, if the state changed, the transition.
+func (s *Incidents) Record(key string, res Result, err error) (Incident, *Transition) {
+	if s == nil {
+		return Incident{}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	inc := s.get(key, now)
+	if res.Outcome != OutcomeCooldown { // a repeat notification, not a decision worth a line
+		a := IncidentAction{At: now, Action: res.Plan.Action, Target: res.Plan.Target, Outcome: string(res.Outcome),
+			Executed: res.Executed, ErrorRatio: res.ErrorRatio}
+		if err != nil {
+			a.Outcome, a.Error = "error", err.Error()
+		}
+		inc.Actions = capped(append(inc.Actions, a), maxIncidentActions)
+	}
+
+	to := inc.State
+	switch {
+	case err != nil || inc.State == IncidentResolved:
+	case res.Executed && res.Outcome != OutcomePROpened: // a PR changes nothing until merged
+		to = IncidentMitigating
+	case res.Outcome == OutcomeVerified:
+		to = IncidentMitigated
+	case res.Outcome == OutcomeIneffective:
+		to = IncidentIneffective
+	case res.Outcome == OutcomeUnverified, res.Outcome == OutcomeReverted, res.Outcome == OutcomeRevertedByGitOps:
+		to = IncidentFiring
+	}
+	var tr *Transition
+	if to != inc.State {
+		tr = s.move(inc, to, string(res.Outcome), now)
+	}
+	return inc.copy(), tr
+}
+
+// requestRCA reports whether key's RCA still needs drafting, and marks it as underway,
+// so an incident that is mitigated twice gets one RCA.
+func (s *Incidents) requestRCA(key string) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inc, ok := s.byKey[key]
+	if !ok || inc.rcaQueued {
+		return !ok
+	}
+	inc.rcaQueued = true
+	return true
+}
+
+// SetRCA attaches the drafted RCA, or the failed attempt, to its incident.
+func (s *Incidents) SetRCA(key string, rca IncidentRCA) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if inc, ok := s.byKey[key]; ok {
+		inc.RCA = &rca
+	}
+}
+
+// Published records each sink's result for an RCA or escalation of key's incident.
+func (s *Incidents) Published(key, kind string, results []sink.Result) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inc, ok := s.byKey[key]
+	if !ok {
+		return
+	}
+	now := s.now()
+	for _, r := range results {
+		p := Publish{At: now, Kind: kind, Sink: r.Sink}
+		if r.Error != nil {
+			p.Error = r.Error.Error()
+		}
+		inc.Publishes = append(inc.Publishes, p)
+	}
+}
+
+// Get returns the incident for key.
+func (s *Incidents) Get(key string) (Incident, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inc, ok := s.byKey[key]
+	if !ok {
+		return Incident{}, false
+	}
+	return inc.copy(), true
+}
+
+// IncidentFilter selects incidents for List. Zero fields match everything; Since and
+// Until select incidents seen at some point in that window. Limit 0 means no limit.
+type IncidentFilter struct {
+	State   IncidentState
+	Service string
+	Since   time.Time
+	Until   time.Time
+	Offset  int
+	Limit   int
+}
+
+func (f IncidentFilter) match(inc *Incident) bool {
+	return (f.State == "" || inc.State == f.State) &&
+		(f.Service == "" || inc.Service == f.Service) &&
+		(f.Since.IsZero() || !inc.LastSeen.Before(f.Since)) &&
+		(f.Until.IsZero() || !inc.FirstSeen.After(f.Until))
+}
+
+// List returns one page of the incidents matching f, most recently seen first, and how
+// many match in all.
+func (s *Incidents) List(f IncidentFilter) ([]Incident, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []Incident
+	for _, inc := range s.byKey {
+		if f.match(inc) {
+			out = append(out, inc.copy())
+		}
+	}
+	slices.SortFunc(out, func(a, b Incident) int {
+		if c := b.LastSeen.Compare(a.LastSeen); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	total := len(out)
+	out = out[min(f.Offset, total):]
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, total
+}
+
+func (s *Incidents) count(state IncidentState) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, inc := range s.byKey {
+		if inc.State == state {
+			n++
+		}
+	}
+	return n
+}
+
+// get returns key's incident, opening it as firing if there is none; s.mu must be held.
+func (s *Incidents) get(key string, now time.Time) *Incident {
+	if inc, ok := s.byKey[key]; ok {
+		return inc
+	}
+	alertname, service, _ := strings.Cut(key, "|")
+	inc := &Incident{Key: key, Alertname: alertname, Service: service, FirstSeen: now, LastSeen: now,
+		Alert: Alert{Labels: map[string]string{"alertname": alertname, "service": service}}}
+	s.byKey[key] = inc
+	s.move(inc, IncidentFiring, "opened", now)
+	return inc
+}
+
+// move records inc's transition to state; s.mu must be held.
+func (s *Incidents) move(inc *Incident, to IncidentState, reason string, now time.Time) *Transition {
+	tr := Transition{At: now, From: inc.State, To: to, Reason: reason}
+	from := string(inc.State)
+	if from == "" {
+		from = "new"
+	}
+	incidentTransitions.WithLabelValues(from, string(to)).Inc()
+	inc.State = to
+	inc.History = append(inc.History, tr)
+	return &tr
+}
+
+// prune forgets incidents resolved longer than Retention ago, then the longest-resolved
+// beyond MaxIncidents; s.mu must be held.
+func (s *Incidents) prune() {
+	now := s.now()
+	var resolved []*Incident
+	for key, inc := range s.byKey {
+		if inc.State != IncidentResolved {
+			continue
+		}
+		if now.Sub(inc.ResolvedAt) > s.Retention {
+			delete(s.byKey, key)
+			continue
+		}
+		resolved = append(resolved, inc)
+	}
+	slices.SortFunc(resolved, func(a, b *Incident) int { return a.ResolvedAt.Compare(b.ResolvedAt) })
+	for _, inc := range resolved {
+		if len(s.byKey) < s.MaxIncidents {
+			break
+		}
+		delete(s.byKey, inc.Key)
+	}
+}
+
+// copy is a snapshot of inc that shares nothing mutable with it.
+func (inc *Incident) copy() Incident {
+	c := *inc
+	c.Alerts = slices.Clone(inc.Alerts)
+	c.Actions = slices.Clone(inc.Actions)
+	c.Publishes = slices.Clone(inc.Publishes)
+	c.History = slices.Clone(inc.History)
+	if inc.RCA != nil {
+		rca := *inc.RCA
+		c.RCA = &rca
+	}
+	return c
+}
+
+// capped drops the oldest entries of s beyond n.
+func capped[T any](s []T, n int) []T {
+	if len(s) > n {
+		return slices.Delete(s, 0, len(s)-n)
+	}
+	return s
+}
+
+// TimelineEvent is one entry of an incident's timeline, as the UI draws it.
+type TimelineEvent struct {
+	At     time.Time `json:"at"`
+	Kind   string    `json:"kind"` // alert, action, verification, rca or publish
+	Title  string    `json:"title"`
+	Detail string    `json:"detail,omitempty"`
+	Failed bool      `json:"failed,omitempty"` // an error, an ineffective action, a failed publish
+}
+
+// Timeline is the incident end to end in time order: when the alert fired (and fired
+// again) and resolved, each action's outcome, each verification, the RCA draft and each
+// sink it or an escalation was published to. Repeats of a firing alert are left out.
+func (inc Incident) Timeline() []TimelineEvent {
+	var out []TimelineEvent
+	status := ""
+	for _, a := range inc.Alerts {
+		if a.Alert.Status == status {
+			continue
+		}
+		status = a.Alert.Status
+		e := TimelineEvent{At: a.At, Kind: "alert", Title: "Alert " + status, Detail: a.Alert.Annotations["summary"]}
+		if status == "firing" {
+			e.Title = "Alert fired"
+		}
+		out = append(out, e)
+	}
+	for _, a := range inc.Actions {
+		e := TimelineEvent{At: a.At, Kind: "action", Title: a.Action + ": " + a.Outcome, Detail: a.Target, Failed: a.Error != ""}
+		switch Outcome(a.Outcome) {
+		case OutcomeVerified, OutcomeIneffective, OutcomeUnverified:
+			e.Kind, e.Title = "verification", "Verification: "+a.Outcome
+			e.Detail = "no SLO data to judge by"
+			if a.Outcome != string(OutcomeUnverified) {
+				e.Detail = fmt.Sprintf("error ratio %.3f", a.ErrorRatio)
+			}
+			e.Failed = a.Outcome == string(OutcomeIneffective)
+		}
+		if a.Error != "" {
+			e.Detail = a.Error
+		}
+		out = append(out, e)
+	}
+	if inc.RCA != nil {
+		e := TimelineEvent{At: inc.RCA.At, Kind: "rca", Title: "RCA drafted", Detail: inc.RCA.Model}
+		if inc.RCA.Error != "" {
+			e.Title, e.Detail, e.Failed = "RCA draft failed", inc.RCA.Error, true
+		}
+		out = append(out, e)
+	}
+	for _, p := range inc.Publishes {
+		e := TimelineEvent{At: p.At, Kind: "publish", Title: "Published " + p.Kind + " to " + p.Sink}
+		if p.Error != "" {
+			e.Title, e.Detail, e.Failed = "Publishing "+p.Kind+" to "+p.Sink+" failed", p.Error, true
+		}
+		out = append(out, e)
+	}
+	slices.SortStableFunc(out, func(a, b TimelineEvent) int { return a.At.Compare(b.At) })
+	return out
+}
+
+// IncidentDetail is GET /incidents/{key}: the incident and its timeline.
+type IncidentDetail struct {
+	Incident
+	Timeline []TimelineEvent `json:"timeline"`
+}
+
+// IncidentSummary is an incident as GET /incidents lists it; GET /incidents/{key} has the rest.
+type IncidentSummary struct {
+	Key        string        `json:"key"`
+	Alertname  string        `json:"alertname"`
+	Service    string        `json:"service"`
+	State      IncidentState `json:"state"`
+	FirstSeen  time.Time     `json:"firstSeen"`
+	LastSeen   time.Time     `json:"lastSeen"`
+	ResolvedAt time.Time     `json:"resolvedAt,omitzero"`
+	Flaps      int           `json:"flaps,omitempty"`
+	Actions    int           `json:"actions"`              // decisions recorded
+	LastAction string        `json:"lastAction,omitempty"` // the latest decision's outcome
+	RCA        bool          `json:"rca"`                  // an RCA was drafted
+}
+
+// IncidentPage is one page of GET /incidents.
+type IncidentPage struct {
+	Incidents []IncidentSummary `json:"incidents"`
+	Total     int               `json:"total"` // incidents matching the filter, across pages
+	Offset    int               `json:"offset"`
+	Limit     int               `json:"limit"`
+}
+
+func (inc Incident) summary() IncidentSummary {
+	sum := IncidentSummary{Key: inc.Key, Alertname: inc.Alertname, Service: inc.Service, State: inc.State,
+		FirstSeen: inc.FirstSeen, LastSeen: inc.LastSeen, ResolvedAt: inc.ResolvedAt, Flaps: inc.Flaps,
+		Actions: len(inc.Actions), RCA: inc.RCA != nil && inc.RCA.Body != ""}
+	if n := len(inc.Actions); n > 0 {
+		sum.LastAction = inc.Actions[n-1].Outcome
+	}
+	return sum
+}
+
+// defaultIncidentLimit and maxIncidentLimit bound a page of GET /incidents.
+const (
+	defaultIncidentLimit = 50
+	maxIncidentLimit     = 500
+)
+
+// listIncidentsHandler serves GET /incidents.
+//
+// @Summary      List incidents
+// @Description  Incidents tracked by the acting replica (a follower proxies the request to it), most recently seen first. since/until select incidents seen at some point in that window.
+// @Tags         incidents
+// @Produce      json
+// @Security     AdminToken
+// @Param        status   query     string             false  "Lifecycle state"  Enums(firing, mitigating, mitigated, ineffective, resolved)
+// @Param        service  query     string             false  "Service label"
+// @Param        since    query     string             false  "RFC 3339 timestamp"
+// @Param        until    query     string             false  "RFC 3339 timestamp"
+// @Param        offset   query     int                false  "Incidents to skip"    default(0)
+// @Param        limit    query     int                false  "Page size (max 500)"  default(50)
+// @Success      200      {object}  IncidentPage
+// @Failure      400      {object}  map[string]string  "bad filter"
+// @Failure      401      {object}  map[string]string  "missing or wrong admin token"
+// @Failure      404      {object}  map[string]string  "observe-only: no incidents tracked"
+// @Failure      503      {object}  map[string]string  "a follower that can't reach the leader"
+// @Router       /incidents [get]
+func listIncidentsHandler(c *gin.Context) {
+	if !incidentsServed(c) {
+		return
+	}
+	f := IncidentFilter{State: IncidentState(c.Query("status")), Service: c.Query("service"), Limit: defaultIncidentLimit}
+	if f.State != "" && !slices.Contains(incidentStates, f.State) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown status " + string(f.State)})
+		return
+	}
+	for param, dst := range map[string]*time.Time{"since": &f.Since, "until": &f.Until} {
+		if v := c.Query(param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be RFC 3339"})
+				return
+			}
+			*dst = t
+		}
+	}
+	for param, dst := range map[string]*int{"offset": &f.Offset, "limit": &f.Limit} {
+		if v := c.Query(param); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a non-negative integer"})
+				return
+			}
+			*dst = n
+		}
+	}
+	if f.Limit == 0 || f.Limit > maxIncidentLimit {
+		f.Limit = maxIncidentLimit
+	}
+
+	list, total := incidents.List(f)
+	page := IncidentPage{Incidents: []IncidentSummary{}, Total: total, Offset: f.Offset, Limit: f.Limit}
+	for _, inc := range list {
+		page.Incidents = append(page.Incidents, inc.summary())
+	}
+	c.JSON(http.StatusOK, page)
+}
+
+// getIncidentHandler serves GET /incidents/{key}.
+//
+// @Summary      Inspect an incident
+// @Description  The alerts received, actions and outcomes, the RCA draft with the evidence and precedent it was grounded in, per-sink publish results, the state history, and all of it as one timeline. The key is alertname|service, URL-encoded (| is %7C).
+// @Tags         incidents
+// @Produce      json
+// @Security     AdminToken
+// @Param        key  path      string             true  "Incident key, alertname|service"
+// @Success      200  {object}  IncidentDetail
+// @Failure      401  {object}  map[string]string  "missing or wrong admin token"
+// @Failure      404  {object}  map[string]string  "no such incident"
+// @Failure      503  {object}  map[string]string  "a follower that can't reach the leader"
+// @Router       /incidents/{key} [get]
+func getIncidentHandler(c *gin.Context) {
+	if !incidentsServed(c) {
+		return
+	}
+	inc, ok := incidents.Get(c.Param("key"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no incident " + c.Param("key")})
+		return
+	}
+	c.JSON(http.StatusOK, IncidentDetail{Incident: inc, Timeline: inc.Timeline()})
+}
+
+// incidentsServed answers for the incident API when this replica has no incidents to
+// show: observe-only, or a follower — incidents live on the leader, so a follower proxies
+// the request there and only answers 503 when it can't. A request the other replica
+// already proxied isn't passed on again, in case leadership moved meanwhile.
+func incidentsServed(c *gin.Context) bool {
+	switch {
+	case incidents == nil:
+		c.JSON(http.StatusNotFound, gin.H{"error": "no incidents tracked (observe-only)"})
+		return false
+	case !acting():
+		if c.GetHeader(forwardedHeader) == "" {
+			err := leadership.Proxy(c.Writer, c.Request)
+			if err == nil {
+				return false
+			}
+			logger.Warnw("incidents: could not proxy to leader", "error", err)
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "incidents are tracked by the leader", "leader": leadership.Leader()})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/tomjga/OmniObserve/remediator/internal/sink"
+)
+
+// testIncidents is an incident tracker on a fake clock.
+func testIncidents() (*Incidents, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewIncidents()
+	s.now = clock.now
+	return s, clock
+}
+
+func states(inc Incident) []IncidentState {
+	var out []IncidentState
+	for _, tr := range inc.History {
+		out = append(out, tr.To)
+	}
+	return out
+}
+
+func TestIncidents_Lifecycle(t *testing.T) {
+	s, clock := testIncidents()
+	alert := stubAlert()
+	key := alert.incidentKey()
+	plan := Plan{Action: "stub", Target: "cart"}
+
+	if _, tr := s.Observe(alert); tr != nil {
+		t.Errorf("first notification transition = %+v; the incident opens as firing", tr)
+	}
+	clock.t = clock.t.Add(time.Minute)
+	if _, tr := s.Observe(alert); tr != nil {
+		t.Errorf("repeat notification transition = %+v, want none", tr)
+	}
+	if _, tr := s.Record(key, Result{Plan: plan, Outcome: OutcomeCooldown}, nil); tr != nil {
+		t.Errorf("cooldown transition = %+v, want none", tr)
+	}
+	if _, tr := s.Record(key, Result{Plan: plan, Outcome: "stubbed", Executed: true}, nil); tr == nil || tr.To != IncidentMitigating {
+		t.Errorf("executed action transition = %+v, want mitigating", tr)
+	}
+	if _, tr := s.Record(key, Result{Plan: plan, Outcome: OutcomeVerified, ErrorRatio: 0.01}, nil); tr == nil || tr.To != IncidentMitigated {
+		t.Errorf("verified transition = %+v, want mitigated", tr)
+	}
+	clock.t = clock.t.Add(time.Minute)
+	resolved := alert
+	resolved.Status = "resolved"
+	inc, tr := s.Observe(resolved)
+	if tr == nil || tr.From != IncidentMitigated || tr.To != IncidentResolved || !inc.ResolvedAt.Equal(clock.t) {
+		t.Errorf("resolve = (%+v, %+v), want resolved from mitigated at %v", inc, tr, clock.t)
+	}
+	if _, tr := s.Record(key, Result{Plan: plan, Outcome: OutcomeRestored}, nil); tr != nil {
+		t.Errorf("undo after resolve transition = %+v; a resolved incident stays resolved", tr)
+	}
+
+	inc, tr = s.Observe(alert)
+	if tr == nil || tr.To != IncidentFiring || inc.Flaps != 1 || !inc.ResolvedAt.IsZero() {
+		t.Errorf("fired again = (%+v, %+v), want it reopened as a flap", inc, tr)
+	}
+	want := []IncidentState{IncidentFiring, IncidentMitigating, IncidentMitigated, IncidentResolved, IncidentFiring}
+	if got := states(inc); !slices.Equal(got, want) {
+		t.Errorf("history = %v, want %v", got, want)
+	}
+	if len(inc.Actions) != 3 || inc.Actions[1].ErrorRatio != 0.01 || inc.Actions[2].Outcome != string(OutcomeRestored) {
+		t.Errorf("actions = %+v, want executed, verified and restored, without the cooldown", inc.Actions)
+	}
+	if !inc.FirstSeen.Equal(clock.t.Add(-2*time.Minute)) || !inc.LastSeen.Equal(clock.t) {
+		t.Errorf("seen %v..%v, want first and last notification", inc.FirstSeen, inc.LastSeen)
+	}
+}
+
+func TestIncidents_RecordMovesOn(t *testing.T) {
+	tests := []struct {
+		name  string
+		res   Result
+		err   error
+		want  IncidentState
+		trans bool
+	}{
+		{"ineffective", Result{Outcome: OutcomeIneffective}, nil, IncidentIneffective, true},
+		{"no data to judge by", Result{Outcome: OutcomeUnverified}, nil, IncidentFiring, true},
+		{"reverted by gitops", Result{Outcome: OutcomeRevertedByGitOps}, nil, IncidentFiring, true},
+		{"pull request opened", Result{Outcome: OutcomePROpened, Executed: true}, nil, IncidentMitigating, false},
+		{"failed action", Result{Executed: true}, errors.New("boom"), IncidentMitigating, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := testIncidents()
+			s.Record("HighLatency|cart", Result{Outcome: "stubbed", Executed: true}, nil)
+			inc, tr := s.Record("HighLatency|cart", tt.res, tt.err)
+			if inc.State != tt.want || (tr != nil) != tt.trans {
+				t.Errorf("state = %s (transition %+v), want %s", inc.State, tr, tt.want)
+			}
+		})
+	}
+}
+
+func TestIncident_Timeline(t *testing.T) {
+	s, clock := testIncidents()
+	alert := stubAlert()
+	alert.Annotations["summary"] = "cart p99 above 1s"
+	key := alert.incidentKey()
+	step := func() { clock.t = clock.t.Add(time.Minute) }
+
+	s.Observe(alert)
+	step()
+	s.Observe(alert) // a repeat: not on the timeline
+	s.Record(key, Result{Plan: Plan{Action: "stub", Target: "cart"}, Outcome: "stubbed", Executed: true}, nil)
+	step()
+	s.SetRCA(key, IncidentRCA{At: clock.t.Add(time.Minute), Model: "m", Body: "## Summary"})
+	s.Record(key, Result{Plan: Plan{Action: "stub"}, Outcome: OutcomeIneffective, ErrorRatio: 0.4}, nil)
+	step()
+	step()
+	s.Published(key, "rca", []sink.Result{{Sink: "grafana", Error: errors.New("timeout")}})
+	step()
+	resolved := alert
+	resolved.Status = "resolved"
+	s.Observe(resolved)
+
+	inc, _ := s.Get(key)
+	var got []string
+	for _, e := range inc.Timeline() {
+		got = append(got, e.Kind+" "+e.Title)
+	}
+	want := []string{
+		"alert Alert fired",
+		"action stub: stubbed",
+		"verification Verification: ineffective",
+		"rca RCA drafted",
+		"publish Publishing rca to grafana failed",
+		"alert Alert resolved",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("timeline = %q, want %q", got, want)
+	}
+	if tl := inc.Timeline(); !tl[2].Failed || tl[2].Detail != "error ratio 0.400" || tl[0].Detail != "cart p99 above 1s" {
+		t.Errorf("timeline = %+v, want the ineffective verification flagged with its ratio", tl)
+	}
+}
+
+func TestIncidents_AlertsKeepStatusChangesAndTheFirst(t *testing.T) {
+	s, clock := testIncidents()
+	alert := stubAlert()
+	resolved := alert
+	resolved.Status = "resolved"
+	first := clock.t
+
+	for range maxIncidentAlerts { // repeats of a firing alert, as Alertmanager sends them
+		s.Observe(alert)
+		clock.t = clock.t.Add(time.Minute)
+	}
+	inc, _ := s.Get(alert.incidentKey())
+	if len(inc.Alerts) != 1 {
+		t.Fatalf("alerts = %d after repeats, want only the first", len(inc.Alerts))
+	}
+	for range maxIncidentAlerts { // flaps: every notification changes the status
+		s.Observe(resolved)
+		s.Observe(alert)
+		clock.t = clock.t.Add(time.Minute)
+	}
+	inc, _ = s.Get(alert.incidentKey())
+	if len(inc.Alerts) != maxIncidentAlerts || !inc.Alerts[0].At.Equal(first) {
+		t.Errorf("alerts = %d from %v, want %d from the first, %v", len(inc.Alerts), inc.Alerts[0].At, maxIncidentAlerts, first)
+	}
+	if tl := inc.Timeline(); tl[0].Title != "Alert fired" || !tl[0].At.Equal(first) {
+		t.Errorf("timeline starts %+v, want the alert firing at %v", tl[0], first)
+	}
+}
+
+func TestIncidents_RCAOncePerIncident(t *testing.T) {
+	s, _ := testIncidents()
+	s.Observe(stubAlert())
+	if !s.requestRCA("HighLatency|cart") || s.requestRCA("HighLatency|cart") {
+		t.Error("want the first mitigation to draft the RCA and the next not to")
+	}
+	s.SetRCA("HighLatency|cart", IncidentRCA{Body: "## Root cause", Precedent: []Precedent{{ID: "INC-2026-0007"}}})
+	s.Published("HighLatency|cart", "rca", []sink.Result{{Sink: "grafana"}, {Sink: "github-issue", Error: errors.New("403")}})
+	inc, _ := s.Get("HighLatency|cart")
+	if inc.RCA == nil || inc.RCA.Body != "## Root cause" || inc.RCA.Precedent[0].ID != "INC-2026-0007" {
+		t.Errorf("RCA = %+v, want the draft and its precedent attached", inc.RCA)
+	}
+	if len(inc.Publishes) != 2 || inc.Publishes[1].Sink != "github-issue" || inc.Publishes[1].Error != "403" {
+		t.Errorf("publishes = %+v, want each sink's result", inc.Publishes)
+	}
+}
+
+func TestIncidents_Prune(t *testing.T) {
+	s, clock := testIncidents()
+	s.MaxIncidents = 2
+	for _, svc := range []string{"cart", "checkout", "ad"} {
+		a := stubAlert()
+		a.Labels = map[string]string{"alertname": "HighLatency", "service": svc}
+		s.Observe(a)
+		a.Status = "resolved"
+		s.Observe(a)
+		clock.t = clock.t.Add(time.Hour)
+	}
+	if _, ok := s.Get("HighLatency|cart"); ok {
+		t.Error("kept the longest-resolved incident past MaxIncidents")
+	}
+
+	clock.t = clock.t.Add(s.Retention)
+	s.Observe(stubAlert())
+	if got, _ := s.List(IncidentFilter{}); len(got) != 1 || got[0].Key != "HighLatency|cart" {
+		t.Errorf("incidents = %+v, want only the one firing now", got)
+	}
+}
+
+func TestRemediate_ActsOnlyWhileFiringOrIneffective(t *testing.T) {
+	stub := &stubAction{verified: true}
+	registry = NewRegistry(false, 0)
+	registry.Register("stub", stub)
+	incidents, _ = testIncidents()
+	t.Cleanup(func() { registry, incidents = nil, nil })
+	ctx := context.Background()
+	span := trace.SpanFromContext(ctx)
+	key := stubAlert().incidentKey()
+
+	incidents.Observe(stubAlert())
+	incidents.Record(key, Result{Outcome: OutcomeVerified}, nil)
+	if err := remediate(ctx, span, stubAlert()); err != nil || stub.executed != 0 {
+		t.Fatalf("remediate on a mitigated incident = %v, executed %d; want it left alone", err, stub.executed)
+	}
+
+	incidents.Record(key, Result{Outcome: OutcomeIneffective}, nil)
+	if err := remediate(ctx, span, stubAlert()); err != nil || stub.executed != 1 {
+		t.Fatalf("remediate on an ineffective incident = %v, executed %d; want another try", err, stub.executed)
+	}
+	// Acting moved it to mitigating, which started the check: without Prometheus that is
+	// unverified at once, and the incident is firing again.
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		inc, _ := incidents.Get(key)
+		if n := len(inc.History); n >= 2 && inc.History[n-2].To == IncidentMitigating && inc.State == IncidentFiring {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("history = %v, want mitigating then back to firing", states(inc))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestIncidentHandlers(t *testing.T) {
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	s, clock := testIncidents()
+	incidents = s
+	t.Cleanup(func() { incidents = nil })
+	for _, svc := range []string{"cart", "checkout", "ad"} {
+		a := stubAlert()
+		a.Labels = map[string]string{"alertname": "HighLatency", "service": svc}
+		s.Observe(a)
+		clock.t = clock.t.Add(time.Minute)
+	}
+	s.Record("HighLatency|ad", Result{Plan: Plan{Action: "stub", Target: "ad"}, Outcome: "stubbed", Executed: true}, nil)
+
+	router := gin.New()
+	router.GET("/incidents", adminAuth(), listIncidentsHandler)
+	router.GET("/incidents/:key", adminAuth(), getIncidentHandler)
+	get := func(path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer s3cret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var page IncidentPage
+	w := get("/incidents?status=firing&limit=1&offset=1")
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /incidents = %d %s", w.Code, w.Body.String())
+	}
+	if page.Total != 2 || len(page.Incidents) != 1 || page.Incidents[0].Key != "HighLatency|cart" {
+		t.Errorf("page = %+v, want the second of the two firing incidents", page)
+	}
+	since := clock.t.Add(-90 * time.Second).Format(time.RFC3339)
+	w = get("/incidents?service=ad&since=" + since)
+	_ = json.Unmarshal(w.Body.Bytes(), &page)
+	if page.Total != 1 || page.Incidents[0].State != IncidentMitigating || page.Incidents[0].LastAction != "stubbed" {
+		t.Errorf("page = %+v, want the mitigating ad incident", page)
+	}
+	for _, q := range []string{"status=burning", "since=yesterday", "limit=-1"} {
+		if w := get("/incidents?" + q); w.Code != http.StatusBadRequest {
+			t.Errorf("GET /incidents?%s status = %d, want 400", q, w.Code)
+		}
+	}
+
+	var inc Incident
+	w = get("/incidents/HighLatency%7Cad")
+	if err := json.Unmarshal(w.Body.Bytes(), &inc); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("GET /incidents/{key} = %d %s", w.Code, w.Body.String())
+	}
+	var detail IncidentDetail
+	_ = json.Unmarshal(w.Body.Bytes(), &detail)
+	if len(inc.Alerts) != 1 || inc.Alerts[0].Alert.Labels["service"] != "ad" || len(inc.Actions) != 1 || len(detail.Timeline) != 2 {
+		t.Errorf("incident = %+v, want its alert and action", inc)
+	}
+	if w := get("/incidents/Nope%7Cnowhere"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown incident status = %d, want 404", w.Code)
+	}
+
+	followerOf(t, "127.0.0.1:1") // nothing listens there
+	if w := get("/incidents"); w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), "127.0.0.1:1") {
+		t.Errorf("follower GET /incidents with the leader down = %d %s, want 503 naming the leader", w.Code, w.Body.String())
+	}
+}
+
+func TestIncidentHandlers_FollowerProxiesToLeader(t *testing.T) {
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	incidents, _ = testIncidents() // the follower's own, empty: the answer must come from the leader
+	t.Cleanup(func() { incidents = nil })
+	var gotURI, gotAuth, gotFrom string
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotURI, gotAuth, gotFrom = r.URL.RequestURI(), r.Header.Get("Authorization"), r.Header.Get(forwardedHeader)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":"no incident HighLatency|ad"}`))
+	}))
+	defer leader.Close()
+	followerOf(t, strings.TrimPrefix(leader.URL, "http://"))
+
+	router := gin.New()
+	router.GET("/incidents/:key", adminAuth(), getIncidentHandler)
+	req := httptest.NewRequest(http.MethodGet, "/incidents/HighLatency%7Cad?x=1", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), "HighLatency|ad") {
+		t.Errorf("proxied answer = %d %s, want the leader's 404 passed through", w.Code, w.Body.String())
+	}
+	if gotURI != "/incidents/HighLatency%7Cad?x=1" || gotAuth != "Bearer s3cret" || gotFrom == "" {
+		t.Errorf("leader got %q auth %q from %q, want the same path, token and the forwarded header", gotURI, gotAuth, gotFrom)
+	}
+
+	req.Header.Set(forwardedHeader, "pod-c_10.0.0.3:8080") // proxied once already
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("a proxied request reaching a follower = %d, want 503 rather than another hop", w.Code)
+	}
+}
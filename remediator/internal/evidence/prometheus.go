@@ -12,6 +12,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -32,12 +33,16 @@ type Metric struct {
 	Value string
 }
 
-// query templates: {svc} is replaced with the alerting service's job label.
-var queries = []struct{ name, expr string }{
-	{"gRPC error ratio (5m)", `(sum(rate(rpc_server_duration_milliseconds_count{job="{svc}",rpc_grpc_status_code!="0"}[5m]))/clamp_min(sum(rate(rpc_server_duration_milliseconds_count{job="{svc}"}[5m])),1))`},
-	{"gRPC request rate /s (5m)", `sum(rate(rpc_server_duration_milliseconds_count{job="{svc}"}[5m]))`},
-	{"HTTP 5xx ratio (5m)", `(sum(rate(http_requests_total{job="{svc}",code=~"5.."}[5m]))/clamp_min(sum(rate(http_requests_total{job="{svc}"}[5m])),1))`},
-	{"HTTP request rate /s (5m)", `sum(rate(http_requests_total{job="{svc}"}[5m]))`},
+// query templates: {svc} is replaced with the alerting service's job label. errorRatio
+// marks the queries ErrorRatio judges a remediation by.
+var queries = []struct {
+	name, expr string
+	errorRatio bool
+}{
+	{"gRPC error ratio (5m)", `(sum(rate(rpc_server_duration_milliseconds_count{job="{svc}",rpc_grpc_status_code!="0"}[5m]))/clamp_min(sum(rate(rpc_server_duration_milliseconds_count{job="{svc}"}[5m])),1))`, true},
+	{"gRPC request rate /s (5m)", `sum(rate(rpc_server_duration_milliseconds_count{job="{svc}"}[5m]))`, false},
+	{"HTTP 5xx ratio (5m)", `(sum(rate(http_requests_total{job="{svc}",code=~"5.."}[5m]))/clamp_min(sum(rate(http_requests_total{job="{svc}"}[5m])),1))`, true},
+	{"HTTP request rate /s (5m)", `sum(rate(http_requests_total{job="{svc}"}[5m]))`, false},
 }
 
 // Gather runs the templated queries for service and returns those that produced a value.
@@ -53,6 +58,28 @@ func (p *Prometheus) Gather(ctx context.Context, service string) []Metric {
 	return out
 }
 
+// ErrorRatio returns the service's current error ratio — gRPC or HTTP, whichever the
+// service reports (the worse of the two if both) — with ok false when neither has data.
+// It's the number the remediator re-checks after acting, to see if the fix worked.
+func (p *Prometheus) ErrorRatio(ctx context.Context, service string) (float64, bool) {
+	worst, ok := 0.0, false
+	for _, q := range queries {
+		if !q.errorRatio {
+			continue
+		}
+		v, found := p.instant(ctx, strings.ReplaceAll(q.expr, "{svc}", service))
+		if !found {
+			continue
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			continue
+		}
+		worst, ok = max(worst, f), true
+	}
+	return worst, ok
+}
+
 type queryResponse struct {
 	Data struct {
 		Result []struct {
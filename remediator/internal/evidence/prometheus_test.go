@@ -50,3 +50,43 @@ func TestGather_QuerySubstitutesService(t *testing.T) {
 		t.Error("service name was not substituted into the query")
 	}
 }
+
+func TestErrorRatio(t *testing.T) {
+	tests := []struct {
+		name   string
+		grpc   string // "" = no data
+		http   string
+		want   float64
+		wantOK bool
+	}{
+		{"gRPC service", "0.42", "", 0.42, true},
+		{"HTTP service", "", "0.1", 0.1, true},
+		{"both report, worse wins", "0.02", "0.3", 0.3, true},
+		{"no data", "", "", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				expr := r.URL.Query().Get("query")
+				v := ""
+				switch {
+				case strings.Contains(expr, "rpc_grpc_status_code"):
+					v = tt.grpc
+				case strings.Contains(expr, `code=~"5.."`):
+					v = tt.http
+				}
+				if v == "" {
+					_, _ = w.Write([]byte(`{"data":{"result":[]}}`))
+					return
+				}
+				_, _ = w.Write([]byte(`{"data":{"result":[{"value":[1,"` + v + `"]}]}}`))
+			}))
+			defer srv.Close()
+
+			got, ok := NewPrometheus(srv.URL).ErrorRatio(context.Background(), "svc")
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("ErrorRatio = (%v, %v), want (%v, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
@@ -63,16 +63,24 @@ Use this topology to reason about likely cause and blast radius.`
 // Enabled reports whether the copilot can draft (i.e. the LLM is configured).
 func (c *Copilot) Enabled() bool { return c.llm != nil && c.llm.Configured() }
 
+// Analysis is a drafted RCA together with the material it was grounded in.
+type Analysis struct {
+	Body      string
+	Evidence  []evidence.Metric
+	Precedent []corpus.Incident
+}
+
 // Draft produces a markdown RCA for the incident. It is best-effort about evidence and
 // precedent (missing either just means a thinner prompt), but requires the LLM to answer.
-func (c *Copilot) Draft(ctx context.Context, inc Incident) (string, error) {
-	var metrics []evidence.Metric
+// The evidence and precedent are returned even when the LLM fails.
+func (c *Copilot) Draft(ctx context.Context, inc Incident) (Analysis, error) {
+	var a Analysis
 	if c.prom != nil {
-		metrics = c.prom.Gather(ctx, inc.Service)
+		a.Evidence = c.prom.Gather(ctx, inc.Service)
 	}
-	precedent := corpus.Retrieve(c.incidents, terms(inc), 3)
+	a.Precedent = corpus.Retrieve(c.incidents, terms(inc), 3)
 
-	user := userPrompt(inc, metrics, precedent)
+	user := userPrompt(inc, a.Evidence, a.Precedent)
 	if c.SystemContext != "" {
 		user = "# System architecture\n" + c.SystemContext + "\n\n" + user
 	}
@@ -80,7 +88,9 @@ func (c *Copilot) Draft(ctx context.Context, inc Incident) (string, error) {
 		{Role: "system", Content: systemPrompt},
 		{Role: "user", Content: user},
 	}
-	return c.llm.Complete(ctx, messages)
+	body, err := c.llm.Complete(ctx, messages)
+	a.Body = body
+	return a, err
 }
 
 const systemPrompt = `You are an SRE incident-analysis assistant for the OmniObserve platform.
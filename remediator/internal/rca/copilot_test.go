@@ -74,8 +74,11 @@ func TestDraft_BuildsGroundedPromptAndReturnsRCA(t *testing.T) {
 	if err != nil {
 		t.Fatalf("draft error: %v", err)
 	}
-	if !strings.Contains(out, "Summary") {
-		t.Errorf("RCA output missing expected content: %q", out)
+	if !strings.Contains(out.Body, "Summary") {
+		t.Errorf("RCA output missing expected content: %q", out.Body)
+	}
+	if len(out.Evidence) == 0 || len(out.Precedent) != 1 || out.Precedent[0].ID != "INC-2026-0007" {
+		t.Errorf("analysis = %+v, want the evidence and precedent it was grounded in", out)
 	}
 
 	// The prompt must include the evidence value and the retrieved precedent ID — proof
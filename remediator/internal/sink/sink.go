@@ -24,6 +24,7 @@ type RCA struct {
 	Service  string
 	Slug     string // filename-safe identifier, e.g. productcataloghigherrorrate
 	Model    string // the LLM that drafted this, e.g. gemini-2.5-flash — surfaced as a tag/label
+	Priority string // "high" for escalations — surfaced as a tag/label; empty for routine RCAs
 	StartsAt time.Time
 }
 
@@ -58,6 +59,9 @@ func (g Grafana) Publish(ctx context.Context, r RCA) error {
 	if r.Model != "" {
 		tags = append(tags, "llm:"+r.Model)
 	}
+	if r.Priority != "" {
+		tags = append(tags, "priority:"+r.Priority)
+	}
 	body, _ := json.Marshal(map[string]any{
 		"time": at.UnixMilli(),
 		"tags": tags,
@@ -86,6 +90,9 @@ func (g GitHubIssue) Publish(ctx context.Context, r RCA) error {
 	if r.Model != "" {
 		labels = append(labels, "llm:"+r.Model) // GitHub creates the label on first use
 	}
+	if r.Priority != "" {
+		labels = append(labels, "priority:"+r.Priority)
+	}
 	body, _ := json.Marshal(map[string]any{
 		"title":  r.Title,
 		"body":   r.Body,
@@ -170,3 +177,18 @@ func (p *Publisher) Publish(ctx context.Context, r RCA) []Result {
 	}
 	return results
 }
+
+// Escalate sends a high-priority notice (e.g. "the remediation didn't work") to every
+// configured sink where a human will see it — everywhere except the corpus, which is for
+// analysed incidents, not alarms.
+func (p *Publisher) Escalate(ctx context.Context, r RCA) []Result {
+	r.Priority = "high"
+	var results []Result
+	for name, s := range p.sinks {
+		if !s.Configured() || name == "github-corpus" {
+			continue
+		}
+		results = append(results, Result{Sink: name, Error: s.Publish(ctx, r)})
+	}
+	return results
+}
@@ -92,6 +92,45 @@ func TestPublisher_SkipsUnconfiguredCollectsResults(t *testing.T) {
 	}
 }
 
+func TestPublisher_EscalateSkipsCorpusAndTagsPriority(t *testing.T) {
+	var paths []string
+	var grafanaTags []any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path == "/api/annotations" {
+			var payload map[string]any
+			raw, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(raw, &payload)
+			grafanaTags, _ = payload["tags"].([]any)
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	github := &http.Client{Transport: rewriteHost(srv.URL)}
+	p := NewPublisher(
+		Grafana{URL: srv.URL, Token: "t", HTTP: srv.Client()},
+		GitHubIssue{Repo: "o/r", Token: "t", HTTP: github},
+		GitHubCorpus{Repo: "o/r", Token: "t", Branch: "rca-drafts", HTTP: github},
+	)
+	results := p.Escalate(context.Background(), RCA{Title: "T", Body: "B", Service: "svc"})
+	if len(results) != 2 {
+		t.Fatalf("escalated to %d sinks, want grafana + github-issue: %+v", len(results), results)
+	}
+	for _, path := range paths {
+		if strings.Contains(path, "/contents/") {
+			t.Errorf("escalation was committed to the corpus: %s", path)
+		}
+	}
+	found := false
+	for _, tag := range grafanaTags {
+		found = found || tag == "priority:high"
+	}
+	if !found {
+		t.Errorf("grafana tags %v missing priority:high", grafanaTags)
+	}
+}
+
 // rewriteHost sends api.github.com requests to the test server instead.
 type hostRewriter struct{ target string }
 
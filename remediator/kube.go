@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// kubeConfig finds the Kubernetes API to act against. In a pod that is the service
+// account; from a laptop or CI (e.g. against kind) it is a kubeconfig found by the standard
+// loading rules — KUBECONFIG, else ~/.kube/config — in context REMEDIATOR_KUBE_CONTEXT, or
+// the file's current context. Setting either variable prefers the kubeconfig even in a pod.
+// The returned string says which was used, for the startup log.
+func kubeConfig() (*rest.Config, string, error) {
+	kubeContext := os.Getenv("REMEDIATOR_KUBE_CONTEXT")
+	if os.Getenv("KUBECONFIG") == "" && kubeContext == "" {
+		cfg, err := rest.InClusterConfig()
+		if err == nil {
+			return cfg, "in-cluster", nil
+		}
+		if err != rest.ErrNotInCluster {
+			return nil, "", fmt.Errorf("in-cluster config: %w", err)
+		}
+	}
+	loader := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+		clientcmd.NewDefaultClientConfigLoadingRules(),
+		&clientcmd.ConfigOverrides{CurrentContext: kubeContext},
+	)
+	raw, err := loader.RawConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("load kubeconfig: %w", err)
+	}
+	cfg, err := loader.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("kubeconfig: %w", err)
+	}
+	if kubeContext == "" {
+		kubeContext = raw.CurrentContext
+	}
+	return cfg, "kubeconfig context " + kubeContext, nil
+}
+
+// permissionLister is implemented by actions that can say which RBAC verbs they need, so
+// one that's missing is reported at startup instead of failing the action mid-incident.
+type permissionLister interface {
+	Permissions() []authorizationv1.ResourceAttributes
+}
+
+// checkPermissions asks the API server, with one SelfSubjectAccessReview per verb, whether
+// the remediator's identity may do what each registered action (and each ConfigMap store,
+// by name) needs, and logs every verb it's missing. It never fails startup: a missing verb only
+// breaks the actions that need it, and observe-only still works. It returns the missing
+// verbs by action, for tests.
+func checkPermissions(ctx context.Context, k8s kubernetes.Interface, r *Registry, stores map[string]*ConfigMapStore) map[string][]authorizationv1.ResourceAttributes {
+	needs := map[string][]authorizationv1.ResourceAttributes{}
+	for name, a := range r.actions {
+		if pl, ok := a.(permissionLister); ok {
+			needs[name] = pl.Permissions()
+		}
+	}
+	for name, s := range stores {
+		needs[name] = s.Permissions()
+	}
+
+	missing := map[string][]authorizationv1.ResourceAttributes{}
+	for _, name := range slices.Sorted(maps.Keys(needs)) {
+		for _, attr := range needs[name] {
+			review, err := k8s.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx,
+				&authorizationv1.SelfSubjectAccessReview{Spec: authorizationv1.SelfSubjectAccessReviewSpec{ResourceAttributes: &attr}},
+				metav1.CreateOptions{})
+			if err != nil {
+				logger.Warnw("could not check RBAC permissions; actions may fail at run time", "error", err)
+				return missing
+			}
+			if !review.Status.Allowed {
+				missing[name] = append(missing[name], attr)
+				logger.Warnw("missing RBAC permission; this action will fail until it's granted",
+					"action", name, "permission", describePermission(attr))
+			}
+		}
+	}
+	if len(missing) == 0 {
+		logger.Infow("RBAC self-check passed", "actions", len(needs))
+	}
+	return missing
+}
+
+// describePermission renders attr as the arguments to kubectl auth can-i, e.g.
+// "update configmaps/flagd-config -n otel-demo", so the log line can be pasted to check it.
+func describePermission(a authorizationv1.ResourceAttributes) string {
+	s := a.Verb + " " + a.Resource
+	if a.Group != "" {
+		s += "." + a.Group
+	}
+	if a.Name != "" {
+		s += "/" + a.Name
+	}
+	if a.Subresource != "" {
+		s += " --subresource=" + a.Subresource
+	}
+	return s + " -n " + a.Namespace
+}
+
+// namespaceFor is the namespace an alert points a namespaced action at: its namespace
+// label, or def. ok is false unless that is def or one of allowed — the namespaces RBAC is
+// granted in and checked at startup — so a label can't send an action anywhere else.
+func namespaceFor(alert Alert, def string, allowed []string) (ns string, ok bool) {
+	ns = alert.namespace(def)
+	return ns, ns == def || slices.Contains(allowed, ns)
+}
+
+// namespacesOf is def followed by the allowed namespaces, without repeats: everywhere an
+// action may act, for its Permissions.
+func namespacesOf(def string, allowed []string) []string {
+	out := []string{def}
+	for _, ns := range allowed {
+		if !slices.Contains(out, ns) {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+// parseNamespaces reads a comma-separated list of namespaces, e.g. ROLLOUTS_ALLOWED_NAMESPACES.
+func parseNamespaces(s string) []string {
+	var out []string
+	for _, ns := range strings.Split(s, ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			out = append(out, ns)
+		}
+	}
+	return out
+}
+
+// permissions expands verbs on one resource (one object, if name is set) into the
+// attributes checkPermissions reviews.
+func permissions(namespace, group, resource, name string, verbs ...string) []authorizationv1.ResourceAttributes {
+	out := make([]authorizationv1.ResourceAttributes, 0, len(verbs))
+	for _, v := range verbs {
+		out = append(out, authorizationv1.ResourceAttributes{
+			Namespace: namespace, Group: group, Resource: resource, Name: name, Verb: v})
+	}
+	return out
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+current-context: kind-a
+clusters:
+- name: a
+  cluster: {server: "https://127.0.0.1:6443"}
+- name: b
+  cluster: {server: "https://127.0.0.1:7443"}
+users:
+- name: dev
+  user: {token: dev-token}
+contexts:
+- name: kind-a
+  context: {cluster: a, user: dev}
+- name: kind-b
+  context: {cluster: b, user: dev}
+`
+
+func TestKubeConfig_KubeconfigAndContext(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := os.WriteFile(path, []byte(testKubeconfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("KUBECONFIG", path)
+
+	cfg, source, err := kubeConfig()
+	if err != nil || cfg.Host != "https://127.0.0.1:6443" || source != "kubeconfig context kind-a" {
+		t.Fatalf("kubeConfig() = (%v, %q, %v), want the current context, kind-a", cfg, source, err)
+	}
+
+	t.Setenv("REMEDIATOR_KUBE_CONTEXT", "kind-b")
+	cfg, source, err = kubeConfig()
+	if err != nil || cfg.Host != "https://127.0.0.1:7443" || cfg.BearerToken != "dev-token" || source != "kubeconfig context kind-b" {
+		t.Fatalf("kubeConfig() = (%v, %q, %v), want the kind-b context", cfg, source, err)
+	}
+
+	t.Setenv("REMEDIATOR_KUBE_CONTEXT", "kind-c")
+	if _, _, err := kubeConfig(); err == nil {
+		t.Error("kubeConfig() with an unknown context succeeded, want an error")
+	}
+}
+
+func TestCheckPermissions_ReportsMissingVerbs(t *testing.T) {
+	cs := fake.NewClientset()
+	// The identity may do everything except update ConfigMaps.
+	cs.PrependReactor("create", "selfsubjectaccessreviews", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		review := a.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		attr := review.Spec.ResourceAttributes
+		review.Status.Allowed = attr.Resource != "configmaps" || attr.Verb != "update"
+		return true, review, nil
+	})
+	r := NewRegistry(false, time.Minute)
+	r.Register(actionFlagd, NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json"))
+	r.Register(actionRollout, NewRolloutRemediator(nil, cs, "default"))
+	r.Register("stub", &stubAction{}) // lists no permissions; not checked
+
+	missing := checkPermissions(context.Background(), cs, r, map[string]*ConfigMapStore{
+		"state": NewConfigMapStore(cs, "remediator", "remediator-state"),
+		"queue": NewConfigMapStore(cs, "remediator", "remediator-queue"),
+	})
+
+	if len(missing) != 3 || len(missing[actionFlagd]) != 1 || len(missing["state"]) != 1 || len(missing["queue"]) != 1 {
+		t.Fatalf("missing = %v, want only the three ConfigMap updates", missing)
+	}
+	if got, want := describePermission(missing[actionFlagd][0]), "update configmaps/flagd-config -n otel-demo"; got != want {
+		t.Errorf("flagd missing %q, want %q", got, want)
+	}
+	if got, want := describePermission(missing["state"][0]), "update configmaps/remediator-state -n remediator"; got != want {
+		t.Errorf("state missing %q, want %q", got, want)
+	}
+}
+
+func TestPermissions_CoverAllowedNamespaces(t *testing.T) {
+	ro := NewRolloutRemediator(nil, nil, "default")
+	ro.AllowedNamespaces = []string{"payments", "default"}
+	var got []string
+	for _, p := range ro.Permissions() {
+		if !slices.Contains(got, p.Namespace) {
+			got = append(got, p.Namespace)
+		}
+	}
+	if !slices.Equal(got, []string{"default", "payments"}) {
+		t.Errorf("rollout permissions cover %v, want default and payments", got)
+	}
+
+	sc := NewScaleRemediator(nil, "default", map[string]ScaleLimit{"default/api": {Step: 1, Max: 2}, "kube-system/dns": {Step: 1, Max: 2}})
+	for _, p := range sc.Permissions() {
+		if p.Namespace != "default" {
+			t.Errorf("scale permission in %s, want none outside the allowed namespaces", p.Namespace)
+		}
+	}
+}
+
+func TestDescribePermission(t *testing.T) {
+	attr := authorizationv1.ResourceAttributes{Namespace: "default", Group: "argoproj.io",
+		Resource: "rollouts", Subresource: "status", Verb: "patch"}
+	if got, want := describePermission(attr), "patch rollouts.argoproj.io --subresource=status -n default"; got != want {
+		t.Errorf("describePermission = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// leadership decides which replica may mutate the cluster. Nil (LEADER_ELECTION_ENABLED
+// unset, or observe-only) means a singleton that is always the leader.
+var leadership *Leadership
+
+// forwardedHeader marks a webhook a follower has already passed on, so it is never
+// forwarded twice if leadership moves while it is in flight. forwardSignatureHeader proves
+// the follower sent it: an HMAC of the sender, forwardTimeHeader and the body under the
+// replicas' shared LEADER_FORWARD_SECRET, which no client outside the pods holds. A
+// forward signed more than forwardMaxAge from now is stale, so a captured one can't be
+// replayed later.
+const (
+	forwardedHeader        = "X-Remediator-Forwarded"
+	forwardSignatureHeader = "X-Remediator-Forward-Signature"
+	forwardTimeHeader      = "X-Remediator-Forward-Time" // unix seconds
+	forwardMaxAge          = time.Minute
+)
+
+var (
+	// isLeader: "which replica is acting?" — exactly one remediator should report 1.
+	isLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "remediator_is_leader",
+		Help: "1 when this replica holds the leader Lease and may execute actions, else 0.",
+	})
+	// webhooksForwarded audits followers handing webhooks to the leader, by result.
+	webhooksForwarded = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "remediator_webhooks_forwarded_total",
+			Help: "Webhooks a follower forwarded to the leader, by result (forwarded/error).",
+		},
+		[]string{"result"},
+	)
+)
+
+func init() { prometheus.MustRegister(isLeader, webhooksForwarded) }
+
+// Leadership runs Lease-based leader election between remediator replicas. Every replica
+// accepts webhooks and records metrics, but only the leader executes actions: a follower
+// forwards each webhook to the leader. Each replica has its own registry state, so letting
+// two of them act would double every mutation.
+//
+// The identity is "<pod>_<host:port>": the Lease then tells followers where to forward.
+type Leadership struct {
+	identity string
+	lock     resourcelock.Interface
+	client   *http.Client
+	secret   []byte // signs forwarded webhooks; empty means forwards carry no proof
+
+	LeaseDuration time.Duration // how long a leader's claim lasts without renewal
+	RenewDeadline time.Duration // how long the leader keeps retrying renewal before stepping down
+	RetryPeriod   time.Duration // how often candidates try to acquire or renew
+
+	leading atomic.Bool
+	mu      sync.Mutex
+	leader  string // identity of the current leader, "" while unknown
+}
+
+// NewLeadership builds an election on the named Lease with client-go's usual timings.
+func NewLeadership(k8s kubernetes.Interface, namespace, lease, identity string) *Leadership {
+	return &Leadership{
+		identity: identity,
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Namespace: namespace, Name: lease},
+			Client:     k8s.CoordinationV1(),
+			LockConfig: resourcelock.ResourceLockConfig{Identity: identity},
+		},
+		client:        &http.Client{Timeout: 20 * time.Second},
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+	}
+}
+
+// Run campaigns for the Lease until ctx is done, standing again after every lost term.
+// onStart runs when this replica wins, before it starts acting — the place to reload
+// state the previous leader wrote.
+func (l *Leadership) Run(ctx context.Context, onStart func(ctx context.Context)) error {
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            l.lock,
+		LeaseDuration:   l.LeaseDuration,
+		RenewDeadline:   l.RenewDeadline,
+		RetryPeriod:     l.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            "remediator",
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				onStart(ctx)
+				l.setLeading(true)
+				logger.Infow("became leader; executing actions", "identity", l.identity)
+			},
+			OnStoppedLeading: func() {
+				l.setLeading(false)
+				logger.Warnw("lost leadership; forwarding webhooks", "identity", l.identity)
+			},
+			OnNewLeader: func(identity string) {
+				l.mu.Lock()
+				l.leader = identity
+				l.mu.Unlock()
+			},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("leader election: %w", err)
+	}
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+	return nil
+}
+
+// IsLeader reports whether this replica may execute actions.
+func (l *Leadership) IsLeader() bool { return l.leading.Load() }
+
+// Leader returns the current leader's identity, or "" while none is known.
+func (l *Leadership) Leader() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.leader
+}
+
+func (l *Leadership) setLeading(leading bool) {
+	l.leading.Store(leading)
+	if leading {
+		isLeader.Set(1)
+	} else {
+		isLeader.Set(0)
+	}
+}
+
+// Forward posts a webhook body to the leader's /webhook, with header (the caller's
+// credentials) added. It fails when no other replica is known to lead, so the caller can
+// answer 503 and let Alertmanager retry.
+func (l *Leadership) Forward(ctx context.Context, body []byte, header http.Header) error {
+	leader, addr, err := l.leaderAddr()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+"/webhook", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("build forward request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(forwardedHeader, l.identity)
+	if len(l.secret) > 0 {
+		at := time.Now()
+		req.Header.Set(forwardTimeHeader, strconv.FormatInt(at.Unix(), 10))
+		req.Header.Set(forwardSignatureHeader, l.sign(l.identity, at, body))
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("forward to leader %s: %w", leader, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("forward to leader %s: status %d", leader, resp.StatusCode)
+	}
+	return nil
+}
+
+// Proxy relays a read-only request to the same path on the leader and copies the answer
+// into w — for the incident API, whose state only the leader holds. The caller's
+// Authorization goes along, so the leader checks it again. It fails, having written
+// nothing, when no other replica is known to lead or the leader can't be reached.
+func (l *Leadership) Proxy(w http.ResponseWriter, r *http.Request) error {
+	leader, addr, err := l.leaderAddr()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, "http://"+addr+r.URL.RequestURI(), nil)
+	if err != nil {
+		return fmt.Errorf("build proxy request: %w", err)
+	}
+	req.Header.Set("Authorization", r.Header.Get("Authorization"))
+	req.Header.Set("Accept", r.Header.Get("Accept"))
+	req.Header.Set(forwardedHeader, l.identity)
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("proxy to leader %s: %w", leader, err)
+	}
+	defer resp.Body.Close()
+	w.Header().Set("Content-Type", resp.Header.Get("Content-Type"))
+	w.WriteHeader(resp.StatusCode)
+	_, _ = io.Copy(w, resp.Body) // the status is out; a broken copy is the client's to retry
+	return nil
+}
+
+// leaderAddr is the current leader's identity and host:port, or an error when no other
+// replica is known to lead.
+func (l *Leadership) leaderAddr() (leader, addr string, err error) {
+	leader = l.Leader()
+	_, addr, ok := strings.Cut(leader, "_")
+	if !ok || leader == l.identity {
+		return leader, "", fmt.Errorf("no leader to forward to (lease holder %q)", leader)
+	}
+	return leader, addr, nil
+}
+
+// Forwarded reports whether r is a webhook another replica forwarded: it carries the
+// forwarded header, a signing time within forwardMaxAge of now, and a valid signature of
+// both and body. Without a secret (a singleton) nothing is.
+func (l *Leadership) Forwarded(r *http.Request, body []byte) bool {
+	if l == nil || len(l.secret) == 0 {
+		return false
+	}
+	from := r.Header.Get(forwardedHeader)
+	unix, err := strconv.ParseInt(r.Header.Get(forwardTimeHeader), 10, 64)
+	if from == "" || err != nil {
+		return false
+	}
+	at := time.Unix(unix, 0)
+	if age := time.Since(at); age > forwardMaxAge || age < -forwardMaxAge {
+		return false
+	}
+	got, err := hex.DecodeString(r.Header.Get(forwardSignatureHeader))
+	return err == nil && hmac.Equal(got, l.mac(from, at, body))
+}
+
+// sign is the forward signature header value for a webhook body sent by identity at at.
+func (l *Leadership) sign(identity string, at time.Time, body []byte) string {
+	return hex.EncodeToString(l.mac(identity, at, body))
+}
+
+func (l *Leadership) mac(identity string, at time.Time, body []byte) []byte {
+	mac := hmac.New(sha256.New, l.secret)
+	mac.Write([]byte(identity + "\n" + strconv.FormatInt(at.Unix(), 10) + "\n"))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// acting reports whether this replica executes actions: always for a singleton.
+func acting() bool { return leadership == nil || leadership.IsLeader() }
+
+// role is this replica's part in the election, as reported by /healthz.
+func role() string {
+	switch {
+	case leadership == nil:
+		return "singleton"
+	case leadership.IsLeader():
+		return "leader"
+	default:
+		return "follower"
+	}
+}
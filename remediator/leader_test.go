@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestLeadership_OneLeaderAtATime(t *testing.T) {
+	k8s := fake.NewClientset()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var candidates []*Leadership
+	for _, id := range []string{"pod-a_10.0.0.1:8080", "pod-b_10.0.0.2:8080"} {
+		l := NewLeadership(k8s, "observability", "remediator", id)
+		l.LeaseDuration, l.RenewDeadline, l.RetryPeriod = time.Second, 500*time.Millisecond, 100*time.Millisecond
+		candidates = append(candidates, l)
+		go func() { _ = l.Run(ctx, func(context.Context) {}) }()
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		leaders := 0
+		for _, l := range candidates {
+			if l.IsLeader() {
+				leaders++
+			}
+		}
+		if leaders == 1 && candidates[0].Leader() != "" && candidates[0].Leader() == candidates[1].Leader() {
+			return
+		}
+		if leaders > 1 {
+			t.Fatal("both replicas lead at once")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("no single leader elected within 5s")
+}
+
+// followerOf makes this process a follower of a leader at addr for the test's duration.
+func followerOf(t *testing.T, addr string) {
+	t.Helper()
+	leadership = &Leadership{identity: "pod-a_127.0.0.1:1", client: http.DefaultClient, leader: "pod-b_" + addr,
+		secret: []byte("replicas-only")}
+	t.Cleanup(func() { leadership = nil })
+}
+
+func TestWebhookHandler_FollowerForwardsToLeader(t *testing.T) {
+	var gotBody, gotHeader string
+	var signed bool
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		gotBody, gotHeader = string(b), r.Header.Get(forwardedHeader)
+		signed = (&Leadership{secret: []byte("replicas-only")}).Forwarded(r, b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer leader.Close()
+	followerOf(t, strings.TrimPrefix(leader.URL, "http://"))
+
+	payload := `{"status":"firing","alerts":[{"status":"firing","labels":{"alertname":"HighErrorRate","service":"cart"}}]}`
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload))
+	req.Header.Set(forwardedHeader, "pod-c_10.0.0.3:8080") // unsigned: a sender's claim, not a replica's
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("follower status = %d, want 200: an unsigned forwarded header is still forwarded", w.Code)
+	}
+	if gotHeader != "pod-a_127.0.0.1:1" || !signed {
+		t.Errorf("forwarded header = %q (signed %v), want the follower's identity, signed", gotHeader, signed)
+	}
+	var fwd AlertmanagerWebhook
+	if err := json.Unmarshal([]byte(gotBody), &fwd); err != nil || len(fwd.Alerts) != 1 || fwd.Alerts[0].alertName() != "HighErrorRate" {
+		t.Errorf("leader got %q, want the forwarded alert", gotBody)
+	}
+}
+
+func TestWebhookHandler_FollowerWithoutLeaderAsksForRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		leader    string
+		forwarded bool
+	}{
+		{"no leader known", "", false},
+		{"leader unreachable", "127.0.0.1:1", false},
+		{"already forwarded once", "127.0.0.1:1", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			followerOf(t, tt.leader)
+			if tt.leader == "" {
+				leadership.leader = ""
+			}
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(`{"status":"firing","alerts":[]}`))
+			if tt.forwarded {
+				signForward(req, leadership, "pod-c_10.0.0.3:8080", time.Now())
+			}
+			w := httptest.NewRecorder()
+			newRouter().ServeHTTP(w, req)
+			if w.Code != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 503 so Alertmanager retries", w.Code)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_ReportsRole(t *testing.T) {
+	followerOf(t, "127.0.0.1:1")
+	for _, want := range []string{"follower", "leader"} {
+		if want == "leader" {
+			leadership.setLeading(true)
+		}
+		w := httptest.NewRecorder()
+		newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		var body map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+			t.Fatalf("healthz body not JSON: %v", err)
+		}
+		if body["role"] != want {
+			t.Errorf("healthz role = %q, want %q", body["role"], want)
+		}
+	}
+}
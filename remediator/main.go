@@ -1,26 +1,38 @@
 // Command remediator is OmniObserve's control-loop service. It receives Alertmanager
-// webhooks when an SLO burns and (in later steps) takes a bounded, auditable action to
-// stop the bleeding. This first cut is OBSERVE-ONLY: it parses alerts, logs them, and
-// counts them — no mutation yet. That lets us wire and validate the alert path end to
-// end before giving the loop any power.
+// webhooks when an SLO burns and takes a bounded, auditable action to stop the bleeding:
+// the alert's remediation_action picks one from the action registry — a flagd kill switch
+// or ramp-down (edited in the ConfigMap or committed to git), an Argo Rollouts abort or
+// undo, a Deployment rollback, or a bounded scale-up. Every action runs behind the same
+// guard checks: only the leader replica acts, and an action is refused while an operator
+// has paused the loop, while the incident's cooldown runs, when the policy denies it (or
+// wants a human to approve it) and once the blast-radius budget is spent; in dry-run it
+// is only planned. Executed changes are verified against the SLO, undone when the alert
+// resolves if they only make sense while it fires, and recorded in the audit log. With no
+// Kubernetes config it stays observe-only: it logs and counts alerts and changes nothing.
 package main
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 )
 
 // logger is the package-level structured logger, assigned in main (mirrors api-service).
@@ -29,10 +41,11 @@ var logger *zap.SugaredLogger
 // version is injected at build time via -ldflags "-X main.version=<git version>".
 var version = "dev"
 
-// flagRemediator performs the bounded action (disable a flagd flag). It is nil when no
-// in-cluster Kubernetes config is available (e.g. local `go run`, tests), in which case
-// the service stays observe-only — every action call site is nil-guarded.
-var flagRemediator *FlagRemediator
+// registry holds the bounded actions (flagd kill switch, Argo Rollouts abort/undo,
+// Deployment rollback, bounded scale-up) behind the shared cooldown and dry-run rails. It
+// is nil when no Kubernetes config (in-cluster or kubeconfig) is available (e.g. tests),
+// in which case the service stays observe-only — every action call site is nil-guarded.
+var registry *Registry
 
 var (
 	// alertsReceived: "what did the remediator see?"
@@ -44,13 +57,14 @@ var (
 		[]string{"alertname", "status"},
 	)
 	// actionsTotal: "what did the remediator do, and how did it turn out?" — the audit
-	// trail for every remediation decision (disabled/already_off/dry_run/cooldown/error).
+	// trail for every remediation decision (disabled/already_off/dry_run/cooldown/error),
+	// labelled by which registered action ran and what it targeted.
 	actionsTotal = prometheus.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "remediator_actions_total",
-			Help: "Remediation actions attempted, by flag and outcome.",
+			Help: "Remediation actions attempted, by action, target and outcome.",
 		},
-		[]string{"flag", "outcome"},
+		[]string{"action", "target", "outcome"},
 	)
 )
 
@@ -58,29 +72,147 @@ func init() {
 	prometheus.MustRegister(alertsReceived, actionsTotal)
 }
 
-// initRemediator builds the flagd action from env config, or returns nil (observe-only)
-// when there's no in-cluster Kubernetes API to act against.
-func initRemediator() *FlagRemediator {
-	cfg, err := rest.InClusterConfig()
+// initRemediator builds the action registry from env config, or returns nil
+// (observe-only) when there's no Kubernetes API to act against — neither in-cluster nor
+// through a kubeconfig (see kubeConfig).
+func initRemediator() (*Registry, kubernetes.Interface) {
+	cfg, source, err := kubeConfig()
 	if err != nil {
-		logger.Warnw("no in-cluster config; running observe-only (no actions)", "error", err)
-		return nil
+		logger.Warnw("no kubernetes config; running observe-only (no actions)", "error", err)
+		return nil, nil
 	}
 	clientset, err := kubernetes.NewForConfig(cfg)
 	if err != nil {
 		logger.Warnw("could not build kubernetes client; running observe-only", "error", err)
-		return nil
+		return nil, nil
+	}
+	dyn, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		logger.Warnw("could not build dynamic client; running observe-only", "error", err)
+		return nil, nil
 	}
 	cooldown := time.Duration(envInt("REMEDIATOR_COOLDOWN_SECONDS", 300)) * time.Second
 	dryRun := os.Getenv("REMEDIATOR_DRY_RUN") == "true"
-	r := NewFlagRemediator(clientset,
+	r := NewRegistry(dryRun, cooldown)
+	r.Soak = time.Duration(envInt("REMEDIATOR_RESTORE_SOAK_SECONDS", 600)) * time.Second
+	r.Budget = initBudget()
+	// Cooldowns and pending undos survive restarts in a ConfigMap next to the remediator.
+	// If it can't be read, start with empty in-memory state rather than overwrite it.
+	store := stateStore(clientset)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.UseStore(ctx, store); err != nil {
+		logger.Warnw("could not load remediation state; cooldowns won't survive a restart", "error", err)
+	}
+	flagd := NewFlagRemediator(clientset,
 		envStr("FLAGD_NAMESPACE", "otel-demo"),
 		envStr("FLAGD_CONFIGMAP", "flagd-config"),
 		envStr("FLAGD_CONFIG_KEY", "demo.flagd.json"),
-		dryRun, cooldown,
 	)
-	logger.Infow("flag remediator ready", "dryRun", dryRun, "cooldown", cooldown.String())
-	return r
+	flagd.AutoRestore = envStr("FLAGD_AUTO_RESTORE", "true") == "true"
+	flagd.Targeting = envStr("FLAGD_TARGETING", targetingKeep)
+	flagd.Git = initGitFlags()
+	// Bad targets are fatal: falling back to the default instance would send other teams'
+	// alerts to the wrong flagd.
+	if flagd.Targets, err = ParseFlagTargets(os.Getenv("FLAGD_TARGETS"), flagd.def); err != nil {
+		logger.Fatalw("invalid FLAGD_TARGETS", "error", err)
+	}
+	r.Register(actionFlagd, flagd)
+	steps, err := parseRampSteps(envStr("FLAGD_RAMP_STEPS", "50,10,0"))
+	if err != nil {
+		logger.Warnw("bad FLAGD_RAMP_STEPS; using 50,10,0", "error", err)
+		steps = []int{50, 10, 0}
+	}
+	ramp := NewFlagRamp(flagd, steps)
+	ramp.AutoRestore = flagd.AutoRestore
+	r.Register(actionFlagdRamp, ramp)
+	// An alert's namespace label may only send these to their default namespace or one
+	// allowed here: RBAC is granted, and self-checked, in exactly those.
+	rollout := NewRolloutRemediator(dyn, clientset, envStr("ROLLOUTS_NAMESPACE", "default"))
+	rollout.AllowedNamespaces = parseNamespaces(os.Getenv("ROLLOUTS_ALLOWED_NAMESPACES"))
+	r.Register(actionRollout, rollout)
+	deploymentsNS := envStr("DEPLOYMENTS_NAMESPACE", "default")
+	deploymentsAllowed := parseNamespaces(os.Getenv("DEPLOYMENTS_ALLOWED_NAMESPACES"))
+	rollback := NewRollbackRemediator(clientset, deploymentsNS)
+	rollback.AllowedNamespaces = deploymentsAllowed
+	r.Register(actionRollback, rollback)
+	limits, err := parseScaleLimits(os.Getenv("SCALE_TARGETS"))
+	if err != nil {
+		logger.Warnw("bad SCALE_TARGETS; scale action has no targets", "error", err)
+	}
+	for target := range limits {
+		if ns, _, _ := strings.Cut(target, "/"); !slices.Contains(namespacesOf(deploymentsNS, deploymentsAllowed), ns) {
+			logger.Warnw("scale target outside DEPLOYMENTS_NAMESPACE and DEPLOYMENTS_ALLOWED_NAMESPACES; it won't be scaled", "target", target)
+		}
+	}
+	scale := NewScaleRemediator(clientset, deploymentsNS, limits)
+	scale.AllowedNamespaces = deploymentsAllowed
+	r.Register(actionScale, scale)
+	if path := os.Getenv("POLICY_FILE"); path != "" {
+		// A policy that doesn't load is fatal: falling back to "allow everything" would
+		// silently drop the guardrails someone deliberately configured.
+		policy, err := LoadPolicy(path)
+		if err == nil {
+			err = r.UsePolicy(policy)
+		}
+		if err != nil {
+			logger.Fatalw("invalid remediation policy", "path", path, "error", err)
+		}
+		logger.Infow("remediation policy loaded", "path", path, "rules", len(policy.Rules))
+	} else {
+		logger.Warnw("no POLICY_FILE; any opted-in alert may run any registered action")
+	}
+	checkPermissions(ctx, clientset, r, map[string]*ConfigMapStore{"state": store, "queue": queueStore(clientset)})
+	logger.Infow("remediation registry ready", "kubernetes", source, "dryRun", dryRun, "cooldown", cooldown.String(),
+		"restoreSoak", r.Soak.String(), "flagAutoRestore", flagd.AutoRestore, "flagdTargets", len(flagd.Targets),
+		"budget", r.Budget)
+	return r, clientset
+}
+
+// stateStore is the ConfigMap next to the remediator that keeps its state.
+func stateStore(k8s kubernetes.Interface) *ConfigMapStore {
+	return NewConfigMapStore(k8s, envStr("POD_NAMESPACE", "default"),
+		envStr("REMEDIATOR_STATE_CONFIGMAP", "remediator-state"))
+}
+
+// queueStore is the ConfigMap next to the remediator that keeps its work queue, apart from
+// the state so neither can crowd the other out of the 1 MiB object limit.
+func queueStore(k8s kubernetes.Interface) *ConfigMapStore {
+	return NewConfigMapStore(k8s, envStr("POD_NAMESPACE", "default"),
+		envStr("REMEDIATOR_QUEUE_CONFIGMAP", "remediator-queue"))
+}
+
+// initLeadership starts leader election when LEADER_ELECTION_ENABLED is set, so several
+// replicas can run with only one acting. It returns nil (act as a singleton) otherwise.
+func initLeadership(ctx context.Context, k8s kubernetes.Interface) *Leadership {
+	if os.Getenv("LEADER_ELECTION_ENABLED") != "true" {
+		return nil
+	}
+	pod, _ := os.Hostname()
+	identity := envStr("POD_NAME", pod) + "_" + envStr("POD_IP", "127.0.0.1") + ":8080"
+	l := NewLeadership(k8s, envStr("POD_NAMESPACE", "default"), envStr("LEADER_ELECTION_LEASE", "remediator"), identity)
+	l.secret = []byte(os.Getenv("LEADER_FORWARD_SECRET"))
+	if len(l.secret) == 0 {
+		// Without it a forward can't be told from a sender claiming to be one.
+		logger.Fatalw("leader election needs LEADER_FORWARD_SECRET to sign forwarded webhooks")
+	}
+	isLeader.Set(0)
+	go func() {
+		// A new leader picks up the cooldowns and pending undos its predecessor wrote.
+		err := l.Run(ctx, func(ctx context.Context) {
+			if err := registry.Reload(ctx); err != nil {
+				logger.Warnw("could not reload remediation state on becoming leader", "error", err)
+			}
+			if err := workQueue.Load(ctx); err != nil {
+				logger.Warnw("could not reload the work queue on becoming leader", "error", err)
+			}
+		})
+		if err != nil {
+			logger.Errorw("leader election stopped; this replica won't act", "error", err)
+		}
+	}()
+	logger.Infow("leader election started", "identity", identity)
+	return l
 }
 
 func envStr(key, def string) string {
@@ -99,6 +231,14 @@ func envInt(key string, def int) int {
 	return def
 }
 
+// @title                       OmniObserve remediator
+// @version                     1.0
+// @description                 The remediator's incident API. The webhook, admin and approval endpoints are described in the README.
+// @BasePath                    /
+// @securityDefinitions.apikey  AdminToken
+// @in                          header
+// @name                        Authorization
+// @description                 "Bearer " followed by REMEDIATOR_ADMIN_TOKEN.
 func main() {
 	shutdownTracer, err := initTracer()
 	if err != nil {
@@ -110,35 +250,113 @@ func main() {
 	defer func() { _ = zapLogger.Sync() }()
 	logger = zapLogger.Sugar()
 
-	flagRemediator = initRemediator()
+	var clientset kubernetes.Interface
+	registry, clientset = initRemediator()
+	if path := os.Getenv("AUDIT_LOG_PATH"); path != "" {
+		if auditLog, err = OpenAuditLog(path); err != nil {
+			logger.Fatalw("could not open audit log", "path", path, "error", err)
+		}
+	} else {
+		logger.Warnw("no AUDIT_LOG_PATH; decisions are only logged and counted")
+	}
 	copilot, publisher = initCopilot()
+	if registry != nil {
+		verifier = initVerifier()
+		// Decisions also show up as Events on the objects they touch (`kubectl describe`).
+		var stopEvents func()
+		host, _ := os.Hostname()
+		actionEvents, stopEvents = NewActionEvents(clientset, host)
+		defer stopEvents()
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		workQueue = initQueue(ctx, queueStore(clientset))
+		cancel()
+		incidents = NewIncidents()
+		incidents.Retention = time.Duration(envInt("INCIDENT_RETENTION_HOURS", 24)) * time.Hour
+		leadership = initLeadership(context.Background(), clientset)
+		approvals = NewApprovalQueue(time.Duration(envInt("APPROVAL_TTL_SECONDS", 1800)) * time.Second)
+
+		// Every global the jobs and loops read is set by now: reloaded jobs run at once.
+		go workQueue.Run(context.Background())
+		go approvalLoop(context.Background(), 30*time.Second)
+		if every := envInt("DRIFT_CHECK_SECONDS", 60); every > 0 {
+			go driftLoop(context.Background(), time.Duration(every)*time.Second)
+		}
+		go restoreLoop(context.Background(), 15*time.Second) // even with no soak: resolves that arrive while paused wait here
+	}
+
+	if webhookAuth, err = initWebhookAuth(); err != nil {
+		logger.Fatalw("invalid webhook authentication config", "error", err)
+	}
+	if webhookAuth == nil {
+		logger.Warnw("POST /webhook is unauthenticated; set WEBHOOK_TOKEN, basic auth, WEBHOOK_HMAC_SECRET or a client CA")
+	}
 
 	router := gin.New()
 	router.Use(gin.Recovery())
 	router.Use(otelgin.Middleware("remediator")) // one span per request
 	router.Use(timeoutMiddleware(30 * time.Second))
 
-	router.POST("/webhook", webhookHandler) // Alertmanager posts here
+	router.POST("/webhook", webhookAuth.Middleware(), webhookHandler) // Alertmanager posts here
 	router.GET("/healthz", healthHandler)
 	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
 
+	admin := router.Group("/admin", adminAuth())
+	admin.POST("/budget/reset", budgetResetHandler)
+	admin.POST("/pause", pauseHandler)
+	admin.POST("/resume", resumeHandler)
+	admin.POST("/dry-run/:state", dryRunHandler)
+	admin.POST("/cooldowns/reset", cooldownResetHandler)
+	admin.GET("/queue", queueHandler)
+	admin.POST("/queue/dead/:id/retry", requeueHandler)
+	router.GET("/audit", adminAuth(), auditHandler)
+
+	// Human-in-the-loop: actions the policy marks "approve" wait here for a decision.
+	actions := router.Group("/actions", adminAuth())
+	actions.GET("", listApprovalsHandler)
+	actions.POST("/:id/approve", approveHandler)
+	actions.POST("/:id/deny", denyHandler)
+
+	// What is going on right now: incidents on the acting replica (spec: docs/swagger.yaml).
+	router.GET("/incidents", adminAuth(), listIncidentsHandler)
+	router.GET("/incidents/:key", adminAuth(), getIncidentHandler)
+	router.StaticFS("/ui", uiFS()) // the on-call view of the same, embedded
+
 	mode := "observe-only"
-	if flagRemediator != nil {
+	if registry != nil {
 		mode = "active"
 	}
-	logger.Infow("remediator starting", "version", version, "mode", mode)
+	tlsServer, err := webhookTLSServer(router)
+	if err != nil {
+		logger.Fatalw("invalid webhook TLS config", "error", err)
+	}
+	if tlsServer != nil {
+		go func() {
+			if err := tlsServer.ListenAndServeTLS("", ""); err != nil {
+				logger.Fatalw("webhook TLS listener stopped", "addr", tlsServer.Addr, "error", err)
+			}
+		}()
+	}
+	logger.Infow("remediator starting", "version", version, "mode", mode,
+		"webhookAuth", webhookAuth != nil, "tls", tlsServer != nil)
 	if err := router.Run(":8080"); err != nil {
 		panic(err)
 	}
 }
 
-// webhookHandler parses an Alertmanager webhook and, for now, only records what it sees.
-// Each firing alert becomes a log line, a counter increment, and a span event — the
-// raw material the action step (disable the offending feature flag) and the RCA copilot
-// will build on.
+// webhookHandler parses an Alertmanager webhook and records each alert as a log line, a
+// counter increment and a span event. On the acting replica, alerts that opt into an
+// action are saved to the work queue (or, without one, remediated inline: the incident
+// followed, the action run or undone) before the webhook is acknowledged; a follower
+// forwards the webhook to the leader instead.
 func webhookHandler(c *gin.Context) {
+	// Kept raw: a follower forwards exactly these bytes, so the leader can re-check an
+	// HMAC signature over them.
+	body, err := io.ReadAll(c.Request.Body)
 	var payload AlertmanagerWebhook
-	if err := c.ShouldBindJSON(&payload); err != nil {
+	if err == nil {
+		err = json.Unmarshal(body, &payload)
+	}
+	if err != nil {
 		logger.Warnw("webhook: bad payload", "error", err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook payload"})
 		return
@@ -150,8 +368,20 @@ func webhookHandler(c *gin.Context) {
 		attribute.Int("alertmanager.alerts", len(payload.Alerts)),
 	)
 
+	// A follower doesn't act: it forwards the webhook to the leader. One that has already
+	// been forwarded landed mid-handover; 503 makes Alertmanager retry it later. Only a
+	// forward another replica signed counts: a sender can't set the header to go uncounted.
+	forwarded := leadership.Forwarded(c.Request, body)
+	if !acting() && forwarded {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "not the leader"})
+		return
+	}
+
+	var jobs []Job
 	for _, alert := range payload.Alerts {
-		alertsReceived.WithLabelValues(alert.alertName(), alert.Status).Inc()
+		if !forwarded { // the forwarding follower already counted it
+			alertsReceived.WithLabelValues(alert.alertName(), alert.Status).Inc()
+		}
 		logger.Infow("alert received",
 			"alertname", alert.alertName(),
 			"status", alert.Status,
@@ -164,45 +394,207 @@ func webhookHandler(c *gin.Context) {
 			attribute.String("status", alert.Status),
 			attribute.String("incident_key", alert.incidentKey()),
 		))
-		remediate(c.Request.Context(), span, alert)
+		if !acting() {
+			continue
+		}
+		if workQueue == nil {
+			_ = remediate(c.Request.Context(), span, alert) // errors are recorded; Alertmanager won't fix them by retrying
+		} else if _, _, ok := registry.Select(alert); ok {
+			jobs = append(jobs, Job{Kind: jobAlert, Alert: alert, Trace: traceOf(span)})
+		}
+	}
+	// Acknowledge only once the alerts are safely queued; if they can't be, a 503 has
+	// Alertmanager send them again.
+	if len(jobs) > 0 {
+		if err := workQueue.Enqueue(c.Request.Context(), jobs...); err != nil {
+			logger.Warnw("webhook: could not queue alerts", "alerts", len(jobs), "error", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "could not queue alerts; retry"})
+			return
+		}
+		span.AddEvent("queued", trace.WithAttributes(attribute.Int("jobs", len(jobs))))
+	}
+
+	if !acting() {
+		if err := leadership.Forward(c.Request.Context(), body, webhookAuth.forwardHeaders(c.Request)); err != nil {
+			logger.Warnw("webhook: could not forward to leader", "error", err)
+			webhooksForwarded.WithLabelValues("error").Inc()
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "leader unavailable"})
+			return
+		}
+		webhooksForwarded.WithLabelValues("forwarded").Inc()
+		span.AddEvent("forwarded", trace.WithAttributes(attribute.String("leader", leadership.Leader())))
 	}
 
-	c.JSON(http.StatusOK, gin.H{"received": len(payload.Alerts)})
+	c.JSON(http.StatusOK, gin.H{"received": len(payload.Alerts), "queued": len(jobs)})
 }
 
 // remediate runs the bounded action for one alert: only firing alerts that explicitly
-// name a flag are acted on, and only when the remediator is active (has a cluster).
-func remediate(ctx context.Context, span trace.Span, alert Alert) {
-	flag := alert.remediationFlag()
-	if alert.Status != "firing" || flag == "" || flagRemediator == nil {
-		return
+// opt into a registered action are acted on, and only when the remediator is active
+// (has a cluster), and only while its incident is firing or the last action proved
+// ineffective. A resolved alert undoes actions that only last as long as the alert.
+// The error is the action's, already recorded; the work queue retries on it.
+func remediate(ctx context.Context, span trace.Span, alert Alert) error {
+	if registry == nil {
+		return nil
+	}
+	name, _, ok := registry.Select(alert)
+	if !ok {
+		return nil
+	}
+
+	if alert.Status != "firing" && alert.Status != "resolved" {
+		return nil
+	}
+	inc, tr := incidents.Observe(alert)
+	if tr != nil {
+		onIncidentTransition(span, inc, *tr, Result{})
+	}
+
+	if alert.Status == "resolved" {
+		res, undone, err := registry.Resolve(ctx, alert)
+		if undone {
+			recordAction(span, name, alert.incidentKey(), res, err)
+		}
+		return err
 	}
+	if !inc.State.acts() {
+		logger.Debugw("incident already handled; not acting again", "incident_key", inc.Key, "state", inc.State)
+		return nil
+	}
+
+	res, err := registry.Run(ctx, alert)
+	if err == nil && res.Outcome == OutcomePendingApproval {
+		queueApproval(ctx, span, name, alert, res)
+		return nil
+	}
+	recordAction(span, name, alert.incidentKey(), res, err)
+	return err
+}
 
-	outcome, err := flagRemediator.DisableFlag(ctx, flag, alert.incidentKey())
-	result := string(outcome)
+// processJob is the work queue's handler: one alert, or one RCA, in a span that carries
+// on the trace of the webhook that queued it.
+func processJob(ctx context.Context, j Job) error {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(j.Trace))
+	ctx, span := otel.Tracer("remediator").Start(ctx, "queue."+j.Kind, trace.WithAttributes(
+		attribute.String("incident_key", j.Alert.incidentKey()),
+		attribute.Int("attempt", j.Attempts+1),
+	))
+	defer span.End()
+	if j.Kind == jobRCA {
+		return draftRCA(ctx, j.Alert, j.Action)
+	}
+	return remediate(ctx, span, j.Alert)
+}
+
+// onIncidentTransition is where the remediator's follow-ups hang off an incident's
+// lifecycle. An action that executed (mitigating) gets a grounded RCA, once per incident,
+// and is checked against the SLO in the background; one that turned out ineffective is
+// escalated to humans. Async so neither the LLM call nor the minutes-long watch blocks
+// the caller. cause is the decision behind the transition, empty for a notification.
+func onIncidentTransition(span trace.Span, inc Incident, tr Transition, cause Result) {
+	logger.Infow("incident transition", "incident_key", inc.Key, "from", tr.From, "to", tr.To, "reason", tr.Reason)
+	span.AddEvent("incident", trace.WithAttributes(
+		attribute.String("incident_key", inc.Key),
+		attribute.String("from", string(tr.From)),
+		attribute.String("to", string(tr.To)),
+	))
+	switch tr.To {
+	case IncidentMitigating:
+		if incidents.requestRCA(inc.Key) {
+			queueRCA(span, inc.Alert, cause.Plan.Description)
+		}
+		if _, ramp := registryAction(cause.Plan.Action).(*FlagRamp); ramp {
+			go rampDown(inc.Alert, cause) // verifies between steps, then after the last
+		} else {
+			go verifyAction(inc.Alert, cause)
+		}
+	case IncidentIneffective:
+		escalate(trace.ContextWithSpan(context.Background(), span), inc.Alert, cause, cause.ErrorRatio)
+	}
+}
+
+// recordAction is the audit trail for one action decision: a log line, the
+// remediator_actions_total counter, a span event, and an audit log record. The decision
+// then moves its incident on, and the transition starts whatever follows.
+func recordAction(span trace.Span, name, incidentKey string, res Result, err error) {
+	result := string(res.Outcome)
 	if err != nil {
 		result = "error"
-		logger.Errorw("remediation failed", "flag", flag, "incident_key", alert.incidentKey(), "error", err)
+		logger.Errorw("remediation failed",
+			"action", name, "target", res.Plan.Target, "incident_key", incidentKey, "error", err)
 	} else {
 		logger.Infow("remediation",
-			"flag", flag, "outcome", result, "incident_key", alert.incidentKey())
+			"action", name, "target", res.Plan.Target, "outcome", result, "incident_key", incidentKey,
+			"params", res.Plan.Params, "policy_rule", res.Rule)
 	}
-	actionsTotal.WithLabelValues(flag, result).Inc()
+	actionsTotal.WithLabelValues(name, res.Plan.Target, result).Inc()
 	span.AddEvent("remediation", trace.WithAttributes(
-		attribute.String("flag", flag),
+		attribute.String("action", name),
+		attribute.String("target", res.Plan.Target),
 		attribute.String("outcome", result),
+		attribute.String("policy_rule", res.Rule),
 	))
+	audit(span, name, incidentKey, res, err)
+	emitEvent(name, incidentKey, res, err)
+	if inc, tr := incidents.Record(incidentKey, res, err); tr != nil {
+		onIncidentTransition(span, inc, *tr, res)
+	}
+}
 
-	// When we actually disabled a flag (once per incident — repeats hit cooldown), draft
-	// a grounded RCA in the background. Async so the LLM call never blocks the webhook.
-	if outcome == OutcomeDisabled {
-		go draftRCA(alert, "disabled flagd flag "+flag)
+// restoreLoop periodically reverses actions whose incidents resolved and stayed quiet for
+// the soak period. Each restore is its own traced, counted and logged decision.
+func restoreLoop(ctx context.Context, every time.Duration) {
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if acting() {
+			restoreDue(ctx)
+		}
+	}
+}
+
+// restoreDue runs the undos that are due and records each as its own decision.
+func restoreDue(ctx context.Context) {
+	tracer := otel.Tracer("remediator")
+	for _, rs := range registry.RestoreDue(ctx) {
+		_, span := tracer.Start(ctx, "restore")
+		recordAction(span, rs.Plan.Action, rs.Plan.IncidentKey, rs.Result, rs.Err)
+		span.End()
+	}
+}
+
+// driftLoop periodically checks that the changes we made are still in place, recording
+// each one a GitOps controller (reverted_by_gitops) or anyone else has put back.
+func driftLoop(ctx context.Context, every time.Duration) {
+	tracer := otel.Tracer("remediator")
+	ticker := time.NewTicker(every)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if !acting() {
+			continue
+		}
+		for _, rs := range registry.CheckDrift(ctx) {
+			_, span := tracer.Start(ctx, "drift")
+			recordAction(span, rs.Plan.Action, rs.Plan.IncidentKey, rs.Result, rs.Err)
+			span.End()
+		}
 	}
 }
 
-// healthHandler reports liveness and the build version (same contract as api-service).
+// healthHandler reports liveness and the build version (same contract as api-service),
+// plus this replica's leader-election role.
 func healthHandler(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version})
+	c.JSON(http.StatusOK, gin.H{"status": "ok", "version": version, "role": role(), "mode": mode()})
 }
 
 // timeoutMiddleware bounds handler execution so a slow downstream can't pile up requests.
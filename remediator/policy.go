@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+	_ "time/tzdata" // rule timezones must resolve in the alpine image, which has no zoneinfo
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy is the declarative allowlist for remediation, loaded from POLICY_FILE. Without
+// one, any alert can have any registered action run on any target just by annotating
+// itself; with one, an action only runs when a rule allows it. Rules are tried in order
+// and the first whose selectors all match the alert and plan decides. An alert no rule
+// matches is denied by the implicit "default" rule.
+type Policy struct {
+	Rules []PolicyRule `yaml:"rules"`
+}
+
+// PolicyRule is one allow, deny or approve decision. Every selector left empty matches anything.
+type PolicyRule struct {
+	Name       string   `yaml:"name"`
+	Effect     string   `yaml:"effect"`     // allow (default), deny, or approve (allow once a human approves)
+	Actions    []string `yaml:"actions"`    // registry names, e.g. flagd, scale
+	Targets    []string `yaml:"targets"`    // plan targets: flag names, namespace/deployment
+	Alertnames []string `yaml:"alertnames"` // alertname label
+	Services   []string `yaml:"services"`   // service label
+	Severities []string `yaml:"severities"` // severity label
+	Namespaces []string `yaml:"namespaces"` // namespace the action mutates
+	// Hours limits an allow or approve rule to a daily window, "HH:MM-HH:MM" in Timezone
+	// (UTC by default); a window may wrap midnight. Outside it the rule denies.
+	Hours    string `yaml:"hours"`
+	Timezone string `yaml:"timezone"`
+
+	from, to time.Duration // parsed Hours, as offsets from midnight
+	loc      *time.Location
+}
+
+// defaultRule names the implicit deny for alerts no rule matches.
+const defaultRule = "default"
+
+// Verdict is what the policy decides for one planned action.
+type Verdict string
+
+const (
+	VerdictAllow   Verdict = "allow"
+	VerdictDeny    Verdict = "deny"
+	VerdictApprove Verdict = "approve" // prepare it, but only run it once a human approves
+)
+
+// LoadPolicy reads and validates a policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read policy: %w", err)
+	}
+	return ParsePolicy(raw)
+}
+
+// ParsePolicy decodes and validates a policy document. Unknown keys are errors: a typo'd
+// selector would otherwise silently match everything.
+func ParsePolicy(raw []byte) (*Policy, error) {
+	var p Policy
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(&p); err != nil {
+		return nil, fmt.Errorf("parse policy: %w", err)
+	}
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("policy has no rules")
+	}
+	seen := map[string]bool{defaultRule: true}
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.Name == "" || seen[rule.Name] {
+			return nil, fmt.Errorf("policy rule #%d: name %q is empty, reserved or duplicated", i+1, rule.Name)
+		}
+		seen[rule.Name] = true
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("policy rule %q: %w", rule.Name, err)
+		}
+	}
+	return &p, nil
+}
+
+// compile checks the rule's effect and parses its time window.
+func (r *PolicyRule) compile() error {
+	switch r.Effect {
+	case "":
+		r.Effect = "allow"
+	case "allow", "deny", "approve":
+	default:
+		return fmt.Errorf("effect %q: want allow, deny or approve", r.Effect)
+	}
+
+	r.loc = time.UTC
+	if r.Timezone != "" {
+		loc, err := time.LoadLocation(r.Timezone)
+		if err != nil {
+			return fmt.Errorf("timezone %q: %w", r.Timezone, err)
+		}
+		r.loc = loc
+	}
+	if r.Hours == "" {
+		return nil
+	}
+	from, to, ok := strings.Cut(r.Hours, "-")
+	if !ok {
+		return fmt.Errorf("hours %q: want HH:MM-HH:MM", r.Hours)
+	}
+	var err error
+	if r.from, err = clock(from); err != nil {
+		return fmt.Errorf("hours %q: %w", r.Hours, err)
+	}
+	if r.to, err = clock(to); err != nil {
+		return fmt.Errorf("hours %q: %w", r.Hours, err)
+	}
+	return nil
+}
+
+// clock parses "HH:MM" as an offset from midnight.
+func clock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("%q is not HH:MM", s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Validate checks every action a rule names is registered — a rule for an action that
+// doesn't exist is a typo, not a no-op.
+func (p *Policy) Validate(actions map[string]Action) error {
+	for _, rule := range p.Rules {
+		for _, a := range rule.Actions {
+			if _, ok := actions[a]; !ok {
+				return fmt.Errorf("policy rule %q: unknown action %q", rule.Name, a)
+			}
+		}
+	}
+	return nil
+}
+
+// Decide returns the verdict on running the planned action for the alert at now, and the
+// name of the rule that decided. Outside its hours, an allow or approve rule denies.
+func (p *Policy) Decide(alert Alert, plan Plan, now time.Time) (Verdict, string) {
+	for _, rule := range p.Rules {
+		if !rule.matches(alert, plan) {
+			continue
+		}
+		if rule.Effect == "deny" || !rule.inHours(now) {
+			return VerdictDeny, rule.Name
+		}
+		return Verdict(rule.Effect), rule.Name
+	}
+	return VerdictDeny, defaultRule
+}
+
+func (r *PolicyRule) matches(alert Alert, plan Plan) bool {
+	return selects(r.Actions, plan.Action) &&
+		selects(r.Targets, plan.Target) &&
+		selects(r.Alertnames, alert.alertName()) &&
+		selects(r.Services, alert.Labels["service"]) &&
+		selects(r.Severities, alert.Labels["severity"]) &&
+		selects(r.Namespaces, plan.Params["namespace"])
+}
+
+// inHours reports whether now falls in the rule's daily window (always, without one).
+func (r *PolicyRule) inHours(now time.Time) bool {
+	if r.Hours == "" {
+		return true
+	}
+	local := now.In(r.loc)
+	at := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute
+	if r.from <= r.to {
+		return at >= r.from && at < r.to
+	}
+	return at >= r.from || at < r.to // wraps midnight, e.g. 22:00-06:00
+}
+
+// selects is a selector match: an empty list matches anything.
+func selects(allowed []string, v string) bool {
+	return len(allowed) == 0 || slices.Contains(allowed, v)
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPolicy = `
+rules:
+  - name: no-payment-flags
+    effect: deny
+    targets: [paymentFailure]
+  - name: cart-kill-switch-office-hours
+    actions: [flagd]
+    services: [cart]
+    severities: [critical]
+    namespaces: [otel-demo]
+    hours: "08:00-20:00"
+    timezone: Europe/Berlin
+  - name: scale-overnight
+    effect: approve
+    actions: [scale]
+    alertnames: [ApiServiceHighLatency]
+    hours: "22:00-06:00"
+`
+
+func TestParsePolicy_Rejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		doc     string
+		wantErr string
+	}{
+		{"no rules", "rules: []", "no rules"},
+		{"unknown key", "rules:\n  - name: a\n    service: [cart]", "field service not found"},
+		{"unnamed rule", "rules:\n  - actions: [flagd]", "name"},
+		{"duplicate name", "rules:\n  - name: a\n  - name: a", "duplicated"},
+		{"reserved name", "rules:\n  - name: default", "reserved"},
+		{"bad effect", "rules:\n  - name: a\n    effect: maybe", "effect"},
+		{"bad hours", "rules:\n  - name: a\n    hours: 9-17", "HH:MM"},
+		{"bad timezone", "rules:\n  - name: a\n    timezone: Mars/Olympus", "timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParsePolicy([]byte(tt.doc))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParsePolicy error = %v, want one mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPolicy_Validate(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	if err := p.Validate(map[string]Action{actionFlagd: &stubAction{}}); err == nil || !strings.Contains(err.Error(), `"scale"`) {
+		t.Errorf("Validate without scale registered = %v, want unknown action scale", err)
+	}
+	if err := p.Validate(map[string]Action{actionFlagd: &stubAction{}, actionScale: &stubAction{}}); err != nil {
+		t.Errorf("Validate = %v, want nil", err)
+	}
+}
+
+func TestPolicy_Decide(t *testing.T) {
+	p, err := ParsePolicy([]byte(testPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	cart := Alert{Labels: map[string]string{"alertname": "CartHighErrorRate", "service": "cart", "severity": "critical"}}
+	flag := Plan{Action: actionFlagd, Target: "cartFailure", Params: map[string]string{"namespace": "otel-demo"}}
+	noonBerlin := time.Date(2026, 5, 4, 10, 0, 0, 0, time.UTC)  // 12:00 CEST
+	nightBerlin := time.Date(2026, 5, 4, 21, 0, 0, 0, time.UTC) // 23:00 CEST
+
+	tests := []struct {
+		name     string
+		alert    Alert
+		plan     Plan
+		now      time.Time
+		want     Verdict
+		wantRule string
+	}{
+		{"allowed in hours", cart, flag, noonBerlin, VerdictAllow, "cart-kill-switch-office-hours"},
+		{"outside hours", cart, flag, nightBerlin, VerdictDeny, "cart-kill-switch-office-hours"},
+		{"deny rule wins by order", cart,
+			Plan{Action: actionFlagd, Target: "paymentFailure", Params: map[string]string{"namespace": "otel-demo"}},
+			noonBerlin, VerdictDeny, "no-payment-flags"},
+		{"severity not allowed", Alert{Labels: map[string]string{"service": "cart", "severity": "warning"}},
+			flag, noonBerlin, VerdictDeny, defaultRule},
+		{"other namespace", cart,
+			Plan{Action: actionFlagd, Target: "cartFailure", Params: map[string]string{"namespace": "prod"}},
+			noonBerlin, VerdictDeny, defaultRule},
+		{"approval in window wrapping midnight", Alert{Labels: map[string]string{"alertname": "ApiServiceHighLatency"}},
+			Plan{Action: actionScale, Target: "default/api-service"},
+			time.Date(2026, 5, 4, 2, 30, 0, 0, time.UTC), VerdictApprove, "scale-overnight"},
+		{"outside wrapping window", Alert{Labels: map[string]string{"alertname": "ApiServiceHighLatency"}},
+			Plan{Action: actionScale, Target: "default/api-service"},
+			time.Date(2026, 5, 4, 12, 0, 0, 0, time.UTC), VerdictDeny, "scale-overnight"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule := p.Decide(tt.alert, tt.plan, tt.now)
+			if got != tt.want || rule != tt.wantRule {
+				t.Errorf("Decide = (%q, %q), want (%q, %q)", got, rule, tt.want, tt.wantRule)
+			}
+		})
+	}
+}
+
+func TestRegistry_PolicyDeniedSkipsExecute(t *testing.T) {
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+	p, err := ParsePolicy([]byte("rules:\n  - name: stub-only-for-checkout\n    actions: [stub]\n    services: [checkout]"))
+	if err != nil {
+		t.Fatalf("ParsePolicy: %v", err)
+	}
+	if err := r.UsePolicy(p); err != nil {
+		t.Fatalf("UsePolicy: %v", err)
+	}
+
+	res, err := r.Run(context.Background(), stubAlert()) // service=cart
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomePolicyDenied || res.Rule != defaultRule || a.executed != 0 {
+		t.Errorf("run = %+v (executed %d), want policy_denied by %q without executing", res, a.executed, defaultRule)
+	}
+
+	checkout := stubAlert()
+	checkout.Labels["service"] = "checkout"
+	res, err = r.Run(context.Background(), checkout)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !res.Executed || res.Rule != "stub-only-for-checkout" {
+		t.Errorf("run = %+v, want executed under stub-only-for-checkout", res)
+	}
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// workQueue sits between the webhook and the actions: the webhook persists each alert and
+// answers straight away, and a bounded pool of workers runs them, with retries. Nil
+// (observe-only) means the webhook handler remediates inline.
+var workQueue *Queue
+
+// Kinds of queued work.
+const (
+	jobAlert = "alert" // remediate one alert
+	jobRCA   = "rca"   // draft and publish the RCA for an executed action
+)
+
+// jobTimeout bounds one attempt at a job; an RCA draft alone may take 90s.
+const jobTimeout = 2 * time.Minute
+
+// maxJobBackoff caps the doubling delay between a job's attempts.
+const maxJobBackoff = 10 * time.Minute
+
+var errQueueFull = errors.New("work queue is full")
+
+// queueJobs: "is queued work getting done?" — dead_lettered should stay 0.
+var queueJobs = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "remediator_queue_jobs_total",
+		Help: "Work queue jobs by kind and result (done/retried/dead_lettered/deduplicated).",
+	},
+	[]string{"kind", "result"},
+)
+
+func init() {
+	stat := func(f func(QueueStats) float64) func() float64 {
+		return func() float64 {
+			if workQueue == nil {
+				return 0
+			}
+			return f(workQueue.Stats())
+		}
+	}
+	prometheus.MustRegister(queueJobs,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remediator_queue_depth",
+			Help: "Jobs waiting in the work queue, including those being worked on.",
+		}, stat(func(s QueueStats) float64 { return float64(s.Depth) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remediator_queue_oldest_age_seconds",
+			Help: "Age of the oldest job in the work queue; 0 when empty.",
+		}, stat(func(s QueueStats) float64 { return s.OldestAge.Seconds() })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remediator_queue_dead_letters",
+			Help: "Jobs that ran out of attempts and wait for an operator (GET /admin/queue).",
+		}, stat(func(s QueueStats) float64 { return float64(s.Dead) })),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "remediator_queue_worker_utilization",
+			Help: "Share of the work queue's workers busy with a job, 0 to 1.",
+		}, stat(func(s QueueStats) float64 { return s.Utilization })),
+	)
+}
+
+// Job is one unit of queued work. Jobs for the same incident and kind run one at a time,
+// in the order they were queued, so a resolve never overtakes the firing alert before it.
+type Job struct {
+	ID        string            `json:"id"`
+	Kind      string            `json:"kind"`
+	Alert     Alert             `json:"alert"`
+	Action    string            `json:"action,omitempty"` // jobRCA: what was done, for the copilot
+	Enqueued  time.Time         `json:"enqueued"`
+	Attempts  int               `json:"attempts,omitempty"`
+	NotBefore time.Time         `json:"notBefore,omitempty"` // not retried before this
+	LastError string            `json:"lastError,omitempty"`
+	Trace     map[string]string `json:"trace,omitempty"` // trace context of the request that queued it
+}
+
+// key serializes jobs: one per incident and kind at a time.
+func (j Job) key() string { return j.Kind + "|" + j.Alert.incidentKey() }
+
+// QueueState is what the queue persists: jobs still to do, and those given up on.
+type QueueState struct {
+	Pending []Job `json:"pending,omitempty"`
+	Dead    []Job `json:"dead,omitempty"`
+}
+
+func (s QueueState) clone() QueueState {
+	return QueueState{Pending: slices.Clone(s.Pending), Dead: slices.Clone(s.Dead)}
+}
+
+// QueueStore persists the queue, like StateStore does the registry's state: loaded once,
+// written whole after every change. In the cluster it is its own ConfigMap, so a backlog
+// of alerts can't push the registry's state past the 1 MiB object limit.
+type QueueStore interface {
+	LoadQueue(ctx context.Context) (QueueState, error)
+	SaveQueue(ctx context.Context, q QueueState) error
+}
+
+// QueueStats is the queue at a glance, for the metrics.
+type QueueStats struct {
+	Depth, Dead int
+	OldestAge   time.Duration
+	Utilization float64
+}
+
+// Queue is a persisted work queue with a bounded worker pool. A job is saved before
+// Enqueue returns and removed only once handled, so a crash means it runs again rather
+// than never: handlers must be idempotent, which cooldowns make the actions. A job whose
+// handler fails is retried with doubling backoff, and after MaxAttempts is moved to the
+// dead letters, where it stays until an operator requeues it.
+type Queue struct {
+	store  QueueStore
+	handle func(context.Context, Job) error
+	now    func() time.Time
+
+	Workers     int
+	MaxAttempts int
+	Backoff     time.Duration // delay before the first retry; doubles after each
+	MaxPending  int           // Enqueue fails beyond this, so the sender retries later
+	MaxDead     int           // oldest dead letters are dropped beyond this
+	// MaxBytes bounds the saved queue's JSON, which must fit in one ConfigMap: Enqueue
+	// fails beyond it, and dead letters are dropped oldest first to stay under it. 0 means
+	// no bound.
+	MaxBytes int
+
+	saveMu  sync.Mutex // orders saves, which happen outside mu
+	mu      sync.Mutex
+	pending []Job
+	dead    []Job
+	running map[string]string // job key -> ID of the job being handled
+	wake    chan struct{}
+}
+
+// NewQueue builds a queue that runs handle for each job and persists to store (nil keeps
+// it in memory).
+func NewQueue(store QueueStore, handle func(context.Context, Job) error) *Queue {
+	return &Queue{
+		store: store, handle: handle, now: time.Now,
+		Workers: 4, MaxAttempts: 5, Backoff: 5 * time.Second, MaxPending: 500, MaxDead: 100,
+		MaxBytes: 768 << 10, // under the 1 MiB ConfigMap limit, with room for metadata
+		running:  map[string]string{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// initQueue builds the work queue over store and loads what was left in it: QUEUE_WORKERS
+// workers, QUEUE_MAX_ATTEMPTS tries per job, QUEUE_RETRY_BACKOFF_SECONDS before the first
+// retry, at most QUEUE_MAX_PENDING jobs waiting and QUEUE_MAX_BYTES saved. The caller
+// starts it with Run.
+func initQueue(ctx context.Context, store QueueStore) *Queue {
+	q := NewQueue(store, processJob)
+	q.Workers = max(1, envInt("QUEUE_WORKERS", q.Workers))
+	q.MaxAttempts = max(1, envInt("QUEUE_MAX_ATTEMPTS", q.MaxAttempts))
+	q.Backoff = time.Duration(envInt("QUEUE_RETRY_BACKOFF_SECONDS", int(q.Backoff.Seconds()))) * time.Second
+	q.MaxPending = envInt("QUEUE_MAX_PENDING", q.MaxPending)
+	q.MaxBytes = envInt("QUEUE_MAX_BYTES", q.MaxBytes)
+	if err := q.Load(ctx); err != nil {
+		logger.Warnw("could not load the work queue; queued alerts from before the restart are lost", "error", err)
+	}
+	st := q.Stats()
+	logger.Infow("work queue ready", "workers", q.Workers, "maxAttempts", q.MaxAttempts,
+		"pending", st.Depth, "deadLetters", st.Dead)
+	return q
+}
+
+// Load replaces the queue with the persisted one — on startup, and when this replica
+// becomes leader and takes over its predecessor's queue.
+func (q *Queue) Load(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+	st, err := q.store.LoadQueue(ctx)
+	if err != nil {
+		return fmt.Errorf("load queue: %w", err)
+	}
+	q.mu.Lock()
+	q.pending, q.dead = st.Pending, st.Dead
+	q.mu.Unlock()
+	q.signal()
+	return nil
+}
+
+// Enqueue persists jobs, filling in their IDs and times. A firing or resolved alert
+// already waiting for the same incident in the same state — an Alertmanager retry — is
+// updated in place instead of queued twice. Nothing is queued if the queue is full or
+// can't be saved; the caller should have the sender retry. The save happens outside the
+// lock, so a worker may already have taken a job whose save then fails: that one stays,
+// and the cooldown absorbs the sender's retry.
+func (q *Queue) Enqueue(ctx context.Context, jobs ...Job) error {
+	q.mu.Lock()
+	prev := slices.Clone(q.pending)
+	var added, dups []string
+	for _, j := range jobs {
+		if i := q.duplicate(j); i >= 0 {
+			q.pending[i].Alert = j.Alert
+			dups = append(dups, j.Kind)
+			continue
+		}
+		if len(q.pending) >= q.MaxPending {
+			q.pending = prev
+			q.mu.Unlock()
+			return errQueueFull
+		}
+		if j.ID == "" {
+			id := make([]byte, 8)
+			_, _ = rand.Read(id)
+			j.ID = hex.EncodeToString(id)
+		}
+		if j.Enqueued.IsZero() {
+			j.Enqueued = q.now()
+		}
+		q.pending = append(q.pending, j)
+		added = append(added, j.ID)
+	}
+	if q.oversize() {
+		q.pending = prev
+		q.mu.Unlock()
+		return errQueueFull
+	}
+	q.mu.Unlock()
+
+	if err := q.save(ctx); err != nil {
+		q.mu.Lock()
+		q.pending = slices.DeleteFunc(q.pending, func(j Job) bool {
+			return slices.Contains(added, j.ID) && q.running[j.key()] != j.ID
+		})
+		q.mu.Unlock()
+		return err
+	}
+	for _, kind := range dups {
+		queueJobs.WithLabelValues(kind, "deduplicated").Inc()
+	}
+	q.signal()
+	return nil
+}
+
+// duplicate finds the latest pending alert job for j's incident, if it has j's status and
+// isn't already being handled; -1 otherwise. q.mu must be held.
+func (q *Queue) duplicate(j Job) int {
+	if j.Kind != jobAlert {
+		return -1
+	}
+	for i := len(q.pending) - 1; i >= 0; i-- {
+		p := q.pending[i]
+		if p.key() != j.key() {
+			continue
+		}
+		if p.Alert.Status != j.Alert.Status || q.running[p.key()] == p.ID {
+			return -1
+		}
+		return i
+	}
+	return -1
+}
+
+// Requeue moves the dead letter id back to the end of the queue with fresh attempts.
+func (q *Queue) Requeue(ctx context.Context, id string) (bool, error) {
+	q.mu.Lock()
+	i := slices.IndexFunc(q.dead, func(j Job) bool { return j.ID == id })
+	if i < 0 {
+		q.mu.Unlock()
+		return false, nil
+	}
+	dead := q.dead[i]
+	j := dead
+	j.Attempts, j.NotBefore, j.LastError = 0, time.Time{}, ""
+	q.dead = slices.Delete(q.dead, i, i+1)
+	q.pending = append(q.pending, j)
+	q.mu.Unlock()
+
+	if err := q.save(ctx); err != nil {
+		q.mu.Lock()
+		if i := slices.IndexFunc(q.pending, func(p Job) bool { return p.ID == id }); i >= 0 && q.running[j.key()] != id {
+			q.pending = slices.Delete(q.pending, i, i+1)
+			q.dead = append(q.dead, dead)
+		}
+		q.mu.Unlock()
+		return false, err
+	}
+	q.signal()
+	return true, nil
+}
+
+// Run starts the workers and blocks until ctx is done. Workers only take jobs while this
+// replica is acting; a follower's queue waits for it to become leader.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for range q.Workers {
+		wg.Go(func() {
+			ticker := time.NewTicker(time.Second) // retries coming due, leadership changing
+			defer ticker.Stop()
+			for {
+				q.drain(ctx)
+				select {
+				case <-ctx.Done():
+					return
+				case <-q.wake:
+				case <-ticker.C:
+				}
+			}
+		})
+	}
+	wg.Wait()
+}
+
+// drain handles due jobs one after another until there is none this worker may take,
+// and returns how many it handled.
+func (q *Queue) drain(ctx context.Context) int {
+	n := 0
+	for acting() && ctx.Err() == nil {
+		j, ok := q.claim()
+		if !ok {
+			break
+		}
+		q.finish(ctx, j, q.run(ctx, j))
+		n++
+	}
+	return n
+}
+
+// claim takes the oldest due job whose incident has nothing running or waiting ahead of it.
+func (q *Queue) claim() (Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	now := q.now()
+	blocked := map[string]bool{}
+	for _, j := range q.pending {
+		k := j.key()
+		if blocked[k] || q.running[k] != "" || now.Before(j.NotBefore) {
+			blocked[k] = true // later jobs for this incident wait their turn
+			continue
+		}
+		q.running[k] = j.ID
+		q.signal() // there may be more for another worker
+		return j, true
+	}
+	return Job{}, false
+}
+
+// run handles one attempt at j, turning a panic into an error so it's retried too.
+func (q *Queue) run(ctx context.Context, j Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return q.handle(ctx, j)
+}
+
+// finish records the outcome of an attempt: done jobs leave the queue, failed ones wait
+// for a retry or, out of attempts, become dead letters.
+func (q *Queue) finish(ctx context.Context, j Job, err error) {
+	defer q.signal()
+	q.mu.Lock()
+	delete(q.running, j.key())
+	i := slices.IndexFunc(q.pending, func(p Job) bool { return p.ID == j.ID })
+	if i < 0 {
+		q.mu.Unlock()
+		return // the queue was reloaded meanwhile
+	}
+	result := "done"
+	switch {
+	case err == nil:
+		q.pending = slices.Delete(q.pending, i, i+1)
+	case j.Attempts+1 >= q.MaxAttempts:
+		result = "dead_lettered"
+		j.Attempts, j.LastError = j.Attempts+1, err.Error()
+		q.pending = slices.Delete(q.pending, i, i+1)
+		q.dead = append(q.dead, j)
+		if len(q.dead) > q.MaxDead {
+			q.dead = slices.Delete(q.dead, 0, len(q.dead)-q.MaxDead)
+		}
+		logger.Errorw("queued job failed for the last time; dead-lettered",
+			"id", j.ID, "kind", j.Kind, "incident_key", j.Alert.incidentKey(), "attempts", j.Attempts, "error", err)
+	default:
+		result = "retried"
+		j.Attempts, j.LastError = j.Attempts+1, err.Error()
+		j.NotBefore = q.now().Add(min(q.Backoff<<(j.Attempts-1), maxJobBackoff))
+		q.pending[i] = j
+		logger.Warnw("queued job failed; will retry",
+			"id", j.ID, "kind", j.Kind, "incident_key", j.Alert.incidentKey(), "attempt", j.Attempts,
+			"retryAt", j.NotBefore, "error", err)
+	}
+	dropped := 0
+	for ; len(q.dead) > 0 && q.oversize(); dropped++ {
+		q.dead = q.dead[1:]
+	}
+	q.mu.Unlock()
+
+	if dropped > 0 {
+		logger.Warnw("work queue is at its size limit; dropped the oldest dead letters", "dropped", dropped, "maxBytes", q.MaxBytes)
+	}
+	queueJobs.WithLabelValues(j.Kind, result).Inc()
+	if err := q.save(ctx); err != nil {
+		logger.Errorw("could not persist the work queue; a restart may redo or lose this job", "id", j.ID, "error", err)
+	}
+}
+
+// Snapshot copies the queue, for GET /admin/queue.
+func (q *Queue) Snapshot() QueueState {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return QueueState{Pending: q.pending, Dead: q.dead}.clone()
+}
+
+// Stats summarizes the queue for the metrics.
+func (q *Queue) Stats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	s := QueueStats{Depth: len(q.pending), Dead: len(q.dead)}
+	if len(q.pending) > 0 {
+		oldest := slices.MinFunc(q.pending, func(a, b Job) int { return a.Enqueued.Compare(b.Enqueued) })
+		s.OldestAge = q.now().Sub(oldest.Enqueued)
+	}
+	if q.Workers > 0 {
+		s.Utilization = float64(len(q.running)) / float64(q.Workers)
+	}
+	return s
+}
+
+// save persists the queue. It must be called without q.mu: the snapshot is taken once
+// saveMu is held, so whichever save runs last writes the latest queue.
+func (q *Queue) save(ctx context.Context) error {
+	if q.store == nil {
+		return nil
+	}
+	q.saveMu.Lock()
+	defer q.saveMu.Unlock()
+	q.mu.Lock()
+	st := QueueState{Pending: q.pending, Dead: q.dead}.clone()
+	q.mu.Unlock()
+	if err := q.store.SaveQueue(ctx, st); err != nil {
+		return fmt.Errorf("save queue: %w", err)
+	}
+	return nil
+}
+
+// oversize reports whether the queue's JSON has outgrown MaxBytes; q.mu must be held.
+func (q *Queue) oversize() bool {
+	if q.MaxBytes <= 0 {
+		return false
+	}
+	raw, _ := json.Marshal(QueueState{Pending: q.pending, Dead: q.dead})
+	return len(raw) > q.MaxBytes
+}
+
+// signal wakes one idle worker, if any.
+func (q *Queue) signal() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// traceOf is the W3C trace context of span, so a queued job's work joins its trace.
+func traceOf(span trace.Span) map[string]string {
+	carrier := propagation.MapCarrier{}
+	otel.GetTextMapPropagator().Inject(trace.ContextWithSpan(context.Background(), span), carrier)
+	return carrier
+}
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fakeClock is a settable now for the queue's backoff.
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+// testQueue is a queue over a memory store whose handler is handle, on a fake clock.
+func testQueue(handle func(context.Context, Job) error) (*Queue, *MemoryStore, *fakeClock) {
+	store := &MemoryStore{}
+	clock := &fakeClock{t: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	q := NewQueue(store, handle)
+	q.now = clock.now
+	return q, store, clock
+}
+
+func TestQueue_WebhookAcknowledgesBeforeActing(t *testing.T) {
+	// Dry-run, so acting leaves an audit record and no background verification behind.
+	registry = NewRegistry(true, time.Minute)
+	registry.Register("stub", &stubAction{})
+	auditLog = testAuditLog(t)
+	q, store, _ := testQueue(processJob)
+	workQueue = q
+	t.Cleanup(func() { registry, workQueue, auditLog = nil, nil, nil })
+
+	payload := `{"status":"firing","alerts":[
+		{"status":"firing","labels":{"alertname":"HighLatency","service":"cart"},"annotations":{"remediation_action":"stub"}},
+		{"status":"firing","labels":{"alertname":"Watchdog"}}]}`
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(payload)))
+
+	var body map[string]int
+	_ = json.Unmarshal(w.Body.Bytes(), &body)
+	if w.Code != http.StatusOK || body["received"] != 2 || body["queued"] != 1 {
+		t.Fatalf("webhook = %d %v, want 2 received and only the opted-in alert queued", w.Code, body)
+	}
+	if got, _ := auditLog.Query(AuditFilter{}); len(got) != 0 {
+		t.Errorf("audit = %+v; the webhook acted inline, want it only queued", got)
+	}
+	if st, _ := store.LoadQueue(context.Background()); len(st.Pending) != 1 || st.Pending[0].Alert.incidentKey() != "HighLatency|cart" {
+		t.Fatalf("persisted queue = %+v, want the alert saved before the acknowledgement", st)
+	}
+
+	if n := q.drain(context.Background()); n != 1 {
+		t.Errorf("drain handled %d jobs, want 1", n)
+	}
+	if got, _ := auditLog.Query(AuditFilter{}); len(got) != 1 || got[0].Outcome != "dry_run" {
+		t.Errorf("audit = %+v, want the queued alert decided once", got)
+	}
+	if st, _ := store.LoadQueue(context.Background()); len(st.Pending) != 0 {
+		t.Errorf("persisted queue = %+v, want the handled job gone", st)
+	}
+}
+
+func TestQueue_WebhookFullQueueAsksForRetry(t *testing.T) {
+	registry = NewRegistry(false, time.Minute)
+	registry.Register("stub", &stubAction{})
+	q, _, _ := testQueue(processJob)
+	q.MaxPending = 0
+	workQueue = q
+	t.Cleanup(func() { registry, workQueue = nil, nil })
+
+	payload, _ := json.Marshal(AlertmanagerWebhook{Status: "firing", Alerts: []Alert{stubAlert()}})
+	w := httptest.NewRecorder()
+	newRouter().ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(string(payload))))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503 so Alertmanager sends it again", w.Code)
+	}
+}
+
+func TestQueue_RetriesWithBackoffThenDeadLetters(t *testing.T) {
+	calls := 0
+	q, store, clock := testQueue(func(context.Context, Job) error {
+		calls++
+		return errors.New("api server unavailable")
+	})
+	q.MaxAttempts, q.Backoff = 3, 10*time.Second
+	ctx := context.Background()
+	if err := q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()}); err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+
+	q.drain(ctx)
+	clock.t = clock.t.Add(9 * time.Second)
+	if q.drain(ctx) != 0 {
+		t.Error("retried before the backoff was up")
+	}
+	clock.t = clock.t.Add(time.Second)
+	q.drain(ctx) // second attempt; the next waits 20s
+	clock.t = clock.t.Add(20 * time.Second)
+	q.drain(ctx)
+
+	st := q.Snapshot()
+	if calls != 3 || len(st.Pending) != 0 || len(st.Dead) != 1 {
+		t.Fatalf("after %d attempts queue = %+v, want one dead letter", calls, st)
+	}
+	if dead := st.Dead[0]; dead.Attempts != 3 || dead.LastError != "api server unavailable" {
+		t.Errorf("dead letter = %+v, want its attempts and last error", dead)
+	}
+	if saved, _ := store.LoadQueue(ctx); len(saved.Dead) != 1 {
+		t.Errorf("persisted queue = %+v, want the dead letter saved", saved)
+	}
+
+	if ok, err := q.Requeue(ctx, st.Dead[0].ID); !ok || err != nil {
+		t.Fatalf("Requeue = (%v, %v), want requeued", ok, err)
+	}
+	if st := q.Snapshot(); len(st.Pending) != 1 || st.Pending[0].Attempts != 0 || len(st.Dead) != 0 {
+		t.Errorf("after requeue queue = %+v, want the job back with fresh attempts", st)
+	}
+}
+
+func TestQueue_StaysUnderMaxBytes(t *testing.T) {
+	q, store, _ := testQueue(func(context.Context, Job) error { return errors.New("api server unavailable") })
+	q.MaxAttempts, q.MaxBytes = 1, 2048
+	ctx := context.Background()
+
+	enqueued := 0
+	for i := range 50 {
+		a := stubAlert()
+		a.Labels["service"] = fmt.Sprintf("svc-%d", i)
+		err := q.Enqueue(ctx, Job{Kind: jobAlert, Alert: a})
+		if errors.Is(err, errQueueFull) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Enqueue: %v", err)
+		}
+		enqueued++
+	}
+	if enqueued == 0 || enqueued == 50 {
+		t.Fatalf("enqueued %d of 50 jobs, want Enqueue to refuse some way in", enqueued)
+	}
+	q.drain(ctx) // every job fails for good and becomes a dead letter
+	saved, _ := store.LoadQueue(ctx)
+	raw, _ := json.Marshal(saved)
+	if len(raw) > q.MaxBytes || len(saved.Pending) != 0 || len(saved.Dead) == 0 || len(saved.Dead) == enqueued {
+		t.Errorf("saved queue is %d bytes with %d dead letters of %d, want the oldest dropped to stay under %d",
+			len(raw), len(saved.Dead), enqueued, q.MaxBytes)
+	}
+}
+
+// slowStore is a queue store whose saves wait until release is closed.
+type slowStore struct {
+	MemoryStore
+	saving, release chan struct{}
+}
+
+func (s *slowStore) SaveQueue(ctx context.Context, q QueueState) error {
+	s.saving <- struct{}{}
+	<-s.release
+	return s.MemoryStore.SaveQueue(ctx, q)
+}
+
+func TestQueue_SavesOutsideTheLock(t *testing.T) {
+	store := &slowStore{saving: make(chan struct{}), release: make(chan struct{})}
+	q := NewQueue(store, func(context.Context, Job) error { return nil })
+	done := make(chan error)
+	go func() { done <- q.Enqueue(context.Background(), Job{Kind: jobAlert, Alert: stubAlert()}) }()
+	<-store.saving
+
+	stats := make(chan QueueStats)
+	go func() { stats <- q.Stats() }()
+	select {
+	case st := <-stats:
+		if st.Depth != 1 {
+			t.Errorf("depth during the save = %d, want 1", st.Depth)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the queue stayed locked while its save was in flight")
+	}
+	close(store.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Enqueue: %v", err)
+	}
+}
+
+func TestQueue_PanicIsRetried(t *testing.T) {
+	q, _, _ := testQueue(func(context.Context, Job) error { panic("boom") })
+	ctx := context.Background()
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()})
+	q.drain(ctx)
+	if st := q.Snapshot(); len(st.Pending) != 1 || st.Pending[0].LastError != "panic: boom" {
+		t.Errorf("queue = %+v, want the job kept for a retry", st)
+	}
+}
+
+func TestQueue_SurvivesRestart(t *testing.T) {
+	q, store, _ := testQueue(func(context.Context, Job) error { return nil })
+	ctx := context.Background()
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()}) // then the process dies
+
+	var handled []string
+	restarted := NewQueue(store, func(_ context.Context, j Job) error {
+		handled = append(handled, j.Alert.incidentKey())
+		return nil
+	})
+	if err := restarted.Load(ctx); err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	restarted.drain(ctx)
+	if len(handled) != 1 || handled[0] != "HighLatency|cart" {
+		t.Errorf("after restart handled %v, want the queued alert", handled)
+	}
+}
+
+func TestQueue_DeduplicatesAndKeepsIncidentOrder(t *testing.T) {
+	var handled []string
+	fail := true
+	q, _, clock := testQueue(func(_ context.Context, j Job) error {
+		if j.Alert.Status == "firing" && fail {
+			fail = false
+			return errors.New("transient")
+		}
+		handled = append(handled, j.Alert.Status)
+		return nil
+	})
+	ctx := context.Background()
+	resolved := stubAlert()
+	resolved.Status = "resolved"
+
+	// An Alertmanager retry of a waiting alert updates it rather than queueing it twice.
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()})
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()})
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: resolved})
+	if st := q.Stats(); st.Depth != 2 {
+		t.Fatalf("depth = %d, want the firing alert once and the resolve", st.Depth)
+	}
+
+	// The firing alert fails and waits for a retry: the resolve behind it must wait too.
+	q.drain(ctx)
+	if len(handled) != 0 {
+		t.Fatalf("handled %v while the firing alert waits for a retry, want nothing", handled)
+	}
+	clock.t = clock.t.Add(q.Backoff)
+	q.drain(ctx)
+	if strings.Join(handled, ",") != "firing,resolved" {
+		t.Errorf("handled %v, want firing then resolved", handled)
+	}
+}
+
+func TestQueue_Stats(t *testing.T) {
+	block := make(chan struct{})
+	started := make(chan struct{})
+	q, _, clock := testQueue(func(context.Context, Job) error {
+		close(started)
+		<-block
+		return nil
+	})
+	q.Workers = 2
+	ctx := context.Background()
+	_ = q.Enqueue(ctx, Job{Kind: jobAlert, Alert: stubAlert()})
+	clock.t = clock.t.Add(30 * time.Second)
+
+	go q.drain(ctx)
+	<-started
+	if st := q.Stats(); st.Depth != 1 || st.OldestAge != 30*time.Second || st.Utilization != 0.5 {
+		t.Errorf("stats = %+v, want depth 1, 30s old, one of two workers busy", st)
+	}
+	close(block)
+}
+
+func TestQueueHandler(t *testing.T) {
+	t.Setenv("REMEDIATOR_ADMIN_TOKEN", "s3cret")
+	q, _, _ := testQueue(func(context.Context, Job) error { return errors.New("nope") })
+	q.MaxAttempts = 1
+	workQueue = q
+	t.Cleanup(func() { workQueue = nil })
+	_ = q.Enqueue(context.Background(), Job{Kind: jobAlert, Alert: stubAlert()})
+	q.drain(context.Background())
+
+	router := gin.New()
+	admin := router.Group("/admin", adminAuth())
+	admin.GET("/queue", queueHandler)
+	admin.POST("/queue/dead/:id/retry", requeueHandler)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/queue", nil)
+	req.Header.Set("Authorization", "Bearer s3cret")
+	router.ServeHTTP(w, req)
+	var st QueueState
+	if err := json.Unmarshal(w.Body.Bytes(), &st); err != nil || len(st.Dead) != 1 {
+		t.Fatalf("GET /admin/queue = %d %s, want the dead letter", w.Code, w.Body.String())
+	}
+
+	if w := postAction(router, "/admin/queue/dead/nope/retry", "s3cret"); w.Code != http.StatusNotFound {
+		t.Errorf("retry of an unknown id status = %d, want 404", w.Code)
+	}
+	if w := postAction(router, "/admin/queue/dead/"+st.Dead[0].ID+"/retry", "s3cret"); w.Code != http.StatusOK {
+		t.Errorf("retry status = %d (%s), want 200", w.Code, w.Body.String())
+	}
+	if st := q.Stats(); st.Depth != 1 || st.Dead != 0 {
+		t.Errorf("stats after retry = %+v, want the job pending again", st)
+	}
+}
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// actionFlagdRamp is the registry name of the gradual flagd ramp-down.
+const actionFlagdRamp = "flagd_ramp"
+
+// FlagRamp is the gentle form of the flagd kill switch, for partially-bad features behind
+// flagd fractional targeting: instead of forcing the whole flag to a safe variant, it
+// lowers the bad variant's share of traffic a step at a time (say 50% -> 10% -> 0%),
+// shifting the rest to the flag's other variants in proportion. Execute takes the first
+// step; rampDown takes the rest, checking the SLO between steps and stopping at the first
+// one where the service recovers. The alert names the bad variant in
+// remediation_ramp_variant and may override the steps with remediation_ramp_steps.
+type FlagRamp struct {
+	flags *FlagRemediator // the flagd ConfigMap it edits, and how
+	// Steps are the bad variant's percentages to step down through, highest first. Steps at
+	// or above its current share are skipped.
+	Steps []int
+	// AutoRestore puts the original targeting back once the alert has resolved and soaked,
+	// as FlagRemediator.AutoRestore does for the kill switch.
+	AutoRestore bool
+
+	mu      sync.Mutex
+	ramping map[string]bool // plan target -> a rampDown is stepping it
+}
+
+// NewFlagRamp builds the ramp-down over the kill switch's flagd ConfigMap.
+func NewFlagRamp(flags *FlagRemediator, steps []int) *FlagRamp {
+	return &FlagRamp{flags: flags, Steps: steps, ramping: map[string]bool{}}
+}
+
+// parseRampSteps parses "50,10,0": percentages from 0 to 100, strictly decreasing.
+func parseRampSteps(s string) ([]int, error) {
+	var steps []int
+	for _, f := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("ramp step %q: want a percentage 0-100", f)
+		}
+		if len(steps) > 0 && n >= steps[len(steps)-1] {
+			return nil, fmt.Errorf("ramp steps %q: want strictly decreasing percentages", s)
+		}
+		steps = append(steps, n)
+	}
+	return steps, nil
+}
+
+// Plan finds the bad variant's bucket in the flag's fractional targeting and plans the
+// first step below its current share, recording the targeting it replaces for Undo. A
+// missing flag or variant, a variant already at or below the last step, or a flag already
+// being ramped is a no-op.
+func (r *FlagRamp) Plan(ctx context.Context, alert Alert) (Plan, error) {
+	flag := alert.remediationFlag()
+	variant := alert.Annotations["remediation_ramp_variant"]
+	t := r.flags.targetFor(alert)
+	p := planFor(t, flag)
+	if flag == "" || variant == "" {
+		return p, fmt.Errorf("alert needs remediation_flag and remediation_ramp_variant annotations")
+	}
+	steps := r.Steps
+	if s := alert.Annotations["remediation_ramp_steps"]; s != "" {
+		var err error
+		if steps, err = parseRampSteps(s); err != nil {
+			return p, err
+		}
+	}
+
+	r.mu.Lock()
+	ramping := r.ramping[p.Target]
+	r.mu.Unlock()
+	if ramping {
+		p.Noop = OutcomeRampInProgress
+		return p, nil
+	}
+
+	_, doc, err := r.flags.load(ctx, t)
+	if err != nil {
+		return p, err
+	}
+	entry, ok := flagEntry(doc, flag)
+	if !ok {
+		p.Noop = OutcomeFlagMissing
+		return p, nil
+	}
+	buckets, ok := fractional(entry["targeting"])
+	if !ok {
+		return p, fmt.Errorf("flag %s has no fractional targeting to ramp down; use the %s action", flag, actionFlagd)
+	}
+	current, ok := share(buckets, variant)
+	if !ok {
+		p.Noop = OutcomeVariantMissing
+		return p, nil
+	}
+	remaining := slices.DeleteFunc(slices.Clone(steps), func(s int) bool { return s >= current })
+	if len(remaining) == 0 {
+		p.Noop = OutcomeAlreadyOff // already ramped down as far as the steps go
+		return p, nil
+	}
+	targeting, _ := json.Marshal(entry["targeting"])
+	p.Params["variant"], p.Params["previousTargeting"] = variant, string(targeting)
+	return rampStep(p, current, remaining), nil
+}
+
+// rampStep is p moved on to the first of steps, from percent from.
+func rampStep(p Plan, from int, steps []int) Plan {
+	p.Params = withParam(p.Params, "fromPercent", strconv.Itoa(from))
+	p.Params["percent"] = strconv.Itoa(steps[0])
+	p.Params["steps"] = joinInts(steps[1:])
+	p.Description = fmt.Sprintf("ramped flagd flag %s variant %s down from %d%% to %d%%",
+		flagOf(p), p.Params["variant"], from, steps[0])
+	return p
+}
+
+// Execute sets the bad variant's share to the planned step.
+func (r *FlagRamp) Execute(ctx context.Context, p Plan) (Outcome, error) {
+	percent, _ := strconv.Atoi(p.Params["percent"])
+	err := r.flags.update(ctx, r.flags.targetOf(p), flagOf(p), func(entry map[string]any) error {
+		buckets, ok := fractional(entry["targeting"])
+		if !ok {
+			return fmt.Errorf("no fractional targeting")
+		}
+		return setShare(buckets, p.Params["variant"], percent)
+	})
+	if err != nil {
+		return "", err
+	}
+	return OutcomeRampedDown, nil
+}
+
+// Next plans the step after p, or reports false when p was the last one. The registry
+// executes it (Registry.Step), behind the same dry-run and budget rails as the first.
+func (r *FlagRamp) Next(p Plan) (Plan, bool, error) {
+	steps, err := parseInts(p.Params["steps"])
+	if err != nil || len(steps) == 0 {
+		return p, false, err
+	}
+	from, _ := strconv.Atoi(p.Params["percent"])
+	return rampStep(p, from, steps), true, nil
+}
+
+// Verify reports whether the bad variant's share is at or below the planned step.
+func (r *FlagRamp) Verify(ctx context.Context, p Plan) (bool, error) {
+	current, ok, err := r.current(ctx, p)
+	if err != nil {
+		return false, err
+	}
+	percent, _ := strconv.Atoi(p.Params["percent"])
+	return ok && current <= percent, nil
+}
+
+// Undo puts back the targeting the flag had before the ramp started. If the bad variant's
+// share has gone back up since, someone has changed it, and their change wins.
+func (r *FlagRamp) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	var targeting any
+	if err := json.Unmarshal([]byte(p.Params["previousTargeting"]), &targeting); err != nil {
+		return "", fmt.Errorf("plan for %s: parse previous targeting: %w", p.Target, err)
+	}
+	if ok, err := r.Verify(ctx, p); err != nil || !ok {
+		return OutcomeRestoreSkipped, err
+	}
+	err := r.flags.update(ctx, r.flags.targetOf(p), flagOf(p), func(entry map[string]any) error {
+		entry["targeting"] = targeting
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return OutcomeRestored, nil
+}
+
+// Drifted reports reverted_by_gitops (or reverted) once the bad variant's share has gone
+// back above the step the ramp took.
+func (r *FlagRamp) Drifted(ctx context.Context, p Plan) (Outcome, error) {
+	if ok, err := r.Verify(ctx, p); err != nil || ok {
+		return "", err
+	}
+	t := r.flags.targetOf(p)
+	cm, _, err := r.flags.load(ctx, t)
+	if err != nil {
+		return "", err
+	}
+	return revertedBy(cm, t.ConfigKey), nil
+}
+
+// Change reports the bad variant's share before and after the step.
+func (r *FlagRamp) Change(p Plan) (string, string) {
+	v := p.Params["variant"]
+	return v + "=" + p.Params["fromPercent"] + "%", v + "=" + p.Params["percent"] + "%"
+}
+
+// Objects names the flagd ConfigMap.
+func (r *FlagRamp) Objects(p Plan) []corev1.ObjectReference { return r.flags.Objects(p) }
+
+// Permissions are the kill switch's, plus each flagd ConfigMap even with the git backend:
+// the ramp always edits the ConfigMap.
+func (r *FlagRamp) Permissions() []authorizationv1.ResourceAttributes {
+	out := r.flags.Permissions()
+	if r.flags.Git != nil {
+		out = append(out, r.flags.def.permissions()...)
+	}
+	return out
+}
+
+// UndoOnResolve opts the ramp into automatic restore when AutoRestore is set.
+func (r *FlagRamp) UndoOnResolve() bool { return r.AutoRestore }
+
+// current reads the bad variant's share back from the cluster.
+func (r *FlagRamp) current(ctx context.Context, p Plan) (int, bool, error) {
+	_, doc, err := r.flags.load(ctx, r.flags.targetOf(p))
+	if err != nil {
+		return 0, false, err
+	}
+	entry, ok := flagEntry(doc, flagOf(p))
+	if !ok {
+		return 0, false, nil
+	}
+	buckets, ok := fractional(entry["targeting"])
+	if !ok {
+		return 0, false, nil
+	}
+	current, ok := share(buckets, p.Params["variant"])
+	return current, ok, nil
+}
+
+// claim marks a plan target (a flag, on its flagd instance) as being stepped by a
+// rampDown, and reports false if one already is.
+func (r *FlagRamp) claim(target string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.ramping[target] {
+		return false
+	}
+	r.ramping[target] = true
+	return true
+}
+
+func (r *FlagRamp) release(target string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.ramping, target)
+}
+
+// rampDown takes the rest of a ramp's steps after Execute took the first: it waits a
+// verification interval, checks the service's error ratio, and stops as soon as it is back
+// under the threshold (verified); otherwise it takes the next step through the registry,
+// recorded as its own decision. After the last step it hands over to verifyAction, which
+// escalates if even that didn't help. Without Prometheus there is nothing to judge a step
+// by, so the steps run back to back. A replica that loses leadership stops, and so does a
+// step the registry refuses (paused, dry_run, budget_exhausted); the next leader (or a
+// resume) carries on from the flag's current share on the alert's next repeat.
+func rampDown(alert Alert, res Result) {
+	ramp, ok := registryAction(res.Plan.Action).(*FlagRamp)
+	if !ok || !ramp.claim(res.Plan.Target) {
+		return
+	}
+	defer ramp.release(res.Plan.Target)
+	ctx, span := otel.Tracer("remediator").Start(context.Background(), "ramp-down")
+	defer span.End()
+
+	service, key := alert.Labels["service"], alert.incidentKey()
+	p := res.Plan
+	for {
+		if verifier != nil {
+			time.Sleep(verifier.Interval)
+			ratio, ok := verifier.prom.ErrorRatio(ctx, service)
+			if ok && ratio <= alert.verifyThreshold(verifier.Threshold) {
+				recordAction(span, p.Action, key, Result{Plan: p, Outcome: OutcomeVerified}, nil)
+				return
+			}
+		}
+		if !acting() {
+			return
+		}
+		next, more, err := ramp.Next(p)
+		if err != nil {
+			recordAction(span, p.Action, key, Result{Plan: p}, fmt.Errorf("ramp step: %w", err))
+			return
+		}
+		if !more {
+			break
+		}
+		step, err := registry.Step(ctx, next, res.Rule)
+		if err != nil {
+			recordAction(span, p.Action, key, step, fmt.Errorf("ramp step: %w", err))
+			return
+		}
+		recordAction(span, p.Action, key, step, nil)
+		if !step.Executed {
+			return
+		}
+		p = next
+	}
+	verifyAction(alert, Result{Plan: p, Executed: true})
+}
+
+// fractional finds the bucket list of the first fractional operation in a flag's
+// targeting rules, which may nest it inside if/else branches.
+func fractional(targeting any) ([]any, bool) {
+	switch t := targeting.(type) {
+	case map[string]any:
+		if args, ok := t["fractional"].([]any); ok {
+			return args, true
+		}
+		for _, v := range t {
+			if buckets, ok := fractional(v); ok {
+				return buckets, true
+			}
+		}
+	case []any:
+		for _, v := range t {
+			if buckets, ok := fractional(v); ok {
+				return buckets, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// weights returns each [variant, weight] bucket's weight, in order; a fractional's
+// optional leading bucketing expression isn't a bucket and is skipped.
+func weights(args []any) (names []string, ws []float64) {
+	for _, a := range args {
+		b, ok := a.([]any)
+		if !ok || len(b) != 2 {
+			continue
+		}
+		name, ok1 := b[0].(string)
+		w, ok2 := b[1].(float64)
+		if ok1 && ok2 {
+			names, ws = append(names, name), append(ws, w)
+		}
+	}
+	return names, ws
+}
+
+// share is variant's percentage of the fractional's total weight, rounded.
+func share(args []any, variant string) (int, bool) {
+	names, ws := weights(args)
+	i := slices.Index(names, variant)
+	if i < 0 {
+		return 0, false
+	}
+	var total float64
+	for _, w := range ws {
+		total += w
+	}
+	if total == 0 {
+		return 0, true
+	}
+	return int(math.Round(ws[i] * 100 / total)), true
+}
+
+// setShare rewrites the bucket weights in place so variant gets percent of 100 and the
+// other variants split the rest in proportion to their current weights (evenly, if they
+// all have none). The last of them takes the rounding remainder.
+func setShare(args []any, variant string, percent int) error {
+	names, ws := weights(args)
+	if !slices.Contains(names, variant) {
+		return fmt.Errorf("no fractional bucket for variant %q", variant)
+	}
+	if len(names) < 2 {
+		return fmt.Errorf("variant %q is the only bucket; nothing to shift its traffic to", variant)
+	}
+	var others float64
+	for i, w := range ws {
+		if names[i] != variant {
+			others += w
+		}
+	}
+	rest, assigned, last := 100-percent, 0, ""
+	newWeights := map[string]int{variant: percent}
+	for i, w := range ws {
+		if names[i] == variant {
+			continue
+		}
+		n := rest / (len(names) - 1)
+		if others > 0 {
+			n = int(math.Floor(w * float64(rest) / others))
+		}
+		newWeights[names[i]], assigned, last = n, assigned+n, names[i]
+	}
+	newWeights[last] += rest - assigned
+	for _, a := range args {
+		if b, ok := a.([]any); ok && len(b) == 2 {
+			if name, ok := b[0].(string); ok {
+				if n, ok := newWeights[name]; ok {
+					b[1] = float64(n)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func joinInts(ns []int) string {
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = strconv.Itoa(n)
+	}
+	return strings.Join(s, ",")
+}
+
+func parseInts(s string) ([]int, error) {
+	if s == "" {
+		return nil, nil
+	}
+	return parseRampSteps(s)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseRampSteps(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    []int
+		wantErr bool
+	}{
+		{"50,10,0", []int{50, 10, 0}, false},
+		{" 25 , 0", []int{25, 0}, false},
+		{"10,50", nil, true},
+		{"10,10", nil, true},
+		{"120", nil, true},
+		{"half", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := parseRampSteps(tt.in)
+		if (err != nil) != tt.wantErr || !slices.Equal(got, tt.want) {
+			t.Errorf("parseRampSteps(%q) = (%v, %v), want %v (error %v)", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestSetShare(t *testing.T) {
+	// A leading bucketing expression isn't a bucket; the other variants keep their ratio.
+	args := []any{
+		map[string]any{"var": "targetingKey"},
+		[]any{"bad", 40.0}, []any{"a", 40.0}, []any{"b", 20.0},
+	}
+	if err := setShare(args, "bad", 10); err != nil {
+		t.Fatalf("setShare: %v", err)
+	}
+	names, ws := weights(args)
+	if !slices.Equal(names, []string{"bad", "a", "b"}) || !slices.Equal(ws, []float64{10, 60, 30}) {
+		t.Errorf("buckets = %v %v, want bad 10, a 60, b 30", names, ws)
+	}
+	if got, _ := share(args, "bad"); got != 10 {
+		t.Errorf("share = %d, want 10", got)
+	}
+	if err := setShare([]any{[]any{"bad", 100.0}}, "bad", 0); err == nil {
+		t.Error("setShare with no other bucket succeeded, want an error")
+	}
+}
+
+// rampRemediator holds a flag splitting traffic 50/50 between a bad and a good variant,
+// behind the registry and global audit log rampDown records through.
+func rampRemediator(t *testing.T) (*Registry, *FlagRamp, *fake.Clientset) {
+	t.Helper()
+	doc := `{"flags": {"recommendationCache": {
+		"state": "ENABLED",
+		"variants": {"on": true, "off": false},
+		"defaultVariant": "off",
+		"targeting": {"if": [{"in": ["beta", {"var": "tier"}]}, "off",
+			{"fractional": [["on", 50], ["off", 50]]}]}}}}`
+	cs := fake.NewClientset(&corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "flagd-config", Namespace: "otel-demo"},
+		Data:       map[string]string{"demo.flagd.json": doc},
+	})
+	ramp := NewFlagRamp(NewFlagRemediator(cs, "otel-demo", "flagd-config", "demo.flagd.json"), []int{50, 10, 0})
+	registry = NewRegistry(false, time.Minute)
+	registry.Register(actionFlagdRamp, ramp)
+	auditLog = testAuditLog(t)
+	t.Cleanup(func() { registry, auditLog, verifier = nil, nil, nil })
+	return registry, ramp, cs
+}
+
+func rampAlert() Alert {
+	return Alert{
+		Status: "firing",
+		Labels: map[string]string{"alertname": "RecommendationHighErrorRate", "service": "recommendation"},
+		Annotations: map[string]string{
+			"remediation_action":       actionFlagdRamp,
+			"remediation_flag":         "recommendationCache",
+			"remediation_ramp_variant": "on",
+		},
+	}
+}
+
+func TestRampDown_StopsAtFirstRecoveredStep(t *testing.T) {
+	r, ramp, _ := rampRemediator(t)
+	// Still burning after the first step (50% -> 10%), recovered after the second (-> 0%).
+	verifier = fastVerifier(fakeErrorRatio(t, "0.4", "0.01"))
+
+	res, err := r.Run(context.Background(), rampAlert())
+	if err != nil || res.Outcome != OutcomeRampedDown || res.Plan.Params["percent"] != "10" {
+		t.Fatalf("Run = (%+v, %v), want the first step to 10%%", res, err)
+	}
+	rampDown(rampAlert(), res)
+
+	got, _ := auditLog.Query(AuditFilter{})
+	var trail []string
+	for _, rec := range got {
+		trail = append(trail, rec.Outcome+" "+rec.After)
+	}
+	if !slices.Equal(trail, []string{"ramped_down on=0%", "verified on=0%"}) {
+		t.Errorf("audit trail = %v, want the second step, then verified", trail)
+	}
+	if current, _, _ := ramp.current(context.Background(), res.Plan); current != 0 {
+		t.Errorf("bad variant share = %d%%, want 0%%", current)
+	}
+}
+
+func TestRampDown_VerifiedAfterFirstStepTakesNoMore(t *testing.T) {
+	r, ramp, _ := rampRemediator(t)
+	verifier = fastVerifier(fakeErrorRatio(t, "0.01"))
+
+	res, err := r.Run(context.Background(), rampAlert())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	rampDown(rampAlert(), res)
+
+	if current, _, _ := ramp.current(context.Background(), res.Plan); current != 10 {
+		t.Errorf("bad variant share = %d%%, want it left at the first step's 10%%", current)
+	}
+	if got, _ := auditLog.Query(AuditFilter{Outcome: "ramped_down"}); len(got) != 0 {
+		t.Errorf("recorded %d further steps, want none", len(got))
+	}
+}
+
+func TestRampDown_StepsGoThroughTheRails(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		budget int  // actions allowed; 0 = no budget
+		dryRun bool // switched on after the first step
+		want   Outcome
+	}{
+		{"budget spent by the first step", 1, false, OutcomeBudgetExhausted},
+		{"dry-run switched on mid-ramp", 0, true, OutcomeDryRun},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ramp, _ := rampRemediator(t)
+			if tt.budget > 0 {
+				r.Budget, _, _ = testBudget(tt.budget, 0, 0)
+			}
+			verifier = fastVerifier(fakeErrorRatio(t, "0.4"))
+
+			res, err := r.Run(context.Background(), rampAlert())
+			if err != nil || res.Outcome != OutcomeRampedDown {
+				t.Fatalf("Run = (%+v, %v), want the first step", res, err)
+			}
+			r.SetDryRun(context.Background(), tt.dryRun)
+			rampDown(rampAlert(), res)
+
+			if current, _, _ := ramp.current(context.Background(), res.Plan); current != 10 {
+				t.Errorf("bad variant share = %d%%, want it left at the first step's 10%%", current)
+			}
+			if got, _ := auditLog.Query(AuditFilter{}); len(got) != 1 || got[0].Outcome != string(tt.want) {
+				t.Errorf("audit = %+v, want the ramp stopped with %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFlagRamp_UndoRestoresTargeting(t *testing.T) {
+	r, ramp, cs := rampRemediator(t)
+
+	res, err := r.Run(context.Background(), rampAlert())
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if out, err := ramp.Undo(context.Background(), res.Plan); err != nil || out != OutcomeRestored {
+		t.Fatalf("Undo = (%s, %v), want restored", out, err)
+	}
+	cm, _ := cs.CoreV1().ConfigMaps("otel-demo").Get(context.Background(), "flagd-config", metav1.GetOptions{})
+	if !strings.Contains(cm.Data["demo.flagd.json"], `"beta"`) {
+		t.Error("restored targeting lost the rule around the fractional")
+	}
+	if current, _, _ := ramp.current(context.Background(), res.Plan); current != 50 {
+		t.Errorf("bad variant share after undo = %d%%, want 50%%", current)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"net/http"
 	"os"
 	"regexp"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/tomjga/OmniObserve/remediator/internal/corpus"
 	"github.com/tomjga/OmniObserve/remediator/internal/evidence"
@@ -71,14 +73,31 @@ func initCopilot() (*rca.Copilot, *sink.Publisher) {
 
 var slugRe = regexp.MustCompile(`[^a-z0-9]+`)
 
-// draftRCA runs the copilot for one incident and publishes the result. It is meant to run
-// in its own goroutine with a fresh context — the LLM call can take tens of seconds and
-// must not block the webhook response or be cancelled when it returns.
-func draftRCA(alert Alert, action string) {
+// queueRCA has the copilot draft an RCA for an action that executed: as a work queue
+// job, retried if the LLM is down, or — with no queue, or a full one — in the background.
+// Either way the LLM call can take tens of seconds and must not hold up the caller.
+func queueRCA(span trace.Span, alert Alert, action string) {
 	if copilot == nil || !copilot.Enabled() {
 		return
 	}
-	ctx, cancel := context.WithTimeout(context.Background(), 90*time.Second)
+	if workQueue != nil {
+		err := workQueue.Enqueue(context.Background(), Job{Kind: jobRCA, Alert: alert, Action: action, Trace: traceOf(span)})
+		if err == nil {
+			return
+		}
+		logger.Warnw("could not queue the rca draft; drafting it unqueued", "incident_key", alert.incidentKey(), "error", err)
+	}
+	go func() { _ = draftRCA(context.Background(), alert, action) }()
+}
+
+// draftRCA runs the copilot for one incident and publishes the result. A failed draft is
+// returned, for the queue to retry; a sink that fails to publish is only counted, so a
+// retry never publishes twice to the sinks that worked.
+func draftRCA(ctx context.Context, alert Alert, action string) error {
+	if copilot == nil || !copilot.Enabled() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 90*time.Second)
 	defer cancel()
 
 	inc := rca.Incident{
@@ -90,18 +109,23 @@ func draftRCA(alert Alert, action string) {
 		StartsAt:    alert.StartsAt,
 	}
 
-	body, err := copilot.Draft(ctx, inc)
+	a, err := copilot.Draft(ctx, inc)
+	model := os.Getenv("LLM_MODEL")
+	record := incidentRCA(a, model)
 	if err != nil {
 		logger.Errorw("rca draft failed", "incident_key", inc.IncidentKey, "error", err)
 		rcaDraftsTotal.WithLabelValues("error").Inc()
-		return
+		record.Error = err.Error()
+		incidents.SetRCA(inc.IncidentKey, record)
+		return fmt.Errorf("draft rca: %w", err)
 	}
 	rcaDraftsTotal.WithLabelValues("drafted").Inc()
-	model := os.Getenv("LLM_MODEL")
-	logger.Infow("rca drafted", "incident_key", inc.IncidentKey, "chars", len(body), "model", model)
+	logger.Infow("rca drafted", "incident_key", inc.IncidentKey, "chars", len(a.Body), "model", model)
 
 	// Footer: who drafted this, so it's attributed wherever it lands (issue, annotation, corpus).
-	body += "\n\n---\n_Generated by the OmniObserve RCA copilot · model: `" + model + "`_\n"
+	body := a.Body + "\n\n---\n_Generated by the OmniObserve RCA copilot · model: `" + model + "`_\n"
+	record.Body = body
+	incidents.SetRCA(inc.IncidentKey, record)
 
 	r := sink.RCA{
 		Title:    "[RCA] " + inc.AlertName + " on " + inc.Service,
@@ -111,7 +135,9 @@ func draftRCA(alert Alert, action string) {
 		Model:    model,
 		StartsAt: inc.StartsAt,
 	}
-	for _, res := range publisher.Publish(ctx, r) {
+	results := publisher.Publish(ctx, r)
+	incidents.Published(inc.IncidentKey, "rca", results)
+	for _, res := range results {
 		if res.Error != nil {
 			logger.Errorw("rca publish failed", "sink", res.Sink, "error", res.Error)
 			rcaDraftsTotal.WithLabelValues("publish_error").Inc()
@@ -120,4 +146,18 @@ func draftRCA(alert Alert, action string) {
 			rcaDraftsTotal.WithLabelValues("published").Inc()
 		}
 	}
+	return nil
+}
+
+// incidentRCA is what an incident keeps of a copilot analysis: the evidence and the
+// precedent's identity, not its whole body.
+func incidentRCA(a rca.Analysis, model string) IncidentRCA {
+	r := IncidentRCA{At: time.Now(), Model: model, Evidence: []Evidence{}, Precedent: []Precedent{}}
+	for _, m := range a.Evidence {
+		r.Evidence = append(r.Evidence, Evidence{Name: m.Name, Value: m.Value})
+	}
+	for _, p := range a.Precedent {
+		r.Precedent = append(r.Precedent, Precedent{ID: p.ID, Title: p.Title, Tags: p.Tags})
+	}
+	return r
 }
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// actionRollback is the registry name of the Deployment rollback action. It is also the
+// default for alerts that carry only a remediation_rollback annotation.
+const actionRollback = "rollback"
+
+// revisionAnnotation is where the Deployment controller numbers each ReplicaSet revision.
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// RollbackRemediator rolls a plain Deployment (e.g. api-service without Argo Rollouts)
+// back to its previous ReplicaSet revision — what `kubectl rollout undo` does: the older
+// ReplicaSet's pod template is copied back into the Deployment, and the Deployment
+// controller rolls pods over to it. It only ever moves one revision back.
+type RollbackRemediator struct {
+	k8s       kubernetes.Interface
+	namespace string // used when the alert carries no namespace label
+	// AllowedNamespaces are the other namespaces an alert's namespace label may name; any
+	// other is refused (namespace_not_allowed).
+	AllowedNamespaces []string
+}
+
+// NewRollbackRemediator builds the rollback action.
+func NewRollbackRemediator(k8s kubernetes.Interface, namespace string) *RollbackRemediator {
+	return &RollbackRemediator{k8s: k8s, namespace: namespace}
+}
+
+// Plan finds the Deployment's current revision and the newest earlier revision with a
+// different pod template. It refuses (no_previous_revision) when there is nothing to go
+// back to, rather than "rolling back" onto the revision that is already failing.
+func (r *RollbackRemediator) Plan(ctx context.Context, alert Alert) (Plan, error) {
+	name := alert.Annotations["remediation_rollback"]
+	ns, allowed := namespaceFor(alert, r.namespace, r.AllowedNamespaces)
+	p := Plan{Target: ns + "/" + name, Params: map[string]string{"namespace": ns, "name": name}}
+	if name == "" {
+		return p, fmt.Errorf("alert has no remediation_rollback annotation")
+	}
+	if !allowed {
+		p.Noop = OutcomeNamespaceNotAllowed
+		return p, nil
+	}
+
+	d, err := r.k8s.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		p.Noop = OutcomeTargetMissing
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	revs, err := r.revisions(ctx, d)
+	if err != nil {
+		return p, err
+	}
+
+	current := slices.IndexFunc(revs, func(rs appsv1.ReplicaSet) bool {
+		return rs.Annotations[revisionAnnotation] == d.Annotations[revisionAnnotation]
+	})
+	if current < 0 {
+		return p, fmt.Errorf("deployment %s: no replicaset for current revision %q", p.Target, d.Annotations[revisionAnnotation])
+	}
+	prev := -1
+	for i := current - 1; i >= 0; i-- {
+		if !sameTemplate(revs[i], revs[current]) {
+			prev = i
+			break
+		}
+	}
+	if prev < 0 {
+		p.Noop = OutcomeNoPreviousRevision
+		return p, nil
+	}
+
+	from, to := revs[current], revs[prev]
+	p.Params["fromRevision"], p.Params["toRevision"] = from.Annotations[revisionAnnotation], to.Annotations[revisionAnnotation]
+	p.Params["fromReplicaSet"], p.Params["toReplicaSet"] = from.Name, to.Name
+	p.Description = fmt.Sprintf("rolled back Deployment %s from revision %s to revision %s",
+		p.Target, p.Params["fromRevision"], p.Params["toRevision"])
+	return p, nil
+}
+
+// Execute copies the previous revision's pod template into the Deployment.
+func (r *RollbackRemediator) Execute(ctx context.Context, p Plan) (Outcome, error) {
+	if err := r.applyTemplate(ctx, p, p.Params["toReplicaSet"]); err != nil {
+		return "", err
+	}
+	return OutcomeRolledBack, nil
+}
+
+// Verify checks the Deployment's template now runs the previous revision's images.
+func (r *RollbackRemediator) Verify(ctx context.Context, p Plan) (bool, error) {
+	d, err := r.k8s.AppsV1().Deployments(p.Params["namespace"]).Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	rs, err := r.k8s.AppsV1().ReplicaSets(p.Params["namespace"]).Get(ctx, p.Params["toReplicaSet"], metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get replicaset %s: %w", p.Params["toReplicaSet"], err)
+	}
+	return slices.Equal(templateImages(d.Spec.Template), templateImages(rs.Spec.Template)), nil
+}
+
+// Undo rolls forward again to the revision Execute moved away from.
+func (r *RollbackRemediator) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	if err := r.applyTemplate(ctx, p, p.Params["fromReplicaSet"]); err != nil {
+		return "", err
+	}
+	return OutcomeRestored, nil
+}
+
+// Change reports the Deployment's revision before and after Execute.
+func (r *RollbackRemediator) Change(p Plan) (string, string) {
+	return "revision=" + p.Params["fromRevision"], "revision=" + p.Params["toRevision"]
+}
+
+// Permissions are what rollback needs in the default and each allowed namespace: updating
+// Deployments from their ReplicaSets, and Events.
+func (r *RollbackRemediator) Permissions() []authorizationv1.ResourceAttributes {
+	var out []authorizationv1.ResourceAttributes
+	for _, ns := range namespacesOf(r.namespace, r.AllowedNamespaces) {
+		out = append(out, permissions(ns, "apps", "deployments", "", "get", "update")...)
+		out = append(out, permissions(ns, "apps", "replicasets", "", "get", "list")...)
+		out = append(out, permissions(ns, "", "events", "", "create")...)
+	}
+	return out
+}
+
+// Objects names the rolled-back Deployment.
+func (r *RollbackRemediator) Objects(p Plan) []corev1.ObjectReference {
+	if p.Params["name"] == "" {
+		return nil
+	}
+	return []corev1.ObjectReference{deploymentRef(p.Params["namespace"], p.Params["name"])}
+}
+
+// applyTemplate sets the Deployment's pod template to that of the named ReplicaSet.
+func (r *RollbackRemediator) applyTemplate(ctx context.Context, p Plan, replicaSet string) error {
+	ns := p.Params["namespace"]
+	rs, err := r.k8s.AppsV1().ReplicaSets(ns).Get(ctx, replicaSet, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get replicaset %s: %w", replicaSet, err)
+	}
+	d, err := r.k8s.AppsV1().Deployments(ns).Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+
+	tmpl := rs.Spec.Template.DeepCopy()
+	delete(tmpl.Labels, appsv1.DefaultDeploymentUniqueLabelKey) // the controller adds it back
+	d.Spec.Template = *tmpl
+	if _, err := r.k8s.AppsV1().Deployments(ns).Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update deployment %s: %w", p.Target, err)
+	}
+	return nil
+}
+
+// revisions lists the Deployment's ReplicaSets, oldest revision first.
+func (r *RollbackRemediator) revisions(ctx context.Context, d *appsv1.Deployment) ([]appsv1.ReplicaSet, error) {
+	sel, err := metav1.LabelSelectorAsSelector(d.Spec.Selector)
+	if err != nil {
+		return nil, fmt.Errorf("deployment %s/%s selector: %w", d.Namespace, d.Name, err)
+	}
+	list, err := r.k8s.AppsV1().ReplicaSets(d.Namespace).List(ctx, metav1.ListOptions{LabelSelector: sel.String()})
+	if err != nil {
+		return nil, fmt.Errorf("list replicasets for %s/%s: %w", d.Namespace, d.Name, err)
+	}
+	var out []appsv1.ReplicaSet
+	for _, rs := range list.Items {
+		if metav1.IsControlledBy(&rs, d) {
+			out = append(out, rs)
+		}
+	}
+	slices.SortFunc(out, func(a, b appsv1.ReplicaSet) int { return revision(a) - revision(b) })
+	return out, nil
+}
+
+func revision(rs appsv1.ReplicaSet) int {
+	n, _ := strconv.Atoi(rs.Annotations[revisionAnnotation])
+	return n
+}
+
+// sameTemplate compares two revisions' pod templates, ignoring the per-revision hash label.
+func sameTemplate(a, b appsv1.ReplicaSet) bool {
+	ta, tb := a.Spec.Template.DeepCopy(), b.Spec.Template.DeepCopy()
+	delete(ta.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	delete(tb.Labels, appsv1.DefaultDeploymentUniqueLabelKey)
+	return apiequality.Semantic.DeepEqual(ta, tb)
+}
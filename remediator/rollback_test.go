@@ -0,0 +1,184 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var apiServiceLabels = map[string]string{"app": "api-service"}
+
+// apiServiceDeployment is api-service at the given revision, running image api:<tag>.
+func apiServiceDeployment(revision, tag string) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "api-service", Namespace: "default", UID: types.UID("deploy-uid"),
+			Annotations: map[string]string{revisionAnnotation: revision},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: apiServiceLabels},
+			Template: podTemplate(tag, ""),
+		},
+	}
+}
+
+func podTemplate(tag, hash string) corev1.PodTemplateSpec {
+	labels := map[string]string{"app": "api-service"}
+	if hash != "" {
+		labels[appsv1.DefaultDeploymentUniqueLabelKey] = hash
+	}
+	return corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{Labels: labels},
+		Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: "api:" + tag}}},
+	}
+}
+
+// revisionReplicaSet is the ReplicaSet the Deployment controller keeps for one revision.
+func revisionReplicaSet(d *appsv1.Deployment, revision, tag string) *appsv1.ReplicaSet {
+	hash := "h" + revision
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "api-service-" + hash, Namespace: "default",
+			Labels:          map[string]string{"app": "api-service", appsv1.DefaultDeploymentUniqueLabelKey: hash},
+			Annotations:     map[string]string{revisionAnnotation: revision},
+			OwnerReferences: []metav1.OwnerReference{*metav1.NewControllerRef(d, appsv1.SchemeGroupVersion.WithKind("Deployment"))},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: podTemplate(tag, hash)},
+	}
+}
+
+func rollbackAlert() Alert {
+	return Alert{
+		Status:      "firing",
+		Labels:      map[string]string{"alertname": "ApiServiceHighErrorRate", "service": "api-service"},
+		Annotations: map[string]string{"remediation_rollback": "api-service"},
+	}
+}
+
+func newFakeRollback(t *testing.T, objs ...*appsv1.ReplicaSet) (*Registry, *fake.Clientset) {
+	t.Helper()
+	d := apiServiceDeployment("3", "v3")
+	cs := fake.NewClientset(d)
+	for _, rs := range objs {
+		if _, err := cs.AppsV1().ReplicaSets("default").Create(context.Background(), rs, metav1.CreateOptions{}); err != nil {
+			t.Fatalf("create replicaset: %v", err)
+		}
+	}
+	r := NewRegistry(false, time.Minute)
+	r.Register(actionRollback, NewRollbackRemediator(cs, "default"))
+	return r, cs
+}
+
+func deploymentImage(t *testing.T, cs *fake.Clientset) string {
+	t.Helper()
+	d, err := cs.AppsV1().Deployments("default").Get(context.Background(), "api-service", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+	return d.Spec.Template.Spec.Containers[0].Image
+}
+
+func TestRollback_ToPreviousRevision(t *testing.T) {
+	d := apiServiceDeployment("3", "v3")
+	r, cs := newFakeRollback(t,
+		revisionReplicaSet(d, "1", "v1"),
+		revisionReplicaSet(d, "2", "v2"),
+		revisionReplicaSet(d, "3", "v3"),
+	)
+
+	res, err := r.Run(context.Background(), rollbackAlert())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeRolledBack || !res.Executed {
+		t.Fatalf("outcome = %q (executed %v), want rolled_back", res.Outcome, res.Executed)
+	}
+	if from, to := res.Plan.Params["fromRevision"], res.Plan.Params["toRevision"]; from != "3" || to != "2" {
+		t.Errorf("revisions = %s -> %s, want 3 -> 2", from, to)
+	}
+	if !strings.Contains(res.Plan.Description, "from revision 3 to revision 2") {
+		t.Errorf("RCA action %q does not name the revisions", res.Plan.Description)
+	}
+	if img := deploymentImage(t, cs); img != "api:v2" {
+		t.Errorf("deployment image = %q, want api:v2", img)
+	}
+
+	if got, err := r.Undo(context.Background(), res.Plan); err != nil || got != OutcomeRestored {
+		t.Fatalf("undo = %q, %v; want restored", got, err)
+	}
+	if img := deploymentImage(t, cs); img != "api:v3" {
+		t.Errorf("deployment image after undo = %q, want api:v3", img)
+	}
+}
+
+func TestRollback_SkipsIdenticalRevision(t *testing.T) {
+	// Revision 2 re-deployed the same template as 3 (e.g. an annotation-only change), so
+	// the real "previous" is revision 1.
+	d := apiServiceDeployment("3", "v3")
+	r, cs := newFakeRollback(t,
+		revisionReplicaSet(d, "1", "v1"),
+		revisionReplicaSet(d, "2", "v3"),
+		revisionReplicaSet(d, "3", "v3"),
+	)
+	res, err := r.Run(context.Background(), rollbackAlert())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Plan.Params["toRevision"] != "1" {
+		t.Errorf("toRevision = %q, want 1", res.Plan.Params["toRevision"])
+	}
+	if img := deploymentImage(t, cs); img != "api:v1" {
+		t.Errorf("deployment image = %q, want api:v1", img)
+	}
+}
+
+func TestRollback_RefusesSingleRevision(t *testing.T) {
+	d := apiServiceDeployment("3", "v3")
+	r, cs := newFakeRollback(t, revisionReplicaSet(d, "3", "v3"))
+
+	res, err := r.Run(context.Background(), rollbackAlert())
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeNoPreviousRevision || res.Executed {
+		t.Errorf("outcome = %q (executed %v), want no_previous_revision", res.Outcome, res.Executed)
+	}
+	if img := deploymentImage(t, cs); img != "api:v3" {
+		t.Errorf("deployment image = %q, want it untouched", img)
+	}
+}
+
+func TestRollback_OnlyInAllowedNamespaces(t *testing.T) {
+	r, _ := newFakeRollback(t)
+	alert := rollbackAlert()
+	alert.Labels["namespace"] = "kube-system"
+	if res, err := r.Run(context.Background(), alert); err != nil || res.Outcome != OutcomeNamespaceNotAllowed {
+		t.Errorf("run in kube-system = (%q, %v), want namespace_not_allowed", res.Outcome, err)
+	}
+
+	r.actions[actionRollback].(*RollbackRemediator).AllowedNamespaces = []string{"payments"}
+	alert.Labels["namespace"] = "payments"
+	if res, err := r.Run(context.Background(), alert); err != nil || res.Outcome != OutcomeTargetMissing {
+		t.Errorf("run in allowed payments = (%q, %v), want it looked up there (target_missing)", res.Outcome, err)
+	}
+}
+
+func TestRollback_Missing(t *testing.T) {
+	r, _ := newFakeRollback(t)
+	alert := rollbackAlert()
+	alert.Annotations["remediation_rollback"] = "no-such-deployment"
+	res, err := r.Run(context.Background(), alert)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeTargetMissing {
+		t.Errorf("outcome = %q, want target_missing", res.Outcome)
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	appsv1 "k8s.io/api/apps/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+// actionRollout is the registry name of the Argo Rollouts action.
+const actionRollout = "rollout"
+
+// rolloutGVR is the Argo Rollouts CRD. We go through the dynamic client rather than the
+// Argo Rollouts Go module so the remediator doesn't take on its whole dependency tree.
+var rolloutGVR = schema.GroupVersionResource{Group: "argoproj.io", Version: "v1alpha1", Resource: "rollouts"}
+
+// podTemplateHashLabel is how Argo Rollouts ties a ReplicaSet to a revision; status.stableRS
+// and status.currentPodHash hold values of this label.
+const podTemplateHashLabel = "rollouts-pod-template-hash"
+
+// RolloutRemediator stops a bad canary on an Argo Rollout named by the alert's
+// remediation_rollout annotation. remediation_rollout_mode picks the move:
+//   - abort (default): set status.abort, the same as `kubectl argo rollouts abort` — traffic
+//     shifts back to the stable ReplicaSet and the canary scales down.
+//   - undo: copy the stable ReplicaSet's pod template back into spec.template, so the
+//     rollout's desired state is the stable revision again (`kubectl argo rollouts undo`).
+type RolloutRemediator struct {
+	dyn       dynamic.Interface
+	k8s       kubernetes.Interface
+	namespace string // used when the alert carries no namespace label
+	// AllowedNamespaces are the other namespaces an alert's namespace label may name; any
+	// other is refused (namespace_not_allowed).
+	AllowedNamespaces []string
+}
+
+// NewRolloutRemediator builds the rollout action.
+func NewRolloutRemediator(dyn dynamic.Interface, k8s kubernetes.Interface, namespace string) *RolloutRemediator {
+	return &RolloutRemediator{dyn: dyn, k8s: k8s, namespace: namespace}
+}
+
+// Plan reads the rollout's status and decides whether there is a canary to stop. An
+// already-aborted rollout (abort mode) or one already on its stable revision is a no-op.
+func (r *RolloutRemediator) Plan(ctx context.Context, alert Alert) (Plan, error) {
+	name := alert.Annotations["remediation_rollout"]
+	ns, allowed := namespaceFor(alert, r.namespace, r.AllowedNamespaces)
+	mode := alert.Annotations["remediation_rollout_mode"]
+	if mode == "" {
+		mode = "abort"
+	}
+	p := Plan{Target: ns + "/" + name, Params: map[string]string{"namespace": ns, "name": name, "mode": mode}}
+	if name == "" {
+		return p, fmt.Errorf("alert has no remediation_rollout annotation")
+	}
+	if !allowed {
+		p.Noop = OutcomeNamespaceNotAllowed
+		return p, nil
+	}
+	if mode != "abort" && mode != "undo" {
+		return p, fmt.Errorf("unknown remediation_rollout_mode %q (want abort or undo)", mode)
+	}
+
+	ro, err := r.dyn.Resource(rolloutGVR).Namespace(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		p.Noop = OutcomeTargetMissing
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("get rollout %s: %w", p.Target, err)
+	}
+
+	aborted, _, _ := unstructured.NestedBool(ro.Object, "status", "abort")
+	stable, _, _ := unstructured.NestedString(ro.Object, "status", "stableRS")
+	current, _, _ := unstructured.NestedString(ro.Object, "status", "currentPodHash")
+	p.Params["stableHash"], p.Params["currentHash"] = stable, current
+
+	switch {
+	case mode == "abort" && aborted:
+		p.Noop = OutcomeAlreadyAborted // idempotent: already stopped
+	case current == stable:
+		p.Noop = OutcomeAlreadyStable // no canary in flight
+	case mode == "abort":
+		p.Description = fmt.Sprintf("aborted Argo rollout %s (canary %s, stable %s)", p.Target, current, stable)
+	default:
+		p.Description = fmt.Sprintf("undid Argo rollout %s from revision %s to stable %s", p.Target, current, stable)
+	}
+	return p, nil
+}
+
+// Execute aborts the rollout or restores the stable pod template, per the planned mode.
+func (r *RolloutRemediator) Execute(ctx context.Context, p Plan) (Outcome, error) {
+	if p.Params["mode"] == "abort" {
+		if err := r.setAbort(ctx, p, true); err != nil {
+			return "", err
+		}
+		return OutcomeAborted, nil
+	}
+	if err := r.applyTemplate(ctx, p, p.Params["stableHash"]); err != nil {
+		return "", err
+	}
+	return OutcomeRolledBack, nil
+}
+
+// Verify re-reads the rollout: abort mode checks status.abort is set, undo mode checks
+// spec.template now runs the stable revision's images.
+func (r *RolloutRemediator) Verify(ctx context.Context, p Plan) (bool, error) {
+	ro, err := r.dyn.Resource(rolloutGVR).Namespace(p.Params["namespace"]).Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get rollout %s: %w", p.Target, err)
+	}
+	if p.Params["mode"] == "abort" {
+		aborted, _, _ := unstructured.NestedBool(ro.Object, "status", "abort")
+		return aborted, nil
+	}
+	rs, err := r.replicaSet(ctx, p, p.Params["stableHash"])
+	if err != nil {
+		return false, err
+	}
+	return slices.Equal(rolloutImages(ro), templateImages(rs.Spec.Template)), nil
+}
+
+// Undo reverses Execute: it clears the abort (`kubectl argo rollouts retry`) or puts the
+// canary's pod template back.
+func (r *RolloutRemediator) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	if p.Params["mode"] == "abort" {
+		if err := r.setAbort(ctx, p, false); err != nil {
+			return "", err
+		}
+		return OutcomeRestored, nil
+	}
+	if err := r.applyTemplate(ctx, p, p.Params["currentHash"]); err != nil {
+		return "", err
+	}
+	return OutcomeRestored, nil
+}
+
+// Change reports the rollout's abort flag, or (undo) the pod-template revision, before
+// and after Execute.
+func (r *RolloutRemediator) Change(p Plan) (string, string) {
+	if p.Params["mode"] == "abort" {
+		return "abort=false", "abort=true"
+	}
+	return "template=" + p.Params["currentHash"], "template=" + p.Params["stableHash"]
+}
+
+// Permissions are what abort and undo need in the default and each allowed namespace:
+// Rollouts (and their status, which abort patches), the ReplicaSets holding revisions, and
+// Events.
+func (r *RolloutRemediator) Permissions() []authorizationv1.ResourceAttributes {
+	var out []authorizationv1.ResourceAttributes
+	for _, ns := range namespacesOf(r.namespace, r.AllowedNamespaces) {
+		out = append(out, permissions(ns, rolloutGVR.Group, rolloutGVR.Resource, "", "get", "patch")...)
+		out = append(out, authorizationv1.ResourceAttributes{Namespace: ns, Group: rolloutGVR.Group,
+			Resource: rolloutGVR.Resource, Subresource: "status", Verb: "patch"})
+		out = append(out, permissions(ns, "apps", "replicasets", "", "list")...)
+		out = append(out, permissions(ns, "", "events", "", "create")...)
+	}
+	return out
+}
+
+// Objects names the Rollout.
+func (r *RolloutRemediator) Objects(p Plan) []corev1.ObjectReference {
+	if p.Params["name"] == "" {
+		return nil
+	}
+	return []corev1.ObjectReference{{APIVersion: rolloutGVR.GroupVersion().String(), Kind: "Rollout",
+		Namespace: p.Params["namespace"], Name: p.Params["name"]}}
+}
+
+// setAbort merge-patches status.abort on the rollout's status subresource.
+func (r *RolloutRemediator) setAbort(ctx context.Context, p Plan, abort bool) error {
+	patch, _ := json.Marshal(map[string]any{"status": map[string]any{"abort": abort}})
+	_, err := r.dyn.Resource(rolloutGVR).Namespace(p.Params["namespace"]).
+		Patch(ctx, p.Params["name"], types.MergePatchType, patch, metav1.PatchOptions{}, "status")
+	if err != nil {
+		return fmt.Errorf("patch rollout %s status: %w", p.Target, err)
+	}
+	return nil
+}
+
+// applyTemplate replaces the rollout's spec.template with that of the ReplicaSet for hash,
+// as `kubectl argo rollouts undo` does: a JSON patch, so labels, annotations or env the
+// other revision has and this one doesn't are dropped rather than merged in.
+func (r *RolloutRemediator) applyTemplate(ctx context.Context, p Plan, hash string) error {
+	rs, err := r.replicaSet(ctx, p, hash)
+	if err != nil {
+		return err
+	}
+	tmpl := rs.Spec.Template.DeepCopy()
+	delete(tmpl.Labels, podTemplateHashLabel) // the controller adds it back per revision
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(tmpl)
+	if err != nil {
+		return fmt.Errorf("convert pod template: %w", err)
+	}
+	patch, err := json.Marshal([]map[string]any{{"op": "replace", "path": "/spec/template", "value": obj}})
+	if err != nil {
+		return fmt.Errorf("marshal rollout patch: %w", err)
+	}
+	_, err = r.dyn.Resource(rolloutGVR).Namespace(p.Params["namespace"]).
+		Patch(ctx, p.Params["name"], types.JSONPatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return fmt.Errorf("patch rollout %s template: %w", p.Target, err)
+	}
+	return nil
+}
+
+// replicaSet finds the rollout's ReplicaSet for a pod-template hash.
+func (r *RolloutRemediator) replicaSet(ctx context.Context, p Plan, hash string) (*appsv1.ReplicaSet, error) {
+	list, err := r.k8s.AppsV1().ReplicaSets(p.Params["namespace"]).List(ctx, metav1.ListOptions{
+		LabelSelector: podTemplateHashLabel + "=" + hash,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("list replicasets for %s: %w", p.Target, err)
+	}
+	for i := range list.Items {
+		for _, ref := range list.Items[i].OwnerReferences {
+			if ref.Kind == "Rollout" && ref.Name == p.Params["name"] {
+				return &list.Items[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no replicaset with %s=%s owned by rollout %s", podTemplateHashLabel, hash, p.Target)
+}
+
+// rolloutImages lists the container images in a rollout's spec.template.
+func rolloutImages(ro *unstructured.Unstructured) []string {
+	containers, _, _ := unstructured.NestedSlice(ro.Object, "spec", "template", "spec", "containers")
+	var out []string
+	for _, c := range containers {
+		if m, ok := c.(map[string]any); ok {
+			img, _ := m["image"].(string)
+			out = append(out, img)
+		}
+	}
+	return out
+}
+
+// templateImages lists the container images in a pod template.
+func templateImages(t corev1.PodTemplateSpec) []string {
+	var out []string
+	for _, c := range t.Spec.Containers {
+		out = append(out, c.Image)
+	}
+	return out
+}
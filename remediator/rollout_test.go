@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// rolloutObject builds an api-service Rollout mid-canary: spec.template runs the canary
+// image, status points at the stable and canary pod-template hashes.
+func rolloutObject(aborted bool, current string) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]any{
+		"apiVersion": "argoproj.io/v1alpha1",
+		"kind":       "Rollout",
+		"metadata":   map[string]any{"name": "api-service", "namespace": "default"},
+		"spec": map[string]any{"template": map[string]any{"spec": map[string]any{
+			"containers": []any{map[string]any{"name": "api", "image": "api:" + current}},
+		}}},
+		"status": map[string]any{"abort": aborted, "stableRS": "v1", "currentPodHash": current},
+	}}
+}
+
+// rolloutReplicaSet is the ReplicaSet Argo Rollouts keeps per revision hash.
+func rolloutReplicaSet(hash string) *appsv1.ReplicaSet {
+	return &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "api-service-" + hash, Namespace: "default",
+			Labels:          map[string]string{podTemplateHashLabel: hash},
+			OwnerReferences: []metav1.OwnerReference{{Kind: "Rollout", Name: "api-service"}},
+		},
+		Spec: appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: map[string]string{podTemplateHashLabel: hash}},
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "api", Image: "api:" + hash}}},
+		}},
+	}
+}
+
+func newFakeRollouts(t *testing.T, ro *unstructured.Unstructured, dryRun bool) (*Registry, *dynamicfake.FakeDynamicClient) {
+	t.Helper()
+	dyn := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{rolloutGVR: "RolloutList"}, ro)
+	cs := fake.NewClientset(rolloutReplicaSet("v1"), rolloutReplicaSet("v2"))
+	r := NewRegistry(dryRun, time.Minute)
+	r.Register(actionRollout, NewRolloutRemediator(dyn, cs, "default"))
+	return r, dyn
+}
+
+func rolloutAlert(mode string) Alert {
+	return Alert{
+		Status: "firing",
+		Labels: map[string]string{"alertname": "ApiServiceHighErrorRate", "service": "api-service"},
+		Annotations: map[string]string{
+			"remediation_action":       actionRollout,
+			"remediation_rollout":      "api-service",
+			"remediation_rollout_mode": mode,
+		},
+	}
+}
+
+func getRollout(t *testing.T, dyn *dynamicfake.FakeDynamicClient) *unstructured.Unstructured {
+	t.Helper()
+	ro, err := dyn.Resource(rolloutGVR).Namespace("default").Get(context.Background(), "api-service", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get rollout: %v", err)
+	}
+	return ro
+}
+
+func TestRollout_Abort(t *testing.T) {
+	r, dyn := newFakeRollouts(t, rolloutObject(false, "v2"), false)
+	res, err := r.Run(context.Background(), rolloutAlert("abort"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeAborted || !res.Executed {
+		t.Errorf("outcome = %q (executed %v), want aborted", res.Outcome, res.Executed)
+	}
+	if res.Plan.Target != "default/api-service" {
+		t.Errorf("target = %q, want default/api-service", res.Plan.Target)
+	}
+	if aborted, _, _ := unstructured.NestedBool(getRollout(t, dyn).Object, "status", "abort"); !aborted {
+		t.Error("status.abort not set")
+	}
+
+	if got, err := r.Undo(context.Background(), res.Plan); err != nil || got != OutcomeRestored {
+		t.Fatalf("undo = %q, %v; want restored", got, err)
+	}
+	if aborted, _, _ := unstructured.NestedBool(getRollout(t, dyn).Object, "status", "abort"); aborted {
+		t.Error("undo did not clear status.abort")
+	}
+}
+
+func TestRollout_AlreadyAborted(t *testing.T) {
+	r, _ := newFakeRollouts(t, rolloutObject(true, "v2"), false)
+	res, err := r.Run(context.Background(), rolloutAlert("abort"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeAlreadyAborted {
+		t.Errorf("outcome = %q, want already_aborted", res.Outcome)
+	}
+}
+
+func TestRollout_DryRunDoesNotMutate(t *testing.T) {
+	r, dyn := newFakeRollouts(t, rolloutObject(false, "v2"), true)
+	res, err := r.Run(context.Background(), rolloutAlert("abort"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeDryRun {
+		t.Errorf("outcome = %q, want dry_run", res.Outcome)
+	}
+	if aborted, _, _ := unstructured.NestedBool(getRollout(t, dyn).Object, "status", "abort"); aborted {
+		t.Error("dry-run set status.abort")
+	}
+}
+
+func TestRollout_Cooldown(t *testing.T) {
+	r, _ := newFakeRollouts(t, rolloutObject(false, "v2"), false)
+	if _, err := r.Run(context.Background(), rolloutAlert("abort")); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	res, err := r.Run(context.Background(), rolloutAlert("abort"))
+	if err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if res.Outcome != OutcomeCooldown {
+		t.Errorf("outcome = %q, want cooldown", res.Outcome)
+	}
+}
+
+func TestRollout_UndoToStable(t *testing.T) {
+	ro := rolloutObject(false, "v2")
+	// Only the canary template has these; the stable one must not inherit them.
+	_ = unstructured.SetNestedStringMap(ro.Object, map[string]string{"canary": "true"}, "spec", "template", "metadata", "annotations")
+	_ = unstructured.SetNestedStringMap(ro.Object, map[string]string{"pool": "canary"}, "spec", "template", "spec", "nodeSelector")
+	r, dyn := newFakeRollouts(t, ro, false)
+	res, err := r.Run(context.Background(), rolloutAlert("undo"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeRolledBack || !res.Executed {
+		t.Errorf("outcome = %q (executed %v), want rolled_back", res.Outcome, res.Executed)
+	}
+	rolledBack := getRollout(t, dyn)
+	if got := rolloutImages(rolledBack); len(got) != 1 || got[0] != "api:v1" {
+		t.Errorf("template images = %v, want [api:v1]", got)
+	}
+	annotations, _, _ := unstructured.NestedStringMap(rolledBack.Object, "spec", "template", "metadata", "annotations")
+	nodeSelector, _, _ := unstructured.NestedStringMap(rolledBack.Object, "spec", "template", "spec", "nodeSelector")
+	if len(annotations) != 0 || len(nodeSelector) != 0 {
+		t.Errorf("template kept the canary's annotations %v and nodeSelector %v, want the stable template exactly", annotations, nodeSelector)
+	}
+
+	if got, err := r.Undo(context.Background(), res.Plan); err != nil || got != OutcomeRestored {
+		t.Fatalf("undo = %q, %v; want restored", got, err)
+	}
+	if got := rolloutImages(getRollout(t, dyn)); len(got) != 1 || got[0] != "api:v2" {
+		t.Errorf("template images after undo = %v, want [api:v2]", got)
+	}
+}
+
+func TestRollout_NoCanaryInFlight(t *testing.T) {
+	r, _ := newFakeRollouts(t, rolloutObject(false, "v1"), false)
+	res, err := r.Run(context.Background(), rolloutAlert("undo"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeAlreadyStable {
+		t.Errorf("outcome = %q, want already_stable", res.Outcome)
+	}
+}
+
+func TestRollout_Missing(t *testing.T) {
+	r, _ := newFakeRollouts(t, rolloutObject(false, "v2"), false)
+	alert := rolloutAlert("abort")
+	alert.Annotations["remediation_rollout"] = "no-such-rollout"
+	res, err := r.Run(context.Background(), alert)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeTargetMissing {
+		t.Errorf("outcome = %q, want target_missing", res.Outcome)
+	}
+}
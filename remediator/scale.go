@@ -0,0 +1,293 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// actionScale is the registry name of the horizontal scale-up action.
+const actionScale = "scale"
+
+// ScaleLimit bounds the scale action for one Deployment: each run adds Step replicas and
+// never goes past Max. A Deployment without a limit is never scaled.
+type ScaleLimit struct {
+	Step int
+	Max  int
+}
+
+// ScaleRemediator adds capacity to the Deployment named in the alert's remediation_scale
+// annotation — for latency alerts (e.g. ApiServiceHighLatency) that a flag kill switch
+// can't fix. It is bounded twice: by the per-target ScaleLimit, and by the maxReplicas
+// of an HPA already managing the Deployment. With an HPA present it raises the HPA's
+// minReplicas instead of the Deployment's replicas, so the two controllers never fight.
+// The extra capacity is temporary: the registry undoes it when the alert resolves.
+type ScaleRemediator struct {
+	k8s       kubernetes.Interface
+	namespace string                // used when the alert carries no namespace label
+	limits    map[string]ScaleLimit // "namespace/name" -> limit
+	// AllowedNamespaces are the other namespaces an alert's namespace label may name; any
+	// other is refused (namespace_not_allowed), even for a target with a limit.
+	AllowedNamespaces []string
+}
+
+// NewScaleRemediator builds the scale action with its per-target limits.
+func NewScaleRemediator(k8s kubernetes.Interface, namespace string, limits map[string]ScaleLimit) *ScaleRemediator {
+	return &ScaleRemediator{k8s: k8s, namespace: namespace, limits: limits}
+}
+
+// parseScaleLimits reads SCALE_TARGETS: comma-separated "namespace/name=step:max" entries,
+// e.g. "default/api-service=1:4,otel-demo/frontend=2:6".
+func parseScaleLimits(s string) (map[string]ScaleLimit, error) {
+	out := map[string]ScaleLimit{}
+	for _, entry := range strings.Split(s, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		target, bounds, ok := strings.Cut(entry, "=")
+		stepStr, maxStr, ok2 := strings.Cut(bounds, ":")
+		if !ok || !ok2 || !strings.Contains(target, "/") {
+			return nil, fmt.Errorf("scale target %q: want namespace/name=step:max", entry)
+		}
+		step, err1 := strconv.Atoi(stepStr)
+		limit, err2 := strconv.Atoi(maxStr)
+		if err1 != nil || err2 != nil || step < 1 || limit < 1 {
+			return nil, fmt.Errorf("scale target %q: step and max must be positive integers", entry)
+		}
+		out[target] = ScaleLimit{Step: step, Max: limit}
+	}
+	return out, nil
+}
+
+// Plan works out the new replica count: current + step, capped by the target's Max and
+// any HPA's maxReplicas. A Deployment already at that ceiling is at_ceiling; one with no
+// configured limit is refused.
+func (r *ScaleRemediator) Plan(ctx context.Context, alert Alert) (Plan, error) {
+	name := alert.Annotations["remediation_scale"]
+	ns, allowed := namespaceFor(alert, r.namespace, r.AllowedNamespaces)
+	p := Plan{Target: ns + "/" + name, Params: map[string]string{"namespace": ns, "name": name}}
+	if name == "" {
+		return p, fmt.Errorf("alert has no remediation_scale annotation")
+	}
+	if !allowed {
+		p.Noop = OutcomeNamespaceNotAllowed
+		return p, nil
+	}
+	limit, ok := r.limits[p.Target]
+	if !ok {
+		p.Noop = OutcomeNoScaleLimit
+		return p, nil
+	}
+
+	d, err := r.k8s.AppsV1().Deployments(ns).Get(ctx, name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		p.Noop = OutcomeTargetMissing
+		return p, nil
+	}
+	if err != nil {
+		return p, fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	hpa, err := r.hpaFor(ctx, ns, name)
+	if err != nil {
+		return p, err
+	}
+
+	current := int32(1)
+	if d.Spec.Replicas != nil {
+		current = *d.Spec.Replicas
+	}
+	ceiling := int32(limit.Max)
+	if hpa != nil {
+		ceiling = min(ceiling, hpa.Spec.MaxReplicas)
+		current = max(current, minReplicas(hpa))
+		p.Params["hpa"] = hpa.Name
+		p.Params["previousMinReplicas"] = strconv.Itoa(int(minReplicas(hpa)))
+	}
+	if current >= ceiling {
+		p.Noop = OutcomeAtCeiling
+		return p, nil
+	}
+
+	next := min(current+int32(limit.Step), ceiling)
+	p.Params["previousReplicas"] = strconv.Itoa(int(current))
+	p.Params["replicas"] = strconv.Itoa(int(next))
+	p.Description = fmt.Sprintf("scaled Deployment %s from %d to %d replicas (ceiling %d)", p.Target, current, next, ceiling)
+	return p, nil
+}
+
+// Execute applies the planned replica count, through the HPA's minReplicas when one
+// manages the Deployment.
+func (r *ScaleRemediator) Execute(ctx context.Context, p Plan) (Outcome, error) {
+	n, _ := strconv.Atoi(p.Params["replicas"])
+	if p.Params["hpa"] != "" {
+		if err := r.setMinReplicas(ctx, p, int32(n)); err != nil {
+			return "", err
+		}
+	}
+	if err := r.setReplicas(ctx, p, int32(n)); err != nil {
+		return "", err
+	}
+	return OutcomeScaledUp, nil
+}
+
+// Verify checks the Deployment (and HPA floor) now ask for the planned replicas.
+func (r *ScaleRemediator) Verify(ctx context.Context, p Plan) (bool, error) {
+	want, _ := strconv.Atoi(p.Params["replicas"])
+	d, err := r.k8s.AppsV1().Deployments(p.Params["namespace"]).Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	if d.Spec.Replicas == nil || int(*d.Spec.Replicas) < want {
+		return false, nil
+	}
+	if p.Params["hpa"] == "" {
+		return true, nil
+	}
+	hpa, err := r.k8s.AutoscalingV2().HorizontalPodAutoscalers(p.Params["namespace"]).Get(ctx, p.Params["hpa"], metav1.GetOptions{})
+	if err != nil {
+		return false, fmt.Errorf("get hpa %s: %w", p.Params["hpa"], err)
+	}
+	return int(minReplicas(hpa)) == want, nil
+}
+
+// Undo scales back down to where Execute started. With an HPA it only restores the
+// HPA's floor and leaves the replica count to the HPA. Either way it only lowers what the
+// remediator itself raised: if someone has changed the floor or the count since, their
+// decision stands and the undo is skipped.
+func (r *ScaleRemediator) Undo(ctx context.Context, p Plan) (Outcome, error) {
+	raised, _ := strconv.Atoi(p.Params["replicas"])
+	if p.Params["hpa"] != "" {
+		hpa, err := r.k8s.AutoscalingV2().HorizontalPodAutoscalers(p.Params["namespace"]).Get(ctx, p.Params["hpa"], metav1.GetOptions{})
+		if err != nil {
+			return "", fmt.Errorf("get hpa %s: %w", p.Params["hpa"], err)
+		}
+		if int(minReplicas(hpa)) != raised {
+			return OutcomeRestoreSkipped, nil
+		}
+		prevMin, _ := strconv.Atoi(p.Params["previousMinReplicas"])
+		if err := r.setMinReplicas(ctx, p, int32(prevMin)); err != nil {
+			return "", err
+		}
+		return OutcomeScaledDown, nil
+	}
+
+	prev, _ := strconv.Atoi(p.Params["previousReplicas"])
+	d, err := r.k8s.AppsV1().Deployments(p.Params["namespace"]).Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return "", fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	if d.Spec.Replicas == nil || int(*d.Spec.Replicas) != raised {
+		return OutcomeRestoreSkipped, nil
+	}
+	if err := r.setReplicas(ctx, p, int32(prev)); err != nil {
+		return "", err
+	}
+	return OutcomeScaledDown, nil
+}
+
+// Stack keeps where the first step on the same Deployment started, so undoing the latest
+// step scales all the way back rather than down one step.
+func (r *ScaleRemediator) Stack(prev, next Plan) Plan {
+	if prev.Target != next.Target {
+		return next
+	}
+	next.Params = maps.Clone(next.Params)
+	next.Params["previousReplicas"] = prev.Params["previousReplicas"]
+	if prev.Params["hpa"] != "" && prev.Params["hpa"] == next.Params["hpa"] {
+		next.Params["previousMinReplicas"] = prev.Params["previousMinReplicas"]
+	}
+	return next
+}
+
+// Change reports the replica count before and after Execute.
+func (r *ScaleRemediator) Change(p Plan) (string, string) {
+	return "replicas=" + p.Params["previousReplicas"], "replicas=" + p.Params["replicas"]
+}
+
+// Permissions are what scaling each configured target needs: its Deployment, the HPAs in
+// its namespace (one may own its replicas), and Events. Targets outside the allowed
+// namespaces are never scaled, so they need nothing.
+func (r *ScaleRemediator) Permissions() []authorizationv1.ResourceAttributes {
+	var out []authorizationv1.ResourceAttributes
+	allowed := namespacesOf(r.namespace, r.AllowedNamespaces)
+	for _, target := range slices.Sorted(maps.Keys(r.limits)) {
+		ns, name, _ := strings.Cut(target, "/")
+		if !slices.Contains(allowed, ns) {
+			continue
+		}
+		out = append(out, permissions(ns, "apps", "deployments", name, "get", "update")...)
+		out = append(out, permissions(ns, "autoscaling", "horizontalpodautoscalers", "", "list", "get", "update")...)
+		out = append(out, permissions(ns, "", "events", "", "create")...)
+	}
+	return out
+}
+
+// Objects names the scaled Deployment.
+func (r *ScaleRemediator) Objects(p Plan) []corev1.ObjectReference {
+	if p.Params["name"] == "" {
+		return nil
+	}
+	return []corev1.ObjectReference{deploymentRef(p.Params["namespace"], p.Params["name"])}
+}
+
+// UndoOnResolve marks scale-up as temporary capacity, reverted once the alert resolves.
+func (r *ScaleRemediator) UndoOnResolve() bool { return true }
+
+func (r *ScaleRemediator) setReplicas(ctx context.Context, p Plan, n int32) error {
+	deployments := r.k8s.AppsV1().Deployments(p.Params["namespace"])
+	d, err := deployments.Get(ctx, p.Params["name"], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get deployment %s: %w", p.Target, err)
+	}
+	d.Spec.Replicas = &n
+	if _, err := deployments.Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update deployment %s: %w", p.Target, err)
+	}
+	return nil
+}
+
+func (r *ScaleRemediator) setMinReplicas(ctx context.Context, p Plan, n int32) error {
+	hpas := r.k8s.AutoscalingV2().HorizontalPodAutoscalers(p.Params["namespace"])
+	hpa, err := hpas.Get(ctx, p.Params["hpa"], metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("get hpa %s: %w", p.Params["hpa"], err)
+	}
+	hpa.Spec.MinReplicas = &n
+	if _, err := hpas.Update(ctx, hpa, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("update hpa %s: %w", p.Params["hpa"], err)
+	}
+	return nil
+}
+
+// hpaFor returns the HPA whose scaleTargetRef is the Deployment, or nil when none is.
+func (r *ScaleRemediator) hpaFor(ctx context.Context, ns, name string) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	list, err := r.k8s.AutoscalingV2().HorizontalPodAutoscalers(ns).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("list hpas in %s: %w", ns, err)
+	}
+	for i, hpa := range list.Items {
+		ref := hpa.Spec.ScaleTargetRef
+		if ref.Kind == "Deployment" && ref.Name == name {
+			return &list.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// minReplicas is the HPA's floor; Kubernetes defaults an unset minReplicas to 1.
+func minReplicas(hpa *autoscalingv2.HorizontalPodAutoscaler) int32 {
+	if hpa.Spec.MinReplicas == nil {
+		return 1
+	}
+	return *hpa.Spec.MinReplicas
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func int32Ptr(n int32) *int32 { return &n }
+
+func scaledDeployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-service", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: int32Ptr(replicas)},
+	}
+}
+
+func apiServiceHPA(minReplicas, maxReplicas int32) *autoscalingv2.HorizontalPodAutoscaler {
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{Name: "api-service", Namespace: "default"},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{Kind: "Deployment", Name: "api-service"},
+			MinReplicas:    int32Ptr(minReplicas),
+			MaxReplicas:    maxReplicas,
+		},
+	}
+}
+
+func newFakeScale(t *testing.T, objs ...runtime.Object) (*Registry, *fake.Clientset) {
+	t.Helper()
+	cs := fake.NewClientset(objs...)
+	r := NewRegistry(false, time.Minute)
+	r.Register(actionScale, NewScaleRemediator(cs, "default", map[string]ScaleLimit{
+		"default/api-service": {Step: 2, Max: 5},
+	}))
+	return r, cs
+}
+
+func scaleAlert(status string) Alert {
+	return Alert{
+		Status:      status,
+		Labels:      map[string]string{"alertname": "ApiServiceHighLatency", "service": "api-service"},
+		Annotations: map[string]string{"remediation_action": actionScale, "remediation_scale": "api-service"},
+	}
+}
+
+func replicas(t *testing.T, cs *fake.Clientset) int32 {
+	t.Helper()
+	d, err := cs.AppsV1().Deployments("default").Get(context.Background(), "api-service", metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get deployment: %v", err)
+	}
+	return *d.Spec.Replicas
+}
+
+func TestScale_StepsUpAndUndoesOnResolve(t *testing.T) {
+	r, cs := newFakeScale(t, scaledDeployment(2))
+
+	res, err := r.Run(context.Background(), scaleAlert("firing"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeScaledUp || !res.Executed {
+		t.Fatalf("outcome = %q (executed %v), want scaled_up", res.Outcome, res.Executed)
+	}
+	if n := replicas(t, cs); n != 4 {
+		t.Errorf("replicas = %d, want 4 (2 + step 2)", n)
+	}
+
+	res, undone, err := r.Resolve(context.Background(), scaleAlert("resolved"))
+	if err != nil || !undone {
+		t.Fatalf("resolve: undone=%v err=%v", undone, err)
+	}
+	if res.Outcome != OutcomeScaledDown {
+		t.Errorf("resolve outcome = %q, want scaled_down", res.Outcome)
+	}
+	if n := replicas(t, cs); n != 2 {
+		t.Errorf("replicas after resolve = %d, want 2", n)
+	}
+	if _, undone, _ := r.Resolve(context.Background(), scaleAlert("resolved")); undone {
+		t.Error("second resolve undid again; want nothing left to undo")
+	}
+}
+
+func TestScale_UndoesEveryStepOnResolve(t *testing.T) {
+	ctx := context.Background()
+	r, cs := newFakeScale(t, scaledDeployment(1))
+	for _, want := range []int32{3, 5} {
+		res, err := r.Run(ctx, scaleAlert("firing"))
+		if err != nil || res.Outcome != OutcomeScaledUp || replicas(t, cs) != want {
+			t.Fatalf("run = (%q, %v), replicas %d; want scaled_up to %d", res.Outcome, err, replicas(t, cs), want)
+		}
+		r.ResetCooldown(ctx, scaleAlert("firing").incidentKey())
+	}
+
+	res, _, err := r.Resolve(ctx, scaleAlert("resolved"))
+	if err != nil || res.Outcome != OutcomeScaledDown || replicas(t, cs) != 1 {
+		t.Errorf("resolve = (%q, %v), replicas %d; want scaled_down to the original 1", res.Outcome, err, replicas(t, cs))
+	}
+
+	r, cs = newFakeScale(t, scaledDeployment(1), apiServiceHPA(1, 5))
+	for range 2 {
+		if _, err := r.Run(ctx, scaleAlert("firing")); err != nil {
+			t.Fatalf("run: %v", err)
+		}
+		r.ResetCooldown(ctx, scaleAlert("firing").incidentKey())
+	}
+	if _, _, err := r.Resolve(ctx, scaleAlert("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	hpa, _ := cs.AutoscalingV2().HorizontalPodAutoscalers("default").Get(ctx, "api-service", metav1.GetOptions{})
+	if *hpa.Spec.MinReplicas != 1 {
+		t.Errorf("hpa minReplicas after resolve = %d, want the original 1", *hpa.Spec.MinReplicas)
+	}
+}
+
+func TestScale_CapsAtCeiling(t *testing.T) {
+	r, cs := newFakeScale(t, scaledDeployment(4))
+	res, err := r.Run(context.Background(), scaleAlert("firing"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if n := replicas(t, cs); res.Outcome != OutcomeScaledUp || n != 5 {
+		t.Errorf("outcome = %q, replicas = %d; want scaled_up to the max of 5", res.Outcome, n)
+	}
+
+	r, cs = newFakeScale(t, scaledDeployment(5))
+	res, err = r.Run(context.Background(), scaleAlert("firing"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if n := replicas(t, cs); res.Outcome != OutcomeAtCeiling || n != 5 {
+		t.Errorf("outcome = %q, replicas = %d; want at_ceiling and untouched", res.Outcome, n)
+	}
+}
+
+func TestScale_HonoursHPA(t *testing.T) {
+	// The HPA's maxReplicas (3) is below our own ceiling (5), so it wins, and we raise the
+	// HPA's floor rather than fight it over the Deployment's replicas.
+	r, cs := newFakeScale(t, scaledDeployment(2), apiServiceHPA(2, 3))
+	res, err := r.Run(context.Background(), scaleAlert("firing"))
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeScaledUp || replicas(t, cs) != 3 {
+		t.Fatalf("outcome = %q, replicas = %d; want scaled_up to the HPA max of 3", res.Outcome, replicas(t, cs))
+	}
+	hpa, _ := cs.AutoscalingV2().HorizontalPodAutoscalers("default").Get(context.Background(), "api-service", metav1.GetOptions{})
+	if *hpa.Spec.MinReplicas != 3 {
+		t.Errorf("hpa minReplicas = %d, want 3", *hpa.Spec.MinReplicas)
+	}
+
+	if _, _, err := r.Resolve(context.Background(), scaleAlert("resolved")); err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	hpa, _ = cs.AutoscalingV2().HorizontalPodAutoscalers("default").Get(context.Background(), "api-service", metav1.GetOptions{})
+	if *hpa.Spec.MinReplicas != 2 {
+		t.Errorf("hpa minReplicas after resolve = %d, want 2", *hpa.Spec.MinReplicas)
+	}
+
+	r, _ = newFakeScale(t, scaledDeployment(3), apiServiceHPA(3, 3))
+	if res, _ := r.Run(context.Background(), scaleAlert("firing")); res.Outcome != OutcomeAtCeiling {
+		t.Errorf("outcome = %q, want at_ceiling at the HPA max", res.Outcome)
+	}
+}
+
+func TestScale_UndoLeavesAChangedCountAlone(t *testing.T) {
+	ctx := context.Background()
+	r, cs := newFakeScale(t, scaledDeployment(2))
+	if _, err := r.Run(ctx, scaleAlert("firing")); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	d, _ := cs.AppsV1().Deployments("default").Get(ctx, "api-service", metav1.GetOptions{})
+	d.Spec.Replicas = int32Ptr(5) // an operator scaled further by hand
+	if _, err := cs.AppsV1().Deployments("default").Update(ctx, d, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err := r.Resolve(ctx, scaleAlert("resolved"))
+	if err != nil || res.Outcome != OutcomeRestoreSkipped || replicas(t, cs) != 5 {
+		t.Errorf("resolve = (%q, %v), replicas %d; want restore_skipped and 5 left alone", res.Outcome, err, replicas(t, cs))
+	}
+
+	r, cs = newFakeScale(t, scaledDeployment(2), apiServiceHPA(2, 5))
+	if _, err := r.Run(ctx, scaleAlert("firing")); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	hpa, _ := cs.AutoscalingV2().HorizontalPodAutoscalers("default").Get(ctx, "api-service", metav1.GetOptions{})
+	hpa.Spec.MinReplicas = int32Ptr(3) // the floor was retuned since the scale-up to 4
+	if _, err := cs.AutoscalingV2().HorizontalPodAutoscalers("default").Update(ctx, hpa, metav1.UpdateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	res, _, err = r.Resolve(ctx, scaleAlert("resolved"))
+	hpa, _ = cs.AutoscalingV2().HorizontalPodAutoscalers("default").Get(ctx, "api-service", metav1.GetOptions{})
+	if err != nil || res.Outcome != OutcomeRestoreSkipped || *hpa.Spec.MinReplicas != 3 {
+		t.Errorf("resolve = (%q, %v), hpa minReplicas %d; want restore_skipped and 3 left alone", res.Outcome, err, *hpa.Spec.MinReplicas)
+	}
+}
+
+func TestScale_RefusesUnconfiguredTarget(t *testing.T) {
+	r, cs := newFakeScale(t, scaledDeployment(2))
+	alert := scaleAlert("firing")
+	alert.Labels["namespace"] = "otel-demo"
+	if res, _ := r.Run(context.Background(), alert); res.Outcome != OutcomeNamespaceNotAllowed {
+		t.Errorf("outcome outside the allowed namespaces = %q, want namespace_not_allowed", res.Outcome)
+	}
+	r.actions[actionScale].(*ScaleRemediator).AllowedNamespaces = []string{"otel-demo"}
+	res, err := r.Run(context.Background(), alert)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if res.Outcome != OutcomeNoScaleLimit || replicas(t, cs) != 2 {
+		t.Errorf("outcome = %q, want no_scale_limit and no change", res.Outcome)
+	}
+}
+
+func TestParseScaleLimits(t *testing.T) {
+	got, err := parseScaleLimits("default/api-service=1:4, otel-demo/frontend=2:6")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got["default/api-service"] != (ScaleLimit{Step: 1, Max: 4}) || got["otel-demo/frontend"] != (ScaleLimit{Step: 2, Max: 6}) {
+		t.Errorf("limits = %+v", got)
+	}
+	if got, err := parseScaleLimits(""); err != nil || len(got) != 0 {
+		t.Errorf("empty = %v, %v; want no limits", got, err)
+	}
+	for _, bad := range []string{"api-service=1:4", "default/api-service=4", "default/api-service=0:4", "default/api-service=a:b"} {
+		if _, err := parseScaleLimits(bad); err == nil {
+			t.Errorf("parseScaleLimits(%q) accepted a bad entry", bad)
+		}
+	}
+}
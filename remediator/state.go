@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// State is the registry's per-incident memory: what it has done and when. Without it a
+// restart forgets every cooldown, and the loop acts again straight away on an incident
+// that is still firing — and forgets which actions it owes an undo.
+type State struct {
+	LastActed  map[string]time.Time `json:"lastActed,omitempty"`  // incidentKey -> last action time
+	Executed   map[string]Plan      `json:"executed,omitempty"`   // incidentKey -> plan to undo on resolve
+	ResolvedAt map[string]time.Time `json:"resolvedAt,omitempty"` // incidentKey -> start of the soak
+	Paused     bool                 `json:"paused,omitempty"`     // an operator paused remediation
+	// DryRun is dry-run as an operator last set it; nil until they do, so REMEDIATOR_DRY_RUN
+	// still decides.
+	DryRun *bool `json:"dryRun,omitempty"`
+	// BudgetTripped is when each tripped budget scope tripped ("" is the global scope), so
+	// a restart or a new leader doesn't quietly close the breaker.
+	BudgetTripped map[string]time.Time `json:"budgetTripped,omitempty"`
+}
+
+// StateStore persists State across restarts. The registry loads it once on startup and
+// writes the whole State back after every change.
+type StateStore interface {
+	Load(ctx context.Context) (State, error)
+	Save(ctx context.Context, s State) error
+}
+
+// MemoryStore is a StateStore that lives only as long as the process — for tests, and
+// the behaviour the remediator had before state was persisted.
+type MemoryStore struct {
+	mu    sync.Mutex
+	state State
+	queue QueueState
+	Saves int // number of Save calls, so tests can check write-through
+}
+
+// Load returns a copy of the last saved State.
+func (m *MemoryStore) Load(context.Context) (State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.state.clone(), nil
+}
+
+// Save replaces the stored State with a copy of s.
+func (m *MemoryStore) Save(_ context.Context, s State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = s.clone()
+	m.Saves++
+	return nil
+}
+
+// LoadQueue returns a copy of the last saved queue.
+func (m *MemoryStore) LoadQueue(context.Context) (QueueState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.queue.clone(), nil
+}
+
+// SaveQueue replaces the stored queue with a copy of q.
+func (m *MemoryStore) SaveQueue(_ context.Context, q QueueState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.queue = q.clone()
+	return nil
+}
+
+// stateKey and queueKey are the ConfigMap keys holding the JSON-encoded State and
+// QueueState.
+const (
+	stateKey = "state.json"
+	queueKey = "queue.json"
+)
+
+// ConfigMapStore keeps State as JSON in a ConfigMap in the remediator's own namespace.
+// The ConfigMap is created on the first Save; a missing one loads as empty State.
+type ConfigMapStore struct {
+	k8s       kubernetes.Interface
+	namespace string
+	name      string
+}
+
+// NewConfigMapStore builds a store backed by the named ConfigMap.
+func NewConfigMapStore(k8s kubernetes.Interface, namespace, name string) *ConfigMapStore {
+	return &ConfigMapStore{k8s: k8s, namespace: namespace, name: name}
+}
+
+// Permissions are what Load and Save need: the ConfigMap, and creating it the first time.
+func (s *ConfigMapStore) Permissions() []authorizationv1.ResourceAttributes {
+	return append(permissions(s.namespace, "", "configmaps", s.name, "get", "update"),
+		permissions(s.namespace, "", "configmaps", "", "create")...)
+}
+
+// Load reads and decodes the ConfigMap's state key.
+func (s *ConfigMapStore) Load(ctx context.Context) (State, error) {
+	var st State
+	err := s.get(ctx, stateKey, &st)
+	return st, err
+}
+
+// Save writes st to the ConfigMap, creating it if needed and retrying on conflict.
+func (s *ConfigMapStore) Save(ctx context.Context, st State) error {
+	return s.put(ctx, stateKey, st)
+}
+
+// LoadQueue reads the work queue. In the cluster the queue has a ConfigMap of its own
+// (REMEDIATOR_QUEUE_CONFIGMAP), so this store holds only one of the two keys.
+func (s *ConfigMapStore) LoadQueue(ctx context.Context) (QueueState, error) {
+	var q QueueState
+	err := s.get(ctx, queueKey, &q)
+	return q, err
+}
+
+// SaveQueue writes the work queue.
+func (s *ConfigMapStore) SaveQueue(ctx context.Context, q QueueState) error {
+	return s.put(ctx, queueKey, q)
+}
+
+// get decodes the JSON under key into v, leaving v alone if the ConfigMap or key is missing.
+func (s *ConfigMapStore) get(ctx context.Context, key string, v any) error {
+	cm, err := s.k8s.CoreV1().ConfigMaps(s.namespace).Get(ctx, s.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("get configmap %s/%s: %w", s.namespace, s.name, err)
+	}
+	if raw := cm.Data[key]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), v); err != nil {
+			return fmt.Errorf("parse %s in configmap %s/%s: %w", key, s.namespace, s.name, err)
+		}
+	}
+	return nil
+}
+
+// put writes v as JSON under key, creating the ConfigMap if needed and retrying on
+// conflict — the state and the queue are saved independently.
+func (s *ConfigMapStore) put(ctx context.Context, key string, v any) error {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", key, err)
+	}
+	configMaps := s.k8s.CoreV1().ConfigMaps(s.namespace)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		cm, err := configMaps.Get(ctx, s.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			cm = &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: s.name, Namespace: s.namespace},
+				Data:       map[string]string{key: string(raw)},
+			}
+			_, err = configMaps.Create(ctx, cm, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if cm.Data == nil {
+			cm.Data = map[string]string{}
+		}
+		cm.Data[key] = string(raw)
+		_, err = configMaps.Update(ctx, cm, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("save %s to configmap %s/%s: %w", key, s.namespace, s.name, err)
+	}
+	return nil
+}
+
+// clone copies the State's maps so the stored and live copies never alias.
+func (s State) clone() State {
+	return State{
+		LastActed:  maps.Clone(s.LastActed),
+		Executed:   maps.Clone(s.Executed),
+		ResolvedAt: maps.Clone(s.ResolvedAt),
+		Paused:     s.Paused,
+		DryRun:     s.DryRun,
+
+		BudgetTripped: maps.Clone(s.BudgetTripped),
+	}
+}
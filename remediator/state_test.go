@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestRegistry_CooldownSurvivesRestart(t *testing.T) {
+	store := &MemoryStore{}
+	a := &stubAction{verified: true}
+	r := NewRegistry(false, time.Minute)
+	r.Register("stub", a)
+	if err := r.UseStore(context.Background(), store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	if _, err := r.Run(context.Background(), stubAlert()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if store.Saves == 0 {
+		t.Fatal("store never saved; want write-through on markActed")
+	}
+
+	// A new process: fresh registry, same store.
+	restarted := NewRegistry(false, time.Minute)
+	restarted.Register("stub", a)
+	if err := restarted.UseStore(context.Background(), store); err != nil {
+		t.Fatalf("UseStore after restart: %v", err)
+	}
+	res, err := restarted.Run(context.Background(), stubAlert())
+	if err != nil {
+		t.Fatalf("run after restart: %v", err)
+	}
+	if res.Outcome != OutcomeCooldown || a.executed != 1 {
+		t.Errorf("after restart outcome = %q (executed %d), want %q (executed 1)", res.Outcome, a.executed, OutcomeCooldown)
+	}
+}
+
+// undoingStub is a stubAction the registry undoes on resolve.
+type undoingStub struct{ stubAction }
+
+func (*undoingStub) UndoOnResolve() bool { return true }
+
+func TestRegistry_PendingUndoSurvivesRestart(t *testing.T) {
+	store := &MemoryStore{}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	newRegistry := func() *Registry {
+		r := NewRegistry(false, time.Minute)
+		r.Soak = 10 * time.Minute
+		r.now = func() time.Time { return now }
+		r.Register("stub", &undoingStub{stubAction{verified: true}})
+		if err := r.UseStore(context.Background(), store); err != nil {
+			t.Fatalf("UseStore: %v", err)
+		}
+		return r
+	}
+	alert := stubAlert()
+
+	r := newRegistry()
+	if _, err := r.Run(context.Background(), alert); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	alert.Status = "resolved"
+	if res, ok, err := r.Resolve(context.Background(), alert); err != nil || !ok || res.Outcome != OutcomeRestorePending {
+		t.Fatalf("Resolve = (%q, %v, %v), want restore_pending", res.Outcome, ok, err)
+	}
+
+	now = now.Add(11 * time.Minute)
+	restores := newRegistry().RestoreDue(context.Background())
+	if len(restores) != 1 || restores[0].Err != nil || restores[0].Outcome != OutcomeRestored {
+		t.Fatalf("RestoreDue after restart = %+v, want one restored", restores)
+	}
+	if st, _ := store.Load(context.Background()); len(st.Executed) != 0 || len(st.ResolvedAt) != 0 {
+		t.Errorf("stored state after restore = %+v, want no pending undo", st)
+	}
+}
+
+func TestRegistry_DryRunUndoKeepsThePlan(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry(false, time.Minute)
+	r.now = func() time.Time { return now }
+	r.Register("stub", &undoingStub{stubAction{verified: true}})
+	alert := stubAlert()
+	if _, err := r.Run(ctx, alert); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	r.SetDryRun(ctx, true) // switched on at runtime, after the real change
+	alert.Status = "resolved"
+	res, ok, err := r.Resolve(ctx, alert)
+	if err != nil || !ok || res.Outcome != OutcomeDryRun || res.Executed {
+		t.Fatalf("Resolve in dry-run = (%+v, %v, %v), want dry_run, not executed", res, ok, err)
+	}
+	if restores := r.RestoreDue(ctx); len(restores) != 0 {
+		t.Errorf("RestoreDue in dry-run = %+v, want the undo held", restores)
+	}
+
+	r.SetDryRun(ctx, false)
+	restores := r.RestoreDue(ctx)
+	if len(restores) != 1 || restores[0].Outcome != OutcomeRestored || !restores[0].Executed {
+		t.Errorf("RestoreDue after dry-run = %+v, want the real change restored", restores)
+	}
+}
+
+func TestRegistry_DryRunToggleSurvivesRestart(t *testing.T) {
+	ctx := context.Background()
+	store := &MemoryStore{}
+	newRegistry := func(envDryRun bool) *Registry {
+		r := NewRegistry(envDryRun, time.Minute)
+		if err := r.UseStore(ctx, store); err != nil {
+			t.Fatalf("UseStore: %v", err)
+		}
+		return r
+	}
+
+	if r := newRegistry(true); r.Mode() != modeDryRun {
+		t.Fatalf("mode = %s before any toggle, want REMEDIATOR_DRY_RUN's dry_run", r.Mode())
+	}
+	newRegistry(false).SetDryRun(ctx, true)
+	if r := newRegistry(false); r.Mode() != modeDryRun {
+		t.Errorf("mode after restart = %s, want the toggled dry_run", r.Mode())
+	}
+	newRegistry(false).SetDryRun(ctx, false)
+	if r := newRegistry(true); r.Mode() != modeActive {
+		t.Errorf("mode after restart = %s, want the toggled active over the environment", r.Mode())
+	}
+}
+
+func TestRegistry_PersistDropsExpiredCooldowns(t *testing.T) {
+	store := &MemoryStore{}
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	r := NewRegistry(true, time.Minute)
+	r.now = func() time.Time { return now }
+	r.Register("stub", &stubAction{})
+	if err := r.UseStore(context.Background(), store); err != nil {
+		t.Fatalf("UseStore: %v", err)
+	}
+	if _, err := r.Run(context.Background(), stubAlert()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	now = now.Add(2 * time.Minute)
+	other := stubAlert()
+	other.Labels = map[string]string{"alertname": "HighLatency", "service": "checkout"}
+	if _, err := r.Run(context.Background(), other); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	st, _ := store.Load(context.Background())
+	if _, ok := st.LastActed["HighLatency|cart"]; ok || len(st.LastActed) != 1 {
+		t.Errorf("stored cooldowns = %v, want only HighLatency|checkout", st.LastActed)
+	}
+}
+
+func TestConfigMapStore_RoundTrip(t *testing.T) {
+	k8s := fake.NewClientset()
+	s := NewConfigMapStore(k8s, "observability", "remediator-state")
+	ctx := context.Background()
+
+	st, err := s.Load(ctx)
+	if err != nil || len(st.LastActed) != 0 {
+		t.Fatalf("Load before first save = (%+v, %v), want empty state", st, err)
+	}
+
+	at := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	plan := Plan{Action: actionFlagd, Target: "cartFailure", IncidentKey: "HighErrors|cart",
+		Params: map[string]string{"previousVariant": "on"}}
+	for i := range 2 { // first save creates the ConfigMap, second updates it
+		want := State{
+			LastActed: map[string]time.Time{"HighErrors|cart": at.Add(time.Duration(i) * time.Minute)},
+			Executed:  map[string]Plan{"HighErrors|cart": plan},
+		}
+		if err := s.Save(ctx, want); err != nil {
+			t.Fatalf("Save #%d: %v", i+1, err)
+		}
+		got, err := s.Load(ctx)
+		if err != nil {
+			t.Fatalf("Load #%d: %v", i+1, err)
+		}
+		if !got.LastActed["HighErrors|cart"].Equal(want.LastActed["HighErrors|cart"]) ||
+			got.Executed["HighErrors|cart"].Params["previousVariant"] != "on" {
+			t.Errorf("Load #%d = %+v, want %+v", i+1, got, want)
+		}
+	}
+	if _, err := k8s.CoreV1().ConfigMaps("observability").Get(ctx, "remediator-state", metav1.GetOptions{}); err != nil {
+		t.Errorf("state configmap not created: %v", err)
+	}
+}
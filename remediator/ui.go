@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// uiFiles is the incident timeline UI: a static page that reads the incident API with
+// the admin token the on-call engineer enters, so it needs no auth of its own.
+//
+//go:embed ui
+var uiFiles embed.FS
+
+// uiFS serves the UI's files from the root of the embedded ui directory.
+func uiFS() http.FileSystem {
+	sub, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err) // the directory is embedded at build time
+	}
+	return http.FS(sub)
+}
@@ -0,0 +1,160 @@
+// The incident timeline UI: lists open and recent incidents from GET /incidents and,
+// for the selected one, draws GET /incidents/{key}'s timeline next to its RCA.
+"use strict";
+
+const api = new URL("../incidents", location.href);
+const refreshMs = 15000;
+let selected = new URLSearchParams(location.hash.slice(1)).get("incident");
+
+const $ = (id) => document.getElementById(id);
+
+function el(tag, attrs, ...children) {
+  const node = document.createElement(tag);
+  for (const [k, v] of Object.entries(attrs || {})) {
+    if (k === "class") node.className = v;
+    else node.setAttribute(k, v);
+  }
+  node.append(...children.filter((c) => c != null));
+  return node;
+}
+
+function when(ts) {
+  return ts ? new Date(ts).toLocaleString() : "";
+}
+
+async function get(url) {
+  const token = sessionStorage.getItem("remediator-token") || "";
+  const resp = await fetch(url, { headers: { Authorization: "Bearer " + token } });
+  const body = await resp.json().catch(() => ({}));
+  if (!resp.ok) {
+    throw new Error(body.leader ? `${body.error} (${body.leader})` : body.error || resp.statusText);
+  }
+  return body;
+}
+
+async function refresh() {
+  try {
+    const page = await get(api + "?limit=200");
+    const open = page.incidents.filter((i) => i.state !== "resolved");
+    const recent = page.incidents.filter((i) => i.state === "resolved");
+    renderList($("open"), open, "No open incidents");
+    renderList($("recent"), recent, "Nothing resolved recently");
+    if (selected) await show(selected);
+    $("status").textContent = "";
+  } catch (err) {
+    $("status").textContent = err.message;
+  }
+}
+
+function renderList(ul, incidents, empty) {
+  ul.replaceChildren();
+  if (incidents.length === 0) {
+    ul.append(el("li", { class: "empty" }, empty));
+    return;
+  }
+  for (const inc of incidents) {
+    const li = el("li", { class: inc.key === selected ? "selected" : "" },
+      el("div", { class: "key" }, inc.key),
+      el("span", { class: "state " + inc.state }, inc.state), " ",
+      el("span", { class: "seen" }, "last seen " + when(inc.lastSeen)));
+    li.addEventListener("click", () => {
+      selected = inc.key;
+      location.hash = new URLSearchParams({ incident: inc.key });
+      for (const other of document.querySelectorAll(".incidents li")) other.classList.remove("selected");
+      li.classList.add("selected");
+      show(inc.key).catch((err) => ($("status").textContent = err.message));
+    });
+    ul.append(li);
+  }
+}
+
+async function show(key) {
+  const inc = await get(api + "/" + encodeURIComponent(key));
+  $("incident").hidden = false;
+  $("incident-title").replaceChildren(inc.key, " ", el("span", { class: "state " + inc.state }, inc.state));
+  let meta = `first seen ${when(inc.firstSeen)} · last seen ${when(inc.lastSeen)}`;
+  if (inc.resolvedAt) meta += ` · resolved ${when(inc.resolvedAt)}`;
+  if (inc.flaps) meta += ` · ${inc.flaps} flap${inc.flaps > 1 ? "s" : ""}`;
+  $("incident-meta").textContent = meta;
+
+  $("timeline").replaceChildren(...inc.timeline.map((e) =>
+    el("li", { class: e.kind + (e.failed ? " failed" : "") },
+      el("time", { datetime: e.at }, when(e.at)),
+      el("div", { class: "title" }, e.title),
+      e.detail ? el("div", { class: "detail" }, e.detail) : null)));
+
+  const rca = $("rca");
+  if (inc.rca && inc.rca.body) {
+    rca.innerHTML = markdown(inc.rca.body);
+  } else {
+    rca.replaceChildren(el("p", { class: "none" }, inc.rca && inc.rca.error
+      ? "The RCA draft failed: " + inc.rca.error
+      : "No RCA drafted yet."));
+  }
+}
+
+function escapeHTML(s) {
+  return s.replace(/[&<>"']/g, (c) => ({ "&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;" })[c]);
+}
+
+// inline renders code spans, bold, italics and http(s) links in already-escaped text.
+function inline(s) {
+  return s
+    .replace(/`([^`]+)`/g, "<code>$1</code>")
+    .replace(/\*\*([^*]+)\*\*/g, "<strong>$1</strong>")
+    .replace(/(^|[^\w*])[*_]([^*_]+)[*_](?=$|[^\w*])/g, "$1<em>$2</em>")
+    .replace(/\[([^\]]+)\]\((https?:\/\/[^)\s]+)\)/g, '<a href="$2" rel="noopener noreferrer" target="_blank">$1</a>');
+}
+
+// markdown renders the subset of markdown the copilot writes: headings, lists, code
+// blocks, rules and paragraphs. Everything is escaped first, so the RCA can't inject HTML.
+function markdown(src) {
+  const out = [];
+  let list = null;
+  let para = [];
+  const flush = () => {
+    if (para.length) out.push("<p>" + inline(para.join(" ")) + "</p>");
+    para = [];
+    if (list) out.push(`</${list}>`);
+    list = null;
+  };
+  const lines = escapeHTML(src).split("\n");
+  for (let i = 0; i < lines.length; i++) {
+    const line = lines[i];
+    let m;
+    if (line.startsWith("```")) {
+      flush();
+      const code = [];
+      while (++i < lines.length && !lines[i].startsWith("```")) code.push(lines[i]);
+      out.push("<pre><code>" + code.join("\n") + "</code></pre>");
+    } else if ((m = line.match(/^(#{1,6})\s+(.*)$/))) {
+      flush();
+      const h = Math.min(m[1].length + 1, 6); // the incident title is the page's h2
+      out.push(`<h${h}>${inline(m[2])}</h${h}>`);
+    } else if (/^\s*(---|\*\*\*)\s*$/.test(line)) {
+      flush();
+      out.push("<hr>");
+    } else if ((m = line.match(/^\s*([-*]|\d+\.)\s+(.*)$/))) {
+      const kind = /\d/.test(m[1]) ? "ol" : "ul";
+      if (para.length || list !== kind) flush();
+      if (!list) out.push(`<${(list = kind)}>`);
+      out.push("<li>" + inline(m[2]) + "</li>");
+    } else if (line.trim() === "") {
+      flush();
+    } else {
+      if (list) flush();
+      para.push(line.trim());
+    }
+  }
+  flush();
+  return out.join("\n");
+}
+
+$("token").value = sessionStorage.getItem("remediator-token") || "";
+$("token-form").addEventListener("submit", (ev) => {
+  ev.preventDefault();
+  sessionStorage.setItem("remediator-token", $("token").value);
+  refresh();
+});
+refresh();
+setInterval(refresh, refreshMs);
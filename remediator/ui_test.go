@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestUI_ServesEmbeddedPage(t *testing.T) {
+	router := gin.New()
+	router.StaticFS("/ui", uiFS())
+
+	for path, want := range map[string]string{
+		"/ui/":          "Remediator incidents",
+		"/ui/app.js":    "../incidents",
+		"/ui/style.css": ".timeline",
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), want) {
+			t.Errorf("GET %s = %d, want 200 containing %q", path, w.Code, want)
+		}
+	}
+}
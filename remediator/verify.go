@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/tomjga/OmniObserve/remediator/internal/evidence"
+	"github.com/tomjga/OmniObserve/remediator/internal/sink"
+)
+
+// verifier re-checks the SLO after every executed action. Nil (no PROMETHEUS_URL) means
+// actions are only verified at the cluster level, by Action.Verify.
+var verifier *Verifier
+
+// escalationsTotal audits escalations (ineffective actions, a tripped budget), per sink
+// publish result.
+var escalationsTotal = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "remediator_escalations_total",
+		Help: "High-priority escalations (ineffective remediations, tripped budget), by per-sink publish result.",
+	},
+	[]string{"result"},
+)
+
+func init() { prometheus.MustRegister(escalationsTotal) }
+
+// Verifier checks that an executed action actually fixed the symptom — not just that the
+// cluster accepted the change (Action.Verify), but that the service's error ratio came
+// back down. INC-2026-0007 is the failure it exists for: a flag flip that landed in the
+// ConfigMap but never reached the services, so the loop reported success while the
+// errors carried on.
+type Verifier struct {
+	prom      *evidence.Prometheus
+	Interval  time.Duration // wait before each check (the 5m rate windows need time to move)
+	Checks    int           // checks before an action that hasn't helped is ineffective
+	Threshold float64       // error ratio at or below which the service counts as recovered
+}
+
+// NewVerifier builds a verifier with the defaults: five checks two minutes apart, against
+// the 5% error ratio the demo SLO alerts on.
+func NewVerifier(prom *evidence.Prometheus) *Verifier {
+	return &Verifier{prom: prom, Interval: 2 * time.Minute, Checks: 5, Threshold: 0.05}
+}
+
+// initVerifier wires the verifier from env, or returns nil when there is no Prometheus.
+func initVerifier() *Verifier {
+	u := os.Getenv("PROMETHEUS_URL")
+	if u == "" {
+		logger.Warnw("no PROMETHEUS_URL; actions won't be verified against the SLO")
+		return nil
+	}
+	v := NewVerifier(evidence.NewPrometheus(u))
+	v.Interval = time.Duration(envInt("VERIFY_INTERVAL_SECONDS", 120)) * time.Second
+	v.Checks = envInt("VERIFY_CHECKS", 5)
+	if t, err := strconv.ParseFloat(os.Getenv("VERIFY_ERROR_RATIO"), 64); err == nil {
+		v.Threshold = t
+	}
+	logger.Infow("action verifier ready",
+		"interval", v.Interval.String(), "checks", v.Checks, "threshold", v.Threshold)
+	return v
+}
+
+// Watch polls the service's error ratio until it is at or below threshold (verified) or
+// the checks run out with it still above (ineffective). If Prometheus never has data for
+// the service, there is nothing to judge by (unverified). It returns the last ratio seen.
+func (v *Verifier) Watch(ctx context.Context, service string, threshold float64) (Outcome, float64) {
+	last, seen := 0.0, false
+	for i := 0; i < v.Checks; i++ {
+		select {
+		case <-ctx.Done():
+			return OutcomeUnverified, last
+		case <-time.After(v.Interval):
+		}
+		ratio, ok := v.prom.ErrorRatio(ctx, service)
+		if !ok {
+			continue
+		}
+		last, seen = ratio, true
+		if ratio <= threshold {
+			return OutcomeVerified, ratio
+		}
+	}
+	if !seen {
+		return OutcomeUnverified, 0
+	}
+	return OutcomeIneffective, last
+}
+
+// verifyAction watches the SLO after an executed action and records verified,
+// ineffective or unverified like any other outcome; the incident's transition escalates
+// an ineffective one. It runs in its own goroutine: a watch lasts minutes. Without
+// Prometheus nothing can judge the action, and it is recorded unverified straight away.
+func verifyAction(alert Alert, res Result) {
+	ctx, span := otel.Tracer("remediator").Start(context.Background(), "verify")
+	defer span.End()
+	if verifier == nil {
+		recordAction(span, res.Plan.Action, alert.incidentKey(), Result{Plan: res.Plan, Outcome: OutcomeUnverified}, nil)
+		return
+	}
+	budget := verifier.Interval*time.Duration(verifier.Checks) + time.Minute
+	ctx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	service := alert.Labels["service"]
+	outcome, ratio := verifier.Watch(ctx, service, alert.verifyThreshold(verifier.Threshold))
+	span.SetAttributes(attribute.Float64("error_ratio", ratio))
+	recordAction(span, res.Plan.Action, alert.incidentKey(), Result{Plan: res.Plan, Outcome: outcome, ErrorRatio: ratio}, nil)
+}
+
+// escalate tells humans a remediation ran but didn't fix the SLO, via every non-corpus sink.
+func escalate(ctx context.Context, alert Alert, res Result, ratio float64) {
+	if publisher == nil {
+		return
+	}
+	service := alert.Labels["service"]
+	body := fmt.Sprintf("The remediator %s for incident `%s`, and the change landed, but %s's "+
+		"error ratio is still %.3f after %d checks over %s. The automated fix did not work — "+
+		"a human needs to take over.\n\nAlert: %s — %s\n",
+		res.Plan.Description, alert.incidentKey(), service, ratio,
+		verifier.Checks, verifier.Interval*time.Duration(verifier.Checks),
+		alert.alertName(), alert.Annotations["summary"])
+	r := sink.RCA{
+		Title:    "[ESCALATION] Remediation ineffective: " + alert.alertName() + " on " + service,
+		Body:     body,
+		Service:  service,
+		StartsAt: alert.StartsAt,
+	}
+	results := publishEscalation(ctx, r, "remediation ineffective; escalated", "incident_key", alert.incidentKey())
+	incidents.Published(alert.incidentKey(), "escalation", results)
+}
+
+// publishEscalation sends r through every escalation sink, logging msg (with kv) and
+// counting each publish. It returns the sinks' results.
+func publishEscalation(ctx context.Context, r sink.RCA, msg string, kv ...any) []sink.Result {
+	results := publisher.Escalate(ctx, r)
+	for _, pr := range results {
+		if pr.Error != nil {
+			logger.Errorw("escalation publish failed", "sink", pr.Sink, "error", pr.Error)
+			escalationsTotal.WithLabelValues("publish_error").Inc()
+		} else {
+			logger.Warnw(msg, append([]any{"sink", pr.Sink}, kv...)...)
+			escalationsTotal.WithLabelValues("published").Inc()
+		}
+	}
+	return results
+}
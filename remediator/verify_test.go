@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tomjga/OmniObserve/remediator/internal/evidence"
+	"github.com/tomjga/OmniObserve/remediator/internal/sink"
+)
+
+// fakeErrorRatio serves one gRPC error ratio per check from ratios ("" = no data); the
+// last value repeats once the sequence runs out.
+func fakeErrorRatio(t *testing.T, ratios ...string) *evidence.Prometheus {
+	t.Helper()
+	var mu sync.Mutex
+	n := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("query"), "rpc_grpc_status_code") {
+			_, _ = w.Write([]byte(`{"data":{"result":[]}}`))
+			return
+		}
+		mu.Lock()
+		v := ratios[min(n, len(ratios)-1)]
+		n++
+		mu.Unlock()
+		if v == "" {
+			_, _ = w.Write([]byte(`{"data":{"result":[]}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":{"result":[{"value":[1,"` + v + `"]}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+	return evidence.NewPrometheus(srv.URL)
+}
+
+func fastVerifier(prom *evidence.Prometheus) *Verifier {
+	v := NewVerifier(prom)
+	v.Interval = time.Millisecond
+	v.Checks = 3
+	return v
+}
+
+func TestVerifier_Watch(t *testing.T) {
+	tests := []struct {
+		name   string
+		ratios []string
+		want   Outcome
+	}{
+		{"recovers on a later check", []string{"0.4", "0.2", "0.01"}, OutcomeVerified},
+		{"keeps burning", []string{"0.4", "0.3", "0.35"}, OutcomeIneffective},
+		{"no data at all", []string{""}, OutcomeUnverified},
+		{"gap then burning", []string{"", "0.5"}, OutcomeIneffective},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, _ := fastVerifier(fakeErrorRatio(t, tt.ratios...)).Watch(context.Background(), "product-catalog", 0.05)
+			if got != tt.want {
+				t.Errorf("Watch = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyAction_EscalatesIneffective(t *testing.T) {
+	var mu sync.Mutex
+	var annotations []string
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf := new(strings.Builder)
+		_, _ = io.Copy(buf, r.Body)
+		mu.Lock()
+		annotations = append(annotations, buf.String())
+		mu.Unlock()
+	}))
+	defer grafana.Close()
+
+	verifier = fastVerifier(fakeErrorRatio(t, "0.4"))
+	publisher = sink.NewPublisher(sink.Grafana{URL: grafana.URL, Token: "t", HTTP: grafana.Client()},
+		sink.GitHubIssue{}, sink.GitHubCorpus{})
+	incidents = NewIncidents()
+	t.Cleanup(func() { verifier, publisher, incidents = nil, nil, nil })
+
+	alert := Alert{Labels: map[string]string{"alertname": "ProductCatalogHighErrorRate", "service": "product-catalog"}}
+	verifyAction(alert, Result{Plan: Plan{Action: actionFlagd, Target: "productCatalogFailure",
+		Description: "disabled flagd flag productCatalogFailure"}, Executed: true})
+
+	if len(annotations) != 1 {
+		t.Fatalf("got %d escalations, want 1", len(annotations))
+	}
+	if !strings.Contains(annotations[0], "priority:high") || !strings.Contains(annotations[0], "productCatalogFailure") {
+		t.Errorf("escalation %s lacks priority or the action taken", annotations[0])
+	}
+	if inc, _ := incidents.Get(alert.incidentKey()); inc.State != IncidentIneffective || len(inc.Publishes) != 1 || inc.Publishes[0].Kind != "escalation" {
+		t.Errorf("incident = %+v, want it ineffective with the escalation's publish result", inc)
+	}
+}
+
+func TestVerifyAction_NoEscalationWhenVerified(t *testing.T) {
+	called := false
+	grafana := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { called = true }))
+	defer grafana.Close()
+
+	verifier = fastVerifier(fakeErrorRatio(t, "0.01"))
+	publisher = sink.NewPublisher(sink.Grafana{URL: grafana.URL, Token: "t", HTTP: grafana.Client()},
+		sink.GitHubIssue{}, sink.GitHubCorpus{})
+	t.Cleanup(func() { verifier, publisher = nil, nil })
+
+	verifyAction(Alert{Labels: map[string]string{"service": "product-catalog"}}, Result{Executed: true})
+	if called {
+		t.Error("escalated a verified action")
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
-import "time"
+import (
+	"strconv"
+	"time"
+)
 
 // AlertmanagerWebhook is the JSON payload Alertmanager POSTs to a webhook receiver.
 // We model only the fields the remediator actually uses; Alertmanager sends more.
@@ -53,3 +56,38 @@ func (a Alert) incidentKey() string {
 func (a Alert) remediationFlag() string {
 	return a.Annotations["remediation_flag"]
 }
+
+// remediationAction names the registered Action this alert opts into via the
+// remediation_action annotation. Alerts that only set remediation_flag predate the
+// registry and keep meaning "the flagd kill switch"; remediation_rollback alone is
+// shorthand for the Deployment rollback.
+func (a Alert) remediationAction() string {
+	if n := a.Annotations["remediation_action"]; n != "" {
+		return n
+	}
+	if a.remediationFlag() != "" {
+		return actionFlagd
+	}
+	if a.Annotations["remediation_rollback"] != "" {
+		return actionRollback
+	}
+	return ""
+}
+
+// namespace is the Kubernetes namespace the alert's workload lives in: its namespace
+// label when the series carries one, otherwise def (the action's configured default).
+func (a Alert) namespace(def string) string {
+	if ns := a.Labels["namespace"]; ns != "" {
+		return ns
+	}
+	return def
+}
+
+// verifyThreshold is the error ratio the service must get back under for an action to
+// count as having worked: the alert's remediation_verify_threshold annotation, or def.
+func (a Alert) verifyThreshold(def float64) float64 {
+	if t, err := strconv.ParseFloat(a.Annotations["remediation_verify_threshold"], 64); err == nil {
+		return t
+	}
+	return def
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// webhookAuth guards POST /webhook. Nil (no WEBHOOK_* credentials configured) accepts
+// anyone who can reach the pod, as before — a forged alert naming a remediation_flag would
+// then flip flags, so production installs should set at least one scheme.
+var webhookAuth *WebhookAuth
+
+// webhooksRejected: "is someone posting alerts who shouldn't?" — normally 0.
+var webhooksRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "remediator_webhooks_rejected_total",
+		Help: "Webhooks refused by authentication, by reason (credentials/signature/client_cert).",
+	},
+	[]string{"reason"},
+)
+
+func init() { prometheus.MustRegister(webhooksRejected) }
+
+// Reasons a webhook is rejected, as the metric's reason label and the audit record's param.
+const (
+	rejectCredentials = "credentials" // missing or wrong bearer token / basic auth
+	rejectSignature   = "signature"   // missing or wrong HMAC of the body
+	rejectClientCert  = "client_cert" // no client certificate verified against the client CA
+)
+
+// defaultHMACHeader carries the body's signature, as "sha256=<hex>" (or the bare hex).
+const defaultHMACHeader = "X-Remediator-Signature"
+
+// WebhookAuth is what a webhook must present. Each configured scheme is required: a
+// token or basic credentials (Alertmanager's http_config authorization / basic_auth — either
+// one is accepted when both are set), an HMAC-SHA256 of the body, and a client certificate.
+type WebhookAuth struct {
+	Token              string // bearer token
+	Username, Password string // basic auth
+	HMACSecret         string
+	HMACHeader         string
+	ClientCert         bool // require a certificate verified against the TLS listener's client CA
+}
+
+// initWebhookAuth reads the webhook's credentials from the environment: WEBHOOK_TOKEN,
+// WEBHOOK_BASIC_USER / WEBHOOK_BASIC_PASSWORD, WEBHOOK_HMAC_SECRET (signature in
+// WEBHOOK_HMAC_HEADER), and WEBHOOK_TLS_CLIENT_CA for client certificates. It returns nil
+// when none is set.
+func initWebhookAuth() (*WebhookAuth, error) {
+	a := &WebhookAuth{
+		Token:      os.Getenv("WEBHOOK_TOKEN"),
+		Username:   os.Getenv("WEBHOOK_BASIC_USER"),
+		Password:   os.Getenv("WEBHOOK_BASIC_PASSWORD"),
+		HMACSecret: os.Getenv("WEBHOOK_HMAC_SECRET"),
+		HMACHeader: envStr("WEBHOOK_HMAC_HEADER", defaultHMACHeader),
+		ClientCert: os.Getenv("WEBHOOK_TLS_CLIENT_CA") != "",
+	}
+	if (a.Username == "") != (a.Password == "") {
+		return nil, errors.New("WEBHOOK_BASIC_USER and WEBHOOK_BASIC_PASSWORD must be set together")
+	}
+	if a.Token == "" && a.Username == "" && a.HMACSecret == "" && !a.ClientCert {
+		return nil, nil
+	}
+	// Followers forward webhooks to the leader over plain HTTP, where no client certificate
+	// can be checked; the leader takes the follower's word for it only when the forward is
+	// signed with the secret the replicas share.
+	if a.ClientCert && os.Getenv("LEADER_ELECTION_ENABLED") == "true" && os.Getenv("LEADER_FORWARD_SECRET") == "" {
+		return nil, errors.New("WEBHOOK_TLS_CLIENT_CA with leader election needs LEADER_FORWARD_SECRET")
+	}
+	return a, nil
+}
+
+// Middleware rejects, counts and audits webhooks that fail any configured scheme, and
+// passes the rest on with their body intact. A nil WebhookAuth lets everything through.
+func (a *WebhookAuth) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if a == nil {
+			c.Next()
+			return
+		}
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "could not read body"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if reason := a.check(c.Request, body); reason != "" {
+			rejectWebhook(c, reason)
+			return
+		}
+		c.Next()
+	}
+}
+
+// check returns why r fails authentication, or "" when it passes.
+func (a *WebhookAuth) check(r *http.Request, body []byte) string {
+	if a.Token != "" || a.Username != "" {
+		if !a.credentialsOK(r) {
+			return rejectCredentials
+		}
+	}
+	if a.HMACSecret != "" && !a.signatureOK(r.Header.Get(a.HMACHeader), body) {
+		return rejectSignature
+	}
+	// A webhook a follower forwarded was checked for a certificate by that follower; its
+	// other credentials came along and were checked again above. The forwarded header alone
+	// proves nothing — anyone can set it — so the follower's signature must check out.
+	if a.ClientCert && !leadership.Forwarded(r, body) && (r.TLS == nil || len(r.TLS.VerifiedChains) == 0) {
+		return rejectClientCert
+	}
+	return ""
+}
+
+func (a *WebhookAuth) credentialsOK(r *http.Request) bool {
+	if got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && a.Token != "" {
+		return subtle.ConstantTimeCompare([]byte(got), []byte(a.Token)) == 1
+	}
+	if user, pass, ok := r.BasicAuth(); ok && a.Username != "" {
+		userOK := subtle.ConstantTimeCompare([]byte(user), []byte(a.Username)) == 1
+		passOK := subtle.ConstantTimeCompare([]byte(pass), []byte(a.Password)) == 1
+		return userOK && passOK
+	}
+	return false
+}
+
+func (a *WebhookAuth) signatureOK(header string, body []byte) bool {
+	got, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil || len(got) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(a.HMACSecret))
+	mac.Write(body)
+	return hmac.Equal(got, mac.Sum(nil))
+}
+
+// forwardHeaders are the request's credentials a follower passes on with the raw body, so
+// the leader can check them again.
+func (a *WebhookAuth) forwardHeaders(r *http.Request) http.Header {
+	h := http.Header{}
+	if a == nil {
+		return h
+	}
+	if v := r.Header.Get("Authorization"); v != "" {
+		h.Set("Authorization", v)
+	}
+	if v := r.Header.Get(a.HMACHeader); v != "" && a.HMACSecret != "" {
+		h.Set(a.HMACHeader, v)
+	}
+	return h
+}
+
+// rejectWebhook answers 401 and leaves a trace of the attempt: a log line, the rejected
+// counter and an audit record (action "webhook", outcome "rejected") naming the caller.
+func rejectWebhook(c *gin.Context, reason string) {
+	remote := c.ClientIP()
+	logger.Warnw("webhook rejected", "reason", reason, "remote", remote)
+	webhooksRejected.WithLabelValues(reason).Inc()
+	audit(trace.SpanFromContext(c.Request.Context()), "webhook", "", Result{
+		Plan:    Plan{Action: "webhook", Target: c.Request.URL.Path, Params: map[string]string{"reason": reason, "remote": remote}},
+		Outcome: OutcomeWebhookRejected,
+		Actor:   "remote:" + remote,
+	}, nil)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+}
+
+// webhookTLSServer serves handler over TLS on WEBHOOK_TLS_ADDR (default :8443) when
+// WEBHOOK_TLS_CERT and WEBHOOK_TLS_KEY are set, asking for client certificates signed by
+// WEBHOOK_TLS_CLIENT_CA if that is set. It returns nil without a certificate. Plain HTTP on
+// :8080 stays up for probes and metrics; with a client CA it no longer accepts webhooks.
+func webhookTLSServer(handler http.Handler) (*http.Server, error) {
+	cert, key := os.Getenv("WEBHOOK_TLS_CERT"), os.Getenv("WEBHOOK_TLS_KEY")
+	if cert == "" && key == "" {
+		if os.Getenv("WEBHOOK_TLS_CLIENT_CA") != "" {
+			return nil, errors.New("WEBHOOK_TLS_CLIENT_CA needs WEBHOOK_TLS_CERT and WEBHOOK_TLS_KEY")
+		}
+		return nil, nil
+	}
+	pair, err := tls.LoadX509KeyPair(cert, key)
+	if err != nil {
+		return nil, fmt.Errorf("load webhook TLS certificate: %w", err)
+	}
+	cfg := &tls.Config{Certificates: []tls.Certificate{pair}, MinVersion: tls.VersionTLS12}
+	if path := os.Getenv("WEBHOOK_TLS_CLIENT_CA"); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read webhook client CA: %w", err)
+		}
+		cfg.ClientCAs = x509.NewCertPool()
+		if !cfg.ClientCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("webhook client CA %s: no PEM certificates", path)
+		}
+		// Verified if given; the middleware rejects a webhook without one, while /healthz
+		// and /metrics on this port still answer.
+		cfg.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return &http.Server{Addr: envStr("WEBHOOK_TLS_ADDR", ":8443"), Handler: handler, TLSConfig: cfg}, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+const authPayload = `{"status":"firing","alerts":[{"status":"firing","labels":{"alertname":"HighErrorRate","service":"cart"}}]}`
+
+// authRouter serves /webhook behind a, with an audit log for the rejections.
+func authRouter(t *testing.T, a *WebhookAuth) *gin.Engine {
+	t.Helper()
+	webhookAuth, auditLog = a, testAuditLog(t)
+	t.Cleanup(func() { webhookAuth, auditLog = nil, nil })
+	r := gin.New()
+	r.POST("/webhook", a.Middleware(), webhookHandler)
+	return r
+}
+
+// sign is the HMAC-SHA256 signature header value for body.
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookAuth_BearerAndBasic(t *testing.T) {
+	router := authRouter(t, &WebhookAuth{Token: "am-token", Username: "alertmanager", Password: "pw"})
+	rejected := testutil.ToFloat64(webhooksRejected.WithLabelValues(rejectCredentials))
+
+	tests := []struct {
+		name string
+		set  func(*http.Request)
+		want int
+	}{
+		{"no credentials", func(*http.Request) {}, http.StatusUnauthorized},
+		{"wrong token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer nope") }, http.StatusUnauthorized},
+		{"wrong password", func(r *http.Request) { r.SetBasicAuth("alertmanager", "nope") }, http.StatusUnauthorized},
+		{"bearer token", func(r *http.Request) { r.Header.Set("Authorization", "Bearer am-token") }, http.StatusOK},
+		{"basic auth", func(r *http.Request) { r.SetBasicAuth("alertmanager", "pw") }, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(authPayload))
+			tt.set(req)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+
+	if got := testutil.ToFloat64(webhooksRejected.WithLabelValues(rejectCredentials)) - rejected; got != 3 {
+		t.Errorf("rejected{credentials} rose by %v, want 3", got)
+	}
+	got, _ := auditLog.Query(AuditFilter{Action: "webhook"})
+	if len(got) != 3 || got[0].Outcome != "rejected" || got[0].Params["reason"] != rejectCredentials || got[0].Actor != "remote:192.0.2.1" {
+		t.Errorf("audit = %+v, want the three rejections with reason and caller", got)
+	}
+}
+
+func TestWebhookAuth_HMACSignature(t *testing.T) {
+	router := authRouter(t, &WebhookAuth{HMACSecret: "s3cret", HMACHeader: defaultHMACHeader})
+
+	for name, tt := range map[string]struct {
+		body, sig string
+		want      int
+	}{
+		"signed":         {authPayload, sign("s3cret", authPayload), http.StatusOK},
+		"bare hex":       {authPayload, strings.TrimPrefix(sign("s3cret", authPayload), "sha256="), http.StatusOK},
+		"unsigned":       {authPayload, "", http.StatusUnauthorized},
+		"wrong secret":   {authPayload, sign("other", authPayload), http.StatusUnauthorized},
+		"tampered body":  {strings.Replace(authPayload, "cart", "checkout", 1), sign("s3cret", authPayload), http.StatusUnauthorized},
+		"not hex at all": {authPayload, "sha256=zz", http.StatusUnauthorized},
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(tt.body))
+			if tt.sig != "" {
+				req.Header.Set(defaultHMACHeader, tt.sig)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			if w.Code != tt.want {
+				t.Errorf("status = %d (%s), want %d", w.Code, w.Body.String(), tt.want)
+			}
+		})
+	}
+	if got, _ := auditLog.Query(AuditFilter{Action: "webhook"}); len(got) != 4 || got[0].Params["reason"] != rejectSignature {
+		t.Errorf("audit = %+v, want four signature rejections", got)
+	}
+}
+
+// testCA issues a self-signed CA and, from it, a client certificate.
+func testCA(t *testing.T) (*x509.CertPool, tls.Certificate) {
+	t.Helper()
+	caKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	caTmpl := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "test CA"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		IsCA: true, BasicConstraintsValid: true, KeyUsage: x509.KeyUsageCertSign}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "alertmanager"},
+		NotBefore: time.Now().Add(-time.Hour), NotAfter: time.Now().Add(time.Hour),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, KeyUsage: x509.KeyUsageDigitalSignature}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	return pool, tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestWebhookAuth_ClientCertificate(t *testing.T) {
+	router := authRouter(t, &WebhookAuth{ClientCert: true})
+	pool, clientCert := testCA(t)
+	srv := httptest.NewUnstartedServer(router)
+	srv.TLS = &tls.Config{ClientCAs: pool, ClientAuth: tls.VerifyClientCertIfGiven}
+	srv.StartTLS()
+	defer srv.Close()
+
+	post := func(certs ...tls.Certificate) int {
+		transport := srv.Client().Transport.(*http.Transport).Clone() // a new connection each time
+		transport.TLSClientConfig.Certificates = certs
+		resp, err := (&http.Client{Transport: transport}).Post(srv.URL+"/webhook", "application/json", strings.NewReader(authPayload))
+		if err != nil {
+			t.Fatalf("POST: %v", err)
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return resp.StatusCode
+	}
+	if got := post(); got != http.StatusUnauthorized {
+		t.Errorf("without a client certificate status = %d, want 401", got)
+	}
+	if got := post(clientCert); got != http.StatusOK {
+		t.Errorf("with a client certificate status = %d, want 200", got)
+	}
+
+	// Plain HTTP has no certificate to check, and a forged forwarded header on a singleton
+	// doesn't stand in for one.
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(authPayload))
+	req.Header.Set(forwardedHeader, "pod-c_10.0.0.3:8080")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("plain HTTP claiming to be forwarded status = %d, want 401", w.Code)
+	}
+	if got, _ := auditLog.Query(AuditFilter{Action: "webhook"}); len(got) != 2 || got[1].Params["reason"] != rejectClientCert {
+		t.Errorf("audit = %+v, want two client_cert rejections", got)
+	}
+}
+
+func TestWebhookAuth_ForwardedHeaderNeedsTheReplicasSignature(t *testing.T) {
+	router := authRouter(t, &WebhookAuth{ClientCert: true})
+	l := &Leadership{identity: "pod-a_10.0.0.1:8080", secret: []byte("replicas-only")}
+	l.setLeading(true)
+	leadership = l
+	t.Cleanup(func() { leadership = nil; isLeader.Set(0) })
+
+	post := func(set func(*http.Request)) int {
+		req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(authPayload))
+		set(req)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+	follower := &Leadership{secret: []byte("replicas-only")}
+	outsider := &Leadership{secret: []byte("guessed")}
+	for name, tt := range map[string]struct {
+		set  func(*http.Request)
+		want int
+	}{
+		"header only": {func(r *http.Request) { r.Header.Set(forwardedHeader, "x") }, http.StatusUnauthorized},
+		"wrong secret": {func(r *http.Request) {
+			signForward(r, outsider, "pod-b_10.0.0.2:8080", time.Now())
+		}, http.StatusUnauthorized},
+		"signed for another sender": {func(r *http.Request) {
+			signForward(r, follower, "pod-b_10.0.0.2:8080", time.Now())
+			r.Header.Set(forwardedHeader, "pod-c_10.0.0.3:8080")
+		}, http.StatusUnauthorized},
+		"replayed after forwardMaxAge": {func(r *http.Request) {
+			signForward(r, follower, "pod-b_10.0.0.2:8080", time.Now().Add(-2*forwardMaxAge))
+		}, http.StatusUnauthorized},
+		"signing time moved": {func(r *http.Request) {
+			signForward(r, follower, "pod-b_10.0.0.2:8080", time.Now().Add(-2*forwardMaxAge))
+			r.Header.Set(forwardTimeHeader, strconv.FormatInt(time.Now().Unix(), 10))
+		}, http.StatusUnauthorized},
+		"signed by a follower": {func(r *http.Request) {
+			signForward(r, follower, "pod-b_10.0.0.2:8080", time.Now())
+		}, http.StatusOK},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if got := post(tt.set); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// signForward marks r as forwarded by identity, signed with l's secret at at.
+func signForward(r *http.Request, l *Leadership, identity string, at time.Time) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.Header.Set(forwardedHeader, identity)
+	r.Header.Set(forwardTimeHeader, strconv.FormatInt(at.Unix(), 10))
+	r.Header.Set(forwardSignatureHeader, l.sign(identity, at, body))
+}
+
+func TestWebhookAuth_FollowerForwardsCredentials(t *testing.T) {
+	a := &WebhookAuth{Token: "am-token", HMACSecret: "s3cret", HMACHeader: defaultHMACHeader}
+	var status int
+	leader := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if a.check(r, body) == "" {
+			status = http.StatusOK
+		} else {
+			status = http.StatusUnauthorized
+		}
+		w.WriteHeader(status)
+	}))
+	defer leader.Close()
+	router := authRouter(t, a)
+	followerOf(t, strings.TrimPrefix(leader.URL, "http://"))
+
+	// Extra whitespace: re-encoding the payload would break the signature.
+	body := strings.Replace(authPayload, `"alerts":`, `"alerts":  `, 1)
+	req := httptest.NewRequest(http.MethodPost, "/webhook", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer am-token")
+	req.Header.Set(defaultHMACHeader, sign("s3cret", body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK || status != http.StatusOK {
+		t.Errorf("follower status = %d, leader status = %d; want the leader to accept the forwarded credentials", w.Code, status)
+	}
+}
+
+func TestInitWebhookAuth(t *testing.T) {
+	if a, err := initWebhookAuth(); a != nil || err != nil {
+		t.Errorf("initWebhookAuth() without config = (%+v, %v), want nil", a, err)
+	}
+	t.Setenv("WEBHOOK_BASIC_USER", "alertmanager")
+	if _, err := initWebhookAuth(); err == nil {
+		t.Error("basic user without a password accepted, want an error")
+	}
+	t.Setenv("WEBHOOK_BASIC_USER", "")
+	t.Setenv("WEBHOOK_TLS_CLIENT_CA", "/etc/remediator-tls/ca.crt")
+	t.Setenv("LEADER_ELECTION_ENABLED", "true")
+	if _, err := initWebhookAuth(); err == nil {
+		t.Error("client certificates alone with leader election accepted, want an error")
+	}
+	t.Setenv("WEBHOOK_HMAC_SECRET", "s3cret")
+	if _, err := initWebhookAuth(); err == nil {
+		t.Error("client certificates with leader election but no forward secret accepted, want an error")
+	}
+	t.Setenv("LEADER_FORWARD_SECRET", "replicas-only")
+	if a, err := initWebhookAuth(); err != nil || !a.ClientCert || a.HMACHeader != defaultHMACHeader {
+		t.Errorf("initWebhookAuth() = (%+v, %v), want client certificates and the default HMAC header", a, err)
+	}
+}